@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	"github.com/NCCloud/metadata-reflector/internal/controllers/reflector"
+	"github.com/NCCloud/metadata-reflector/internal/inspect"
+
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	errUsage         = errors.New("usage")
+	errInvalidObject = errors.New("invalid object")
+	errUnknownKind   = errors.New("unknown kind")
+)
+
+const usage = `Inspect and audit reflection of metadata-reflector.
+
+Usage:
+  kubectl reflector status KIND/NAME [flags]   explain the plan of a source and which targets are out of sync
+  kubectl reflector explain KIND/NAME [flags]  explain keys reflected to a target and where they come from
+  kubectl reflector audit [flags]              find out of sync targets, failing sources and orphaned records
+
+Objects are fetched from the cluster, or read from files with -f, e.g. the output of kubectl get -o yaml.
+
+Flags:
+`
+
+// options flags shared by all subcommands.
+type options struct {
+	namespace     string
+	allNamespaces bool
+	files         stringList
+	output        string
+	configFile    string
+	kubeconfig    string
+	context       string
+}
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+
+	return nil
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	command, object, opts, argsErr := parseArgs(args)
+	if argsErr != nil {
+		return argsErr
+	}
+
+	config, configErr := common.NewConfig(opts.configFile)
+	if configErr != nil {
+		return configErr
+	}
+
+	objects, namespace, objectsErr := loadObjects(ctx, opts)
+	if objectsErr != nil {
+		return objectsErr
+	}
+
+	inspector := inspect.NewInspector(objects, config)
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: object.Name}
+
+	switch command {
+	case "status":
+		explanation, statusErr := inspector.Status(ctx, object.Kind, namespacedName)
+		if statusErr != nil {
+			return statusErr
+		}
+
+		return printExplanation(out, opts.output, explanation)
+	case "explain":
+		report, explainErr := inspector.ExplainTarget(ctx, object.Kind, namespacedName)
+		if explainErr != nil {
+			return explainErr
+		}
+
+		return printTargetReport(out, opts.output, report)
+	default:
+		return printFindings(out, opts.output, inspector.Audit(ctx))
+	}
+}
+
+// parse the subcommand, its object and flags, flags are allowed before and after the object as kubectl allows them.
+func parseArgs(args []string) (string, inspect.ObjectReference, *options, error) {
+	flags, opts := newFlagSet()
+
+	if len(args) == 0 {
+		flags.Usage()
+
+		return "", inspect.ObjectReference{}, nil, errUsage
+	}
+
+	var positional []string
+
+	remaining := args[1:]
+	for len(remaining) > 0 {
+		if parseErr := flags.Parse(remaining); parseErr != nil {
+			return "", inspect.ObjectReference{}, nil, errUsage
+		}
+
+		if flags.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, flags.Arg(0))
+		remaining = flags.Args()[1:]
+	}
+
+	switch command := args[0]; {
+	case command == "audit" && len(positional) == 0:
+		return command, inspect.ObjectReference{}, opts, nil
+	case (command == "status" || command == "explain") && len(positional) == 1:
+		kind, name, objectErr := parseObject(positional[0])
+
+		return command, inspect.ObjectReference{Kind: kind, Name: name}, opts, objectErr
+	default:
+		flags.Usage()
+
+		return "", inspect.ObjectReference{}, nil, errUsage
+	}
+}
+
+func newFlagSet() (*flag.FlagSet, *options) {
+	opts := &options{}
+	flags := flag.NewFlagSet("kubectl-reflector", flag.ContinueOnError)
+
+	flags.StringVar(&opts.namespace, "namespace", "", "namespace of the objects, defaults to the namespace of the context")
+	flags.StringVar(&opts.namespace, "n", "", "shorthand for --namespace")
+	flags.BoolVar(&opts.allNamespaces, "all-namespaces", false, "inspect objects of all namespaces")
+	flags.BoolVar(&opts.allNamespaces, "A", false, "shorthand for --all-namespaces")
+	flags.Var(&opts.files, "f", "file to read objects from instead of the cluster, can be repeated")
+	flags.StringVar(&opts.output, "o", "", "output format, text or json")
+	flags.StringVar(&opts.configFile, "config", "",
+		"configuration file of the controller, e.g. to use the same conflict policy and denied keys")
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "path to the kubeconfig file")
+	flags.StringVar(&opts.context, "context", "", "kubeconfig context to use")
+
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	return flags, opts
+}
+
+/*
+load objects from files or from the cluster and get the namespace of the object to inspect.
+objects of all namespaces are loaded with -A, pods can reference objects of their namespace only.
+*/
+func loadObjects(ctx context.Context, opts *options) ([]client.Object, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.kubeconfig
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules, &clientcmd.ConfigOverrides{CurrentContext: opts.context})
+
+	namespace := opts.namespace
+
+	if len(opts.files) > 0 {
+		objects, readErr := inspect.ReadFiles(opts.files)
+
+		return objects, defaultNamespace(namespace, "default"), readErr
+	}
+
+	if namespace == "" {
+		contextNamespace, _, namespaceErr := clientConfig.Namespace()
+		if namespaceErr != nil {
+			return nil, "", namespaceErr
+		}
+
+		namespace = contextNamespace
+	}
+
+	restConfig, restConfigErr := clientConfig.ClientConfig()
+	if restConfigErr != nil {
+		return nil, "", restConfigErr
+	}
+
+	reader, clientErr := client.New(restConfig, client.Options{Scheme: clientgoscheme.Scheme})
+	if clientErr != nil {
+		return nil, "", clientErr
+	}
+
+	fetchedNamespace := namespace
+	if opts.allNamespaces {
+		fetchedNamespace = ""
+	}
+
+	objects, fetchErr := inspect.FetchObjects(ctx, reader, fetchedNamespace)
+
+	return objects, namespace, fetchErr
+}
+
+func defaultNamespace(namespace string, fallback string) string {
+	if namespace == "" {
+		return fallback
+	}
+
+	return namespace
+}
+
+// parse a KIND/NAME argument, kinds are accepted the way kubectl accepts them, e.g. deploy/app or cm/config.
+func parseObject(argument string) (string, string, error) {
+	kindName, name, found := strings.Cut(argument, "/")
+	if !found || name == "" {
+		return "", "", fmt.Errorf("%w: %s should be KIND/NAME", errInvalidObject, argument)
+	}
+
+	kinds := map[string][]string{
+		reflector.SourceKindDeployment: {"deploy", "deployment", "deployments"},
+		reflector.SourceKindCronJob:    {"cj", "cronjob", "cronjobs"},
+		reflector.SourceKindJob:        {"job", "jobs"},
+		reflector.SourceKindConfigMap:  {"cm", "configmap", "configmaps"},
+		reflector.SourceKindSecret:     {"secret", "secrets"},
+		"Pod":                          {"po", "pod", "pods"},
+		"Service":                      {"svc", "service", "services"},
+		"EndpointSlice":                {"endpointslice", "endpointslices"},
+	}
+
+	for kind, aliases := range kinds {
+		for _, alias := range aliases {
+			if strings.EqualFold(kindName, alias) {
+				return kind, name, nil
+			}
+		}
+	}
+
+	return "", "", fmt.Errorf("%w: %s", errUnknownKind, kindName)
+}
+
+func printJSON(out io.Writer, value any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
+
+func printExplanation(out io.Writer, output string, explanation *reflector.Explanation) error {
+	if output == "json" {
+		return printJSON(out, explanation)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "%s %s/%s\n\n", explanation.Kind, explanation.Namespace, explanation.Name)
+	fmt.Fprintln(writer, "ANNOTATION\tOPERATION\tVALUE\tMATCHED KEYS")
+
+	for _, operation := range explanation.Operations {
+		matched := strings.Join(operation.MatchedKeys, ",")
+		if operation.Error != "" {
+			matched = "error: " + operation.Error
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", operation.Annotation, operation.Operation, operation.Value, matched)
+	}
+
+	fmt.Fprintln(writer, "\nTARGET\tSTATUS\tCHANGES")
+
+	for _, target := range explanation.Targets {
+		status := "in sync"
+
+		switch {
+		case target.Excluded:
+			status = "excluded"
+		case target.Changed:
+			status = "out of sync"
+		}
+
+		fmt.Fprintf(writer, "%s %s\t%s\t%s\n", target.Kind, target.Name, status, formatChanges(target))
+	}
+
+	for _, explainedErr := range explanation.Errors {
+		fmt.Fprintf(writer, "\nerror: %s\n", explainedErr)
+	}
+
+	return writer.Flush()
+}
+
+func printTargetReport(out io.Writer, output string, report *inspect.TargetReport) error {
+	if output == "json" {
+		return printJSON(out, report)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "%s\n\n", report.ObjectReference)
+	fmt.Fprintln(writer, "KEY\tVALUE\tOWNER\tCLAIMS")
+
+	for _, key := range report.Keys {
+		owner := "unknown source " + string(key.Owner)
+		if key.OwnerSource != nil {
+			owner = key.OwnerSource.String()
+		}
+
+		if key.Legacy {
+			owner = "reflected-list annotation"
+		}
+
+		var claims []string
+
+		for index, claimSource := range key.ClaimSources {
+			if claimSource == nil {
+				claims = append(claims, "unknown source "+string(key.Claims[index].Source))
+			} else {
+				claims = append(claims, claimSource.String())
+			}
+		}
+
+		fmt.Fprintf(writer, "%s/%s\t%s\t%s\t%s\n",
+			key.Metadata, key.Key, formatValue(key.Value), owner, strings.Join(claims, ","))
+	}
+
+	for _, pending := range report.Pending {
+		fmt.Fprintf(writer, "\npending from %s: %s\n", pending.Source, formatChanges(pending.Target))
+	}
+
+	for _, reportErr := range report.Errors {
+		fmt.Fprintf(writer, "\nerror: %s\n", reportErr)
+	}
+
+	return writer.Flush()
+}
+
+func printFindings(out io.Writer, output string, findings []inspect.Finding) error {
+	if output == "json" {
+		return printJSON(out, findings)
+	}
+
+	if len(findings) == 0 {
+		_, printErr := fmt.Fprintln(out, "No findings")
+
+		return printErr
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "TYPE\tOBJECT\tSOURCE\tKEYS\tMESSAGE")
+
+	for _, finding := range findings {
+		source := "-"
+		if finding.Source != nil {
+			source = finding.Source.String()
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+			finding.Type, finding.Object, source, strings.Join(finding.Keys, ","), finding.Message)
+	}
+
+	return writer.Flush()
+}
+
+func formatChanges(target reflector.TargetExplanation) string {
+	var changes []string
+
+	for _, change := range target.Labels {
+		changes = append(changes, formatChange("labels", change))
+	}
+
+	for _, change := range target.Annotations {
+		changes = append(changes, formatChange("annotations", change))
+	}
+
+	if len(changes) == 0 {
+		return "-"
+	}
+
+	return strings.Join(changes, " ")
+}
+
+func formatChange(metadata string, change reflector.KeyChange) string {
+	return fmt.Sprintf("%s/%s:%s->%s", metadata, change.Key, formatValue(change.From), formatValue(change.To))
+}
+
+func formatValue(value *string) string {
+	if value == nil {
+		return "<none>"
+	}
+
+	return *value
+}
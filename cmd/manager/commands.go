@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	"github.com/NCCloud/metadata-reflector/internal/inspect"
+)
+
+// exit codes of offline commands.
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+const offlineUsage = `Evaluate reflector annotations against manifests, without access to a cluster.
+
+Usage:
+  metadata-reflector lint [flags]    check that reflector annotations of sources are valid
+  metadata-reflector render [flags]  print the metadata pods and other targets end up with
+
+Manifests of sources and targets are read from files, or from the standard input when no file is given.
+Deployments without pods in the manifests are rendered to a pod created from their template.
+The exit code is 1 when a source is invalid.
+
+Flags:
+`
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+
+	return nil
+}
+
+func isOfflineCommand(command string) bool {
+	return command == "lint" || command == "render"
+}
+
+// run an offline command and get its exit code.
+func runOfflineCommand(ctx context.Context, command string, args []string, stdout io.Writer, stderr io.Writer) int {
+	var files stringList
+
+	flags := flag.NewFlagSet("metadata-reflector "+command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Var(&files, "f", "manifest file to read, - reads the standard input, can be repeated")
+	output := flags.String("o", "", "output format, text or json")
+	configFile := flags.String("config", "",
+		"configuration file of the controller, e.g. to use the same denied keys and label policy")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, offlineUsage)
+		flags.PrintDefaults()
+	}
+
+	if parseErr := flags.Parse(args); parseErr != nil || flags.NArg() > 0 {
+		return exitUsage
+	}
+
+	if len(files) == 0 {
+		files = stringList{"-"}
+	}
+
+	config, configErr := common.NewConfig(*configFile)
+	if configErr != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", configErr)
+
+		return exitUsage
+	}
+
+	objects, readErr := inspect.ReadFiles(files)
+	if readErr != nil {
+		fmt.Fprintf(stderr, "Failed to read manifests: %v\n", readErr)
+
+		return exitUsage
+	}
+
+	reports := inspect.NewInspector(inspect.WithTemplatePods(objects), config).Render(ctx)
+
+	printErr := printReports(stdout, command, *output, reports)
+	if printErr != nil {
+		fmt.Fprintf(stderr, "Failed to print results: %v\n", printErr)
+
+		return exitUsage
+	}
+
+	for _, report := range reports {
+		if !report.Valid() {
+			return exitInvalid
+		}
+	}
+
+	return exitValid
+}
+
+func printReports(out io.Writer, command string, output string, reports []inspect.SourceReport) error {
+	if output == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(reports)
+	}
+
+	var text strings.Builder
+
+	if len(reports) == 0 {
+		text.WriteString("No sources with reflector annotations\n")
+	}
+
+	for _, report := range reports {
+		status := "valid"
+		if !report.Valid() {
+			status = "invalid"
+		}
+
+		fmt.Fprintf(&text, "%s: %s\n", report.ObjectReference, status)
+
+		for _, reportErr := range report.Errors {
+			fmt.Fprintf(&text, "  error: %s\n", reportErr)
+		}
+
+		if command != "render" {
+			continue
+		}
+
+		for _, target := range report.Targets {
+			fmt.Fprintf(&text, "  %s\n", target.ObjectReference)
+			writeMetadata(&text, "labels", target.Labels)
+			writeMetadata(&text, "annotations", target.Annotations)
+		}
+	}
+
+	_, writeErr := io.WriteString(out, text.String())
+
+	return writeErr
+}
+
+func writeMetadata(text *strings.Builder, name string, metadata map[string]string) {
+	if len(metadata) == 0 {
+		return
+	}
+
+	fmt.Fprintf(text, "    %s:\n", name)
+
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		fmt.Fprintf(text, "      %s: %s\n", key, metadata[key])
+	}
+}
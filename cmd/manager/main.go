@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 
@@ -11,30 +13,48 @@ import (
 
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	uberzap "go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 func main() {
-	config := common.NewConfig()
+	if len(os.Args) > 1 && isOfflineCommand(os.Args[1]) {
+		os.Exit(runOfflineCommand(context.Background(), os.Args[1], os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	configFile := flag.String("config", "", "path to a YAML or JSON configuration file, overrides CONFIG_FILE")
+	flag.Parse()
 
-	var level zapcore.Level
-	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
-		fmt.Fprintf(os.Stderr, "Invalid log level %q, defaulting to info, error: %v\n", config.LogLevel, err)
-		// default to info if invalid
-		level = zapcore.InfoLevel
+	config, configErr := common.NewConfig(*configFile)
+	if configErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", configErr)
+		os.Exit(1)
 	}
 
+	// the level is validated with the configuration, an atomic level lets it change on reload
+	level, _ := zapcore.ParseLevel(config.LogLevel)
+	atomicLevel := uberzap.NewAtomicLevelAt(level)
+
 	opts := zap.Options{
 		Development: false,
-		Level:       level,
+		Level:       atomicLevel,
 	}
 
 	logger := zap.New(zap.UseFlagOptions(&opts))
 	ctrl.SetLogger(logger)
 	logger.Info("Log level configuration", "configured", level.String())
 
+	configWatcher := common.NewConfigWatcher(config, logger)
+	configWatcher.OnReload(func(reloadedConfig *common.Config) {
+		reloadedLevel, _ := zapcore.ParseLevel(reloadedConfig.LogLevel)
+		if reloadedLevel != atomicLevel.Level() {
+			atomicLevel.SetLevel(reloadedLevel)
+			logger.Info("Log level changed", "configured", reloadedLevel.String())
+		}
+	})
+
 	mgr, mgrErr := clients.NewControllerManager(config, logger)
 	if mgrErr != nil {
 		logger.Error(mgrErr, "Failed to created the controller manager")
@@ -42,12 +62,25 @@ func main() {
 	}
 
 	kubeClient := clients.NewKubernetesClient(mgr, config)
-	reflectorController := reflector.NewController(kubeClient, logger, config)
+	recorder := mgr.GetEventRecorder("metadata-reflector")
+	reflectorController := reflector.NewController(kubeClient, logger, configWatcher, recorder)
+
+	if addConfigWatcherErr := mgr.Add(configWatcher); addConfigWatcherErr != nil {
+		panic(addConfigWatcherErr)
+	}
 
 	if reflectorControllerErr := reflectorController.SetupWithManager(mgr); reflectorControllerErr != nil {
 		panic(reflectorControllerErr)
 	}
 
+	if config.EnableDebugEndpoints {
+		addPlanHandlerErr := mgr.AddMetricsServerExtraHandler(
+			reflector.PlanEndpointPath, reflectorController.PlanHandler())
+		if addPlanHandlerErr != nil {
+			panic(addPlanHandlerErr)
+		}
+	}
+
 	if addHealthCheckErr := mgr.AddHealthzCheck("healthz", healthz.Ping); addHealthCheckErr != nil {
 		panic(addHealthCheckErr)
 	}
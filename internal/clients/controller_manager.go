@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -29,7 +30,11 @@ func NewControllerManager(config *common.Config, logger logr.Logger) (manager.Ma
 		return nil, errors.Wrap(cacheOptsErr, "failed to get cache options")
 	}
 
-	mgr, managerErr := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = config.KubeAPIQPS
+	restConfig.Burst = config.KubeAPIBurst
+
+	mgr, managerErr := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme: scheme,
 		Logger: logger,
 		Metrics: server.Options{
@@ -50,26 +55,20 @@ func NewControllerManager(config *common.Config, logger logr.Logger) (manager.Ma
 	return mgr, nil
 }
 
+/*
+GetCacheOptions restrict cached objects to the configured namespaces and selectors.
+cached pods are trimmed to the metadata and references the reflector reads, as pods are usually the largest cache.
+controllers of pods are only read for their selectors, so they are trimmed to metadata and selectors as well.
+*/
 func GetCacheOptions(config *common.Config, logger logr.Logger) (cache.Options, error) {
-	var labelParseErr error
-
-	rawDeploymentSelector := config.DeploymentSelector
-	labelSelector := labels.NewSelector()
-
-	// if the selector is empty, it will only match resources without labels
-	if rawDeploymentSelector != "" {
-		labelSelector, labelParseErr = labels.Parse(rawDeploymentSelector)
-		if labelParseErr != nil {
-			logger.Error(labelParseErr,
-				"Failed to construct deployment selector",
-				"selector", config.DeploymentSelector,
-			)
-
-			return cache.Options{}, labelParseErr
-		}
+	deploymentSelector, deploymentSelectorErr := parseCacheSelector("DEPLOYMENT_SELECTOR", config.DeploymentSelector, logger)
+	if deploymentSelectorErr != nil {
+		return cache.Options{}, deploymentSelectorErr
+	}
 
-		logger.Info("DEPLOYMENT_SELECTOR is set, will only watch deployments matching it",
-			"selector", labelSelector.String())
+	podSelector, podSelectorErr := parseCacheSelector("POD_SELECTOR", config.PodSelector, logger)
+	if podSelectorErr != nil {
+		return cache.Options{}, podSelectorErr
 	}
 
 	namespaces := make(map[string]cache.Config)
@@ -86,9 +85,34 @@ func GetCacheOptions(config *common.Config, logger logr.Logger) (cache.Options,
 	return cache.Options{
 		ByObject: map[client.Object]cache.ByObject{
 			&appsv1.Deployment{}: {
-				Label: labelSelector,
+				Label: deploymentSelector,
 			},
+			&v1.Pod{}: {
+				Label:     podSelector,
+				Transform: TrimPod,
+			},
+			&appsv1.ReplicaSet{}:  {Transform: TrimPodController},
+			&appsv1.StatefulSet{}: {Transform: TrimPodController},
+			&appsv1.DaemonSet{}:   {Transform: TrimPodController},
 		},
 		DefaultNamespaces: namespaces,
 	}, nil
 }
+
+// parse a label selector of cached objects, an empty selector matches all objects.
+func parseCacheSelector(setting string, rawSelector string, logger logr.Logger) (labels.Selector, error) {
+	if rawSelector == "" {
+		return labels.NewSelector(), nil
+	}
+
+	labelSelector, labelParseErr := labels.Parse(rawSelector)
+	if labelParseErr != nil {
+		logger.Error(labelParseErr, "Failed to construct selector", "setting", setting, "selector", rawSelector)
+
+		return nil, labelParseErr
+	}
+
+	logger.Info(setting+" is set, will only watch objects matching it", "selector", labelSelector.String())
+
+	return labelSelector, nil
+}
@@ -8,6 +8,7 @@ import (
 	mockManager "github.com/NCCloud/metadata-reflector/mocks/sigs.k8s.io/controller-runtime/pkg/manager"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -125,3 +126,64 @@ func TestGetCacheOptions_InvalidConfiguration(t *testing.T) {
 
 	assert.NotNil(t, cacheOptsErr)
 }
+
+func TestGetCacheOptions_PodSelectorConfigured(t *testing.T) {
+	logger := zap.New()
+
+	config := &common.Config{
+		PodSelector: "metadata-reflector.spaceship.com/enabled=true",
+	}
+
+	options, cacheOptsErr := GetCacheOptions(config, logger)
+
+	assert.Nil(t, cacheOptsErr)
+
+	var byObjectPod cache.ByObject
+
+	for key, value := range options.ByObject {
+		if _, ok := key.(*v1.Pod); ok {
+			byObjectPod = value
+		}
+	}
+
+	assert.Equal(t, config.PodSelector, byObjectPod.Label.String())
+	assert.NotNil(t, byObjectPod.Transform)
+}
+
+func TestGetCacheOptions_PodControllersTrimmed(t *testing.T) {
+	logger := zap.New()
+
+	config := &common.Config{
+		Namespaces: []string{"default"},
+	}
+
+	options, cacheOptsErr := GetCacheOptions(config, logger)
+
+	assert.Nil(t, cacheOptsErr)
+
+	trimmedKinds := 0
+
+	for key, value := range options.ByObject {
+		switch key.(type) {
+		case *appsv1.ReplicaSet, *appsv1.StatefulSet, *appsv1.DaemonSet:
+			trimmedKinds++
+
+			assert.NotNil(t, value.Transform)
+		}
+	}
+
+	assert.Equal(t, 3, trimmedKinds)
+	assert.Contains(t, options.DefaultNamespaces, "default")
+}
+
+func TestGetCacheOptions_InvalidPodSelector(t *testing.T) {
+	logger := zap.New()
+
+	config := &common.Config{
+		PodSelector: "invalid;selector",
+	}
+
+	_, cacheOptsErr := GetCacheOptions(config, logger)
+
+	assert.NotNil(t, cacheOptsErr)
+}
@@ -2,31 +2,54 @@ package clients
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"slices"
 
 	"github.com/NCCloud/metadata-reflector/internal/common"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
-	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"k8s.io/client-go/util/flowcontrol"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
 type KubernetesClient interface {
 	ListDeployments(ctx context.Context, labelSelector labels.Selector) (*appsv1.DeploymentList, error)
-	ListPods(ctx context.Context, labelSelector labels.Selector) (*v1.PodList, error)
+	ListPods(ctx context.Context, namespace string, labelSelector labels.Selector) (*v1.PodList, error)
 	GetDeployment(ctx context.Context, namespacedName types.NamespacedName) (*appsv1.Deployment, error)
-	UpdatePod(ctx context.Context, pod v1.Pod) error
+	ListServices(ctx context.Context, namespace string) (*v1.ServiceList, error)
+	ListEndpointSlices(ctx context.Context, namespace string, labelSelector labels.Selector,
+	) (*discoveryv1.EndpointSliceList, error)
+	ListPodsByIndex(ctx context.Context, namespace string, index string, value string) (*v1.PodList, error)
+	GetMetadata(ctx context.Context, gvk schema.GroupVersionKind, namespacedName types.NamespacedName,
+	) (*metav1.PartialObjectMetadata, error)
+	PatchMetadata(ctx context.Context, object *metav1.PartialObjectMetadata) error
+	GetCronJob(ctx context.Context, namespacedName types.NamespacedName) (*batchv1.CronJob, error)
+	GetJob(ctx context.Context, namespacedName types.NamespacedName) (*batchv1.Job, error)
+	ListJobs(ctx context.Context, namespace string) (*batchv1.JobList, error)
+	SetPodCondition(ctx context.Context, pod v1.Pod, condition v1.PodCondition) error
+	GetControllerSelector(ctx context.Context, namespace string, controller metav1.OwnerReference,
+	) (*metav1.LabelSelector, error)
 }
 
 type kubernetesClient struct {
-	// used for read operations
-	cacheClient cache.Cache
+	// used for read operations, the cache of the manager or objects in memory
+	cacheClient client.Reader
 	// used for write operations
 	client client.Client
-	config *common.Config
+	// shared by all reconciliations, so large fan-outs are spread over time
+	writeRateLimiter flowcontrol.RateLimiter
+	config           *common.Config
 }
 
 func NewKubernetesClient(mgr manager.Manager, config *common.Config,
@@ -35,9 +58,10 @@ func NewKubernetesClient(mgr manager.Manager, config *common.Config,
 	cacheClient := mgr.GetCache()
 
 	return &kubernetesClient{
-		cacheClient: cacheClient,
-		client:      client,
-		config:      config,
+		cacheClient:      cacheClient,
+		client:           client,
+		writeRateLimiter: flowcontrol.NewTokenBucketRateLimiter(config.WriteQPS, config.WriteBurst),
+		config:           config,
 	}
 }
 
@@ -56,10 +80,12 @@ func (c *kubernetesClient) ListDeployments(ctx context.Context, labelSelector la
 	return deploymentList, nil
 }
 
-func (c *kubernetesClient) ListPods(ctx context.Context, labelSelector labels.Selector,
+// ListPods list pods in the namespace matching the label selector.
+func (c *kubernetesClient) ListPods(ctx context.Context, namespace string, labelSelector labels.Selector,
 ) (*v1.PodList, error) {
 	podList := &v1.PodList{}
 	listOptions := &client.ListOptions{
+		Namespace:     namespace,
 		LabelSelector: labelSelector,
 	}
 
@@ -81,6 +107,220 @@ func (c *kubernetesClient) GetDeployment(ctx context.Context, namespacedName typ
 	return deployment, nil
 }
 
-func (c *kubernetesClient) UpdatePod(ctx context.Context, pod v1.Pod) error {
-	return c.client.Update(ctx, &pod)
+func (c *kubernetesClient) ListServices(ctx context.Context, namespace string,
+) (*v1.ServiceList, error) {
+	serviceList := &v1.ServiceList{}
+	listOptions := &client.ListOptions{
+		Namespace: namespace,
+	}
+
+	if listErr := c.cacheClient.List(ctx, serviceList, listOptions); listErr != nil {
+		return nil, listErr
+	}
+
+	return serviceList, nil
+}
+
+func (c *kubernetesClient) ListEndpointSlices(ctx context.Context, namespace string, labelSelector labels.Selector,
+) (*discoveryv1.EndpointSliceList, error) {
+	endpointSliceList := &discoveryv1.EndpointSliceList{}
+	listOptions := &client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: labelSelector,
+	}
+
+	if listErr := c.cacheClient.List(ctx, endpointSliceList, listOptions); listErr != nil {
+		return nil, listErr
+	}
+
+	return endpointSliceList, nil
+}
+
+// ListPodsByIndex list pods in the namespace with the given value of a cache index.
+func (c *kubernetesClient) ListPodsByIndex(ctx context.Context, namespace string, index string, value string,
+) (*v1.PodList, error) {
+	podList := &v1.PodList{}
+	listOptions := &client.ListOptions{
+		Namespace:     namespace,
+		FieldSelector: fields.OneTermEqualSelector(index, value),
+	}
+
+	if listErr := c.cacheClient.List(ctx, podList, listOptions); listErr != nil {
+		return nil, listErr
+	}
+
+	return podList, nil
+}
+
+// GetMetadata get only the metadata of an object of any kind.
+func (c *kubernetesClient) GetMetadata(
+	ctx context.Context, gvk schema.GroupVersionKind, namespacedName types.NamespacedName,
+) (*metav1.PartialObjectMetadata, error) {
+	object := &metav1.PartialObjectMetadata{}
+	object.SetGroupVersionKind(gvk)
+
+	if getErr := c.cacheClient.Get(ctx, namespacedName, object); getErr != nil {
+		return nil, getErr
+	}
+
+	return object, nil
+}
+
+/*
+PatchMetadata replace labels and annotations of an object of any kind, the kind is taken from the object.
+only the metadata is patched, so stale specs of cached objects are never written.
+the patch fails when the object was changed in the meantime, like an update would.
+*/
+func (c *kubernetesClient) PatchMetadata(ctx context.Context, object *metav1.PartialObjectMetadata) error {
+	objectLabels := object.GetLabels()
+	if objectLabels == nil {
+		objectLabels = map[string]string{}
+	}
+
+	objectAnnotations := object.GetAnnotations()
+	if objectAnnotations == nil {
+		objectAnnotations = map[string]string{}
+	}
+
+	patch, marshalErr := json.Marshal([]map[string]any{
+		{"op": "test", "path": "/metadata/resourceVersion", "value": object.GetResourceVersion()},
+		{"op": "add", "path": "/metadata/labels", "value": objectLabels},
+		{"op": "add", "path": "/metadata/annotations", "value": objectAnnotations},
+	})
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	if waitErr := c.waitForWrite(ctx); waitErr != nil {
+		return waitErr
+	}
+
+	patchErr := c.client.Patch(ctx, object, client.RawPatch(types.JSONPatchType, patch))
+	if isFailedResourceVersionTest(patchErr) {
+		// the API server rejects a failed test operation as invalid, while it's a conflict like a failed update
+		return apierrors.NewConflict(schema.GroupResource{
+			Group: object.GroupVersionKind().Group, Resource: object.GroupVersionKind().Kind,
+		}, object.GetName(), patchErr)
+	}
+
+	return patchErr
+}
+
+/*
+check whether a patch failed as the resource version of the object doesn't match anymore.
+the API server responds with 422 to a patch that cannot be applied, without any causes, while validation errors
+of the patched object list their fields as causes. the test is the only operation of the patch that can fail.
+*/
+func isFailedResourceVersionTest(patchErr error) bool {
+	var statusErr apierrors.APIStatus
+	if !apierrors.IsInvalid(patchErr) || !errors.As(patchErr, &statusErr) {
+		return false
+	}
+
+	details := statusErr.Status().Details
+
+	return details == nil || len(details.Causes) == 0
+}
+
+func (c *kubernetesClient) GetCronJob(ctx context.Context, namespacedName types.NamespacedName,
+) (*batchv1.CronJob, error) {
+	cronJob := &batchv1.CronJob{}
+
+	if getErr := c.cacheClient.Get(ctx, namespacedName, cronJob); getErr != nil {
+		return nil, getErr
+	}
+
+	return cronJob, nil
+}
+
+func (c *kubernetesClient) GetJob(ctx context.Context, namespacedName types.NamespacedName,
+) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+
+	if getErr := c.cacheClient.Get(ctx, namespacedName, job); getErr != nil {
+		return nil, getErr
+	}
+
+	return job, nil
+}
+
+func (c *kubernetesClient) ListJobs(ctx context.Context, namespace string,
+) (*batchv1.JobList, error) {
+	jobList := &batchv1.JobList{}
+	listOptions := &client.ListOptions{
+		Namespace: namespace,
+	}
+
+	if listErr := c.cacheClient.List(ctx, jobList, listOptions); listErr != nil {
+		return nil, listErr
+	}
+
+	return jobList, nil
+}
+
+// SetPodCondition add or replace a condition of the pod with a strategic merge patch of its status,
+// so conditions managed by the kubelet are not overwritten.
+func (c *kubernetesClient) SetPodCondition(ctx context.Context, pod v1.Pod, condition v1.PodCondition) error {
+	updatedPod := pod.DeepCopy()
+
+	conditionIndex := slices.IndexFunc(updatedPod.Status.Conditions, func(podCondition v1.PodCondition) bool {
+		return podCondition.Type == condition.Type
+	})
+	if conditionIndex == -1 {
+		updatedPod.Status.Conditions = append(updatedPod.Status.Conditions, condition)
+	} else {
+		updatedPod.Status.Conditions[conditionIndex] = condition
+	}
+
+	if waitErr := c.waitForWrite(ctx); waitErr != nil {
+		return waitErr
+	}
+
+	return c.client.Status().Patch(ctx, updatedPod, client.StrategicMergeFrom(&pod))
+}
+
+// wait until a write is allowed by the rate limiter, fails when the context is done first.
+func (c *kubernetesClient) waitForWrite(ctx context.Context) error {
+	if c.writeRateLimiter == nil {
+		return nil
+	}
+
+	return c.writeRateLimiter.Wait(ctx)
+}
+
+/*
+GetControllerSelector get the pod selector of a controller referenced by an owner reference of a pod.
+returns nil for kinds of controllers that don't select their pods with a label selector.
+*/
+func (c *kubernetesClient) GetControllerSelector(ctx context.Context, namespace string,
+	controller metav1.OwnerReference,
+) (*metav1.LabelSelector, error) {
+	var (
+		object   client.Object
+		selector func() *metav1.LabelSelector
+	)
+
+	switch controller.Kind {
+	case "ReplicaSet":
+		replicaSet := &appsv1.ReplicaSet{}
+		object, selector = replicaSet, func() *metav1.LabelSelector { return replicaSet.Spec.Selector }
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		object, selector = statefulSet, func() *metav1.LabelSelector { return statefulSet.Spec.Selector }
+	case "DaemonSet":
+		daemonSet := &appsv1.DaemonSet{}
+		object, selector = daemonSet, func() *metav1.LabelSelector { return daemonSet.Spec.Selector }
+	case "Job":
+		job := &batchv1.Job{}
+		object, selector = job, func() *metav1.LabelSelector { return job.Spec.Selector }
+	default:
+		return nil, nil
+	}
+
+	namespacedName := types.NamespacedName{Name: controller.Name, Namespace: namespace}
+	if getErr := c.cacheClient.Get(ctx, namespacedName, object); getErr != nil {
+		return nil, getErr
+	}
+
+	return selector(), nil
 }
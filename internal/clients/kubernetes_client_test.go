@@ -2,6 +2,7 @@ package clients
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/NCCloud/metadata-reflector/internal/common"
@@ -10,18 +11,25 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
 	ctrl "sigs.k8s.io/controller-runtime"
 	_ "sigs.k8s.io/controller-runtime/pkg/cache"
 	realClient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestNewKubernetesClient(t *testing.T) {
-	config := common.NewConfig()
+	config, _ := common.NewConfig("")
 	mgr, _ := ctrl.NewManager(&rest.Config{}, ctrl.Options{})
 
 	client := NewKubernetesClient(mgr, config)
@@ -31,7 +39,7 @@ func TestNewKubernetesClient(t *testing.T) {
 
 func TestKubernetesClient_ListDeployments(t *testing.T) {
 	ctx := context.Background()
-	config := common.NewConfig()
+	config, _ := common.NewConfig("")
 	mockCache := new(mockCache.MockCache)
 	mockClient := new(mockClient.MockClient)
 
@@ -92,7 +100,7 @@ func TestKubernetesClient_ListDeployments(t *testing.T) {
 
 func TestKubernetesClient_ListPods(t *testing.T) {
 	ctx := context.Background()
-	config := common.NewConfig()
+	config, _ := common.NewConfig("")
 	mockCache := new(mockCache.MockCache)
 	mockClient := new(mockClient.MockClient)
 
@@ -100,15 +108,25 @@ func TestKubernetesClient_ListPods(t *testing.T) {
 
 	matchingPod := v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   "matching-pod",
-			Labels: map[string]string{"matching": "true"},
+			Name:      "matching-pod",
+			Namespace: "default",
+			Labels:    map[string]string{"matching": "true"},
 		},
 	}
 
 	notMatchingPod := v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   "not-matching-pod",
-			Labels: map[string]string{"hello": "world"},
+			Name:      "not-matching-pod",
+			Namespace: "default",
+			Labels:    map[string]string{"hello": "world"},
+		},
+	}
+
+	otherNamespacePod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "matching-pod",
+			Namespace: "other",
+			Labels:    map[string]string{"matching": "true"},
 		},
 	}
 
@@ -121,13 +139,17 @@ func TestKubernetesClient_ListPods(t *testing.T) {
 	allPodList := []v1.Pod{
 		matchingPod,
 		notMatchingPod,
+		otherNamespacePod,
 	}
 
 	mockCache.On("List", mock.Anything, mock.Anything, mock.Anything).
 		Return(func(ctx context.Context, list realClient.ObjectList, opts ...realClient.ListOption) error {
+			listOptions := &realClient.ListOptions{}
+			listOptions.ApplyOptions(opts)
+
 			if podList, ok := list.(*v1.PodList); ok {
 				for _, pod := range allPodList {
-					if labelSelector.Matches(labels.Set(pod.Labels)) {
+					if pod.Namespace == listOptions.Namespace && listOptions.LabelSelector.Matches(labels.Set(pod.Labels)) {
 						podList.Items = append(podList.Items, pod)
 					}
 				}
@@ -141,7 +163,7 @@ func TestKubernetesClient_ListPods(t *testing.T) {
 		config:      config,
 	}
 
-	result, listErr := client.ListPods(ctx, labelSelector)
+	result, listErr := client.ListPods(ctx, "default", labelSelector)
 
 	assert.Nil(t, listErr)
 	assert.NotNil(t, result)
@@ -152,7 +174,7 @@ func TestKubernetesClient_ListPods(t *testing.T) {
 
 func TestKubernetesClient_GetDeployment(t *testing.T) {
 	ctx := context.Background()
-	config := common.NewConfig()
+	config, _ := common.NewConfig("")
 	mockCache := new(mockCache.MockCache)
 	mockClient := new(mockClient.MockClient)
 
@@ -197,40 +219,505 @@ func TestKubernetesClient_GetDeployment(t *testing.T) {
 	mockCache.AssertExpectations(t)
 }
 
-func TestKubernetesClient_UpdatePod(t *testing.T) {
+func TestKubernetesClient_ListServices(t *testing.T) {
 	ctx := context.Background()
+	config, _ := common.NewConfig("")
+	mockCache := new(mockCache.MockCache)
 	mockClient := new(mockClient.MockClient)
 
-	podToUpdate := v1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-pod",
-			Namespace: "default",
+	expectedServiceList := &v1.ServiceList{
+		Items: []v1.Service{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-service",
+					Namespace: "default",
+				},
+			},
 		},
-		Spec: v1.PodSpec{
-			Containers: []v1.Container{
-				{
-					Name:  "test-container",
-					Image: "test-image",
+	}
+
+	mockCache.On("List", mock.Anything, mock.AnythingOfType("*v1.ServiceList"), mock.Anything).
+		Return(func(ctx context.Context, list realClient.ObjectList, opts ...realClient.ListOption) error {
+			listOptions := &realClient.ListOptions{}
+			listOptions.ApplyOptions(opts)
+
+			assert.Equal(t, "default", listOptions.Namespace)
+
+			if serviceList, ok := list.(*v1.ServiceList); ok {
+				serviceList.Items = expectedServiceList.Items
+			}
+			return nil
+		})
+
+	client := &kubernetesClient{
+		cacheClient: mockCache,
+		client:      mockClient,
+		config:      config,
+	}
+
+	result, listErr := client.ListServices(ctx, "default")
+
+	assert.Nil(t, listErr)
+	assert.Equal(t, expectedServiceList, result)
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestKubernetesClient_ListEndpointSlices(t *testing.T) {
+	ctx := context.Background()
+	config, _ := common.NewConfig("")
+	mockCache := new(mockCache.MockCache)
+	mockClient := new(mockClient.MockClient)
+
+	labelSelector, _ := labels.Parse("kubernetes.io/service-name=test-service")
+
+	expectedEndpointSliceList := &discoveryv1.EndpointSliceList{
+		Items: []discoveryv1.EndpointSlice{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-service-abcde",
+					Namespace: "default",
+				},
+			},
+		},
+	}
+
+	mockCache.On("List", mock.Anything, mock.AnythingOfType("*v1.EndpointSliceList"), mock.Anything).
+		Return(func(ctx context.Context, list realClient.ObjectList, opts ...realClient.ListOption) error {
+			listOptions := &realClient.ListOptions{}
+			listOptions.ApplyOptions(opts)
+
+			assert.Equal(t, "default", listOptions.Namespace)
+			assert.Equal(t, labelSelector, listOptions.LabelSelector)
+
+			if endpointSliceList, ok := list.(*discoveryv1.EndpointSliceList); ok {
+				endpointSliceList.Items = expectedEndpointSliceList.Items
+			}
+			return nil
+		})
+
+	client := &kubernetesClient{
+		cacheClient: mockCache,
+		client:      mockClient,
+		config:      config,
+	}
+
+	result, listErr := client.ListEndpointSlices(ctx, "default", labelSelector)
+
+	assert.Nil(t, listErr)
+	assert.Equal(t, expectedEndpointSliceList, result)
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestKubernetesClient_ListPodsByIndex(t *testing.T) {
+	ctx := context.Background()
+	config, _ := common.NewConfig("")
+	mockCache := new(mockCache.MockCache)
+	mockClient := new(mockClient.MockClient)
+
+	expectedPodList := &v1.PodList{
+		Items: []v1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "default",
 				},
 			},
 		},
 	}
 
-	mockClient.On("Update", mock.Anything, mock.AnythingOfType("*v1.Pod")).
+	mockCache.On("List", mock.Anything, mock.AnythingOfType("*v1.PodList"), mock.Anything).
+		Return(func(ctx context.Context, list realClient.ObjectList, opts ...realClient.ListOption) error {
+			listOptions := &realClient.ListOptions{}
+			listOptions.ApplyOptions(opts)
+
+			assert.Equal(t, "default", listOptions.Namespace)
+			assert.Equal(t, "index=ConfigMap/test-config", listOptions.FieldSelector.String())
+
+			if podList, ok := list.(*v1.PodList); ok {
+				podList.Items = expectedPodList.Items
+			}
+			return nil
+		})
+
+	client := &kubernetesClient{
+		cacheClient: mockCache,
+		client:      mockClient,
+		config:      config,
+	}
+
+	result, listErr := client.ListPodsByIndex(ctx, "default", "index", "ConfigMap/test-config")
+
+	assert.Nil(t, listErr)
+	assert.Equal(t, expectedPodList, result)
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestKubernetesClient_GetMetadata(t *testing.T) {
+	ctx := context.Background()
+	config, _ := common.NewConfig("")
+	mockCache := new(mockCache.MockCache)
+	mockClient := new(mockClient.MockClient)
+
+	gvk := v1.SchemeGroupVersion.WithKind("ConfigMap")
+	namespacedName := types.NamespacedName{
+		Name:      "test-config",
+		Namespace: "default",
+	}
+
+	mockCache.On("Get", mock.Anything, namespacedName, mock.AnythingOfType("*v1.PartialObjectMetadata")).
 		Run(func(args mock.Arguments) {
-			if pod, ok := args.Get(1).(*v1.Pod); ok {
-				pod.Labels = map[string]string{"new-label": "true"} // Example modification
+			if object, ok := args.Get(2).(*metav1.PartialObjectMetadata); ok {
+				assert.Equal(t, gvk, object.GroupVersionKind())
+
+				object.Labels = map[string]string{"hello": "world"}
 			}
 		}).
 		Return(nil)
 
+	client := &kubernetesClient{
+		cacheClient: mockCache,
+		client:      mockClient,
+		config:      config,
+	}
+
+	result, getErr := client.GetMetadata(ctx, gvk, namespacedName)
+
+	assert.Nil(t, getErr)
+	assert.Equal(t, gvk, result.GroupVersionKind())
+	assert.Equal(t, map[string]string{"hello": "world"}, result.Labels)
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestKubernetesClient_PatchMetadata(t *testing.T) {
+	ctx := context.Background()
+	mockClient := new(mockClient.MockClient)
+
+	podToUpdate := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-pod",
+			Namespace:       "default",
+			ResourceVersion: "42",
+			Labels:          map[string]string{"new-label": "true"},
+		},
+	}
+
+	// only metadata is patched, so stale specs are never written
+	mockClient.On("Patch", mock.Anything, mock.AnythingOfType("*v1.PartialObjectMetadata"), mock.MatchedBy(func(patch realClient.Patch) bool {
+		data, _ := patch.Data(nil)
+
+		return patch.Type() == types.JSONPatchType && string(data) == `[`+
+			`{"op":"test","path":"/metadata/resourceVersion","value":"42"},`+
+			`{"op":"add","path":"/metadata/labels","value":{"new-label":"true"}},`+
+			`{"op":"add","path":"/metadata/annotations","value":{}}]`
+	})).Return(nil)
+
 	client := &kubernetesClient{
 		client: mockClient,
 	}
 
-	updateErr := client.UpdatePod(ctx, podToUpdate)
+	updateErr := client.PatchMetadata(ctx, podToUpdate)
 
 	assert.Nil(t, updateErr)
 
 	mockClient.AssertExpectations(t)
 }
+
+func TestKubernetesClient_PatchMetadataStaleObject(t *testing.T) {
+	mockClient := new(mockClient.MockClient)
+
+	client := &kubernetesClient{
+		client: mockClient,
+	}
+
+	object := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", ResourceVersion: "42"},
+	}
+
+	// the API server responds to a failed test operation of a JSON patch with 422
+	testFailedErr := apierrors.NewGenericServerResponse(http.StatusUnprocessableEntity, "",
+		schema.GroupResource{}, "", "testing value /metadata/resourceVersion failed: test failed", 0, false)
+	invalidLabelErr := apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "test-pod", field.ErrorList{
+		field.Invalid(field.NewPath("metadata", "labels"), "invalid key", "name part must consist of alphanumeric"),
+	})
+
+	mockClient.On("Patch", mock.Anything, object, mock.Anything).Return(testFailedErr).Once()
+	mockClient.On("Patch", mock.Anything, object, mock.Anything).Return(invalidLabelErr).Once()
+
+	patchErr := client.PatchMetadata(context.Background(), object)
+
+	assert.True(t, apierrors.IsInvalid(testFailedErr))
+	assert.True(t, apierrors.IsConflict(patchErr))
+
+	// other invalid patches are not conflicts
+	patchErr = client.PatchMetadata(context.Background(), object)
+
+	assert.True(t, apierrors.IsInvalid(patchErr))
+}
+
+func TestKubernetesClient_PatchMetadataRateLimited(t *testing.T) {
+	mockClient := new(mockClient.MockClient)
+
+	client := &kubernetesClient{
+		client:           mockClient,
+		writeRateLimiter: flowcontrol.NewTokenBucketRateLimiter(1, 1),
+	}
+
+	object := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "test-pod"}}
+
+	// the first write takes the burst
+	mockClient.On("Patch", mock.Anything, object, mock.Anything).Return(nil).Once()
+	assert.Nil(t, client.PatchMetadata(context.Background(), object))
+
+	// the next one has to wait, which a cancelled reconciliation can't do
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.NotNil(t, client.PatchMetadata(ctx, object))
+
+	mockClient.AssertNumberOfCalls(t, "Patch", 1)
+}
+
+func TestKubernetesClient_GetCronJob(t *testing.T) {
+	ctx := context.Background()
+	config, _ := common.NewConfig("")
+	mockCache := new(mockCache.MockCache)
+	mockClient := new(mockClient.MockClient)
+
+	expectedCronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cronjob",
+			Namespace: "default",
+		},
+	}
+
+	namespacedName := types.NamespacedName{
+		Name:      "test-cronjob",
+		Namespace: "default",
+	}
+
+	mockCache.On("Get", mock.Anything, namespacedName, mock.AnythingOfType("*v1.CronJob")).
+		Run(func(args mock.Arguments) {
+			if cronJob, ok := args.Get(2).(*batchv1.CronJob); ok {
+				*cronJob = *expectedCronJob
+			}
+		}).
+		Return(nil)
+
+	client := &kubernetesClient{
+		cacheClient: mockCache,
+		client:      mockClient,
+		config:      config,
+	}
+
+	result, getErr := client.GetCronJob(ctx, namespacedName)
+
+	assert.Nil(t, getErr)
+	assert.Equal(t, expectedCronJob, result)
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestKubernetesClient_GetJob(t *testing.T) {
+	ctx := context.Background()
+	config, _ := common.NewConfig("")
+	mockCache := new(mockCache.MockCache)
+	mockClient := new(mockClient.MockClient)
+
+	expectedJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-job",
+			Namespace: "default",
+		},
+	}
+
+	namespacedName := types.NamespacedName{
+		Name:      "test-job",
+		Namespace: "default",
+	}
+
+	mockCache.On("Get", mock.Anything, namespacedName, mock.AnythingOfType("*v1.Job")).
+		Run(func(args mock.Arguments) {
+			if job, ok := args.Get(2).(*batchv1.Job); ok {
+				*job = *expectedJob
+			}
+		}).
+		Return(nil)
+
+	client := &kubernetesClient{
+		cacheClient: mockCache,
+		client:      mockClient,
+		config:      config,
+	}
+
+	result, getErr := client.GetJob(ctx, namespacedName)
+
+	assert.Nil(t, getErr)
+	assert.Equal(t, expectedJob, result)
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestKubernetesClient_ListJobs(t *testing.T) {
+	ctx := context.Background()
+	config, _ := common.NewConfig("")
+	mockCache := new(mockCache.MockCache)
+	mockClient := new(mockClient.MockClient)
+
+	expectedJobList := &batchv1.JobList{
+		Items: []batchv1.Job{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-job",
+					Namespace: "default",
+				},
+			},
+		},
+	}
+
+	mockCache.On("List", mock.Anything, mock.AnythingOfType("*v1.JobList"), mock.Anything).
+		Return(func(ctx context.Context, list realClient.ObjectList, opts ...realClient.ListOption) error {
+			listOptions := &realClient.ListOptions{}
+			listOptions.ApplyOptions(opts)
+
+			assert.Equal(t, "default", listOptions.Namespace)
+
+			if jobList, ok := list.(*batchv1.JobList); ok {
+				jobList.Items = expectedJobList.Items
+			}
+			return nil
+		})
+
+	client := &kubernetesClient{
+		cacheClient: mockCache,
+		client:      mockClient,
+		config:      config,
+	}
+
+	result, listErr := client.ListJobs(ctx, "default")
+
+	assert.Nil(t, listErr)
+	assert.Equal(t, expectedJobList, result)
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestKubernetesClient_SetPodCondition(t *testing.T) {
+	ctx := context.Background()
+
+	conditionType := v1.PodConditionType("metadata-reflector.spaceship.com/MetadataConflict")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+		},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodReady, Status: v1.ConditionTrue},
+				{Type: conditionType, Status: v1.ConditionFalse},
+			},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithObjects(pod).
+		WithStatusSubresource(pod).
+		Build()
+
+	client := &kubernetesClient{
+		client: fakeClient,
+	}
+
+	setErr := client.SetPodCondition(ctx, *pod, v1.PodCondition{Type: conditionType, Status: v1.ConditionTrue})
+
+	assert.Nil(t, setErr)
+
+	updatedPod := &v1.Pod{}
+	getErr := fakeClient.Get(ctx, types.NamespacedName{Name: "test-pod", Namespace: "default"}, updatedPod)
+
+	// the condition is replaced and conditions managed by the kubelet are kept
+	assert.Nil(t, getErr)
+	assert.Equal(t, []v1.PodCondition{
+		{Type: v1.PodReady, Status: v1.ConditionTrue},
+		{Type: conditionType, Status: v1.ConditionTrue},
+	}, updatedPod.Status.Conditions)
+}
+
+func TestKubernetesClient_GetControllerSelector(t *testing.T) {
+	ctx := context.Background()
+	config, _ := common.NewConfig("")
+
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": "test"},
+	}
+
+	tests := []struct {
+		name       string
+		controller metav1.OwnerReference
+		mockSetup  func(*mockCache.MockCache)
+		want       *metav1.LabelSelector
+	}{
+		{
+			name:       "ReplicaSet",
+			controller: metav1.OwnerReference{Kind: "ReplicaSet", Name: "test-replicaset"},
+			mockSetup: func(mockCache *mockCache.MockCache) {
+				mockCache.On("Get", mock.Anything,
+					types.NamespacedName{Name: "test-replicaset", Namespace: "default"},
+					mock.AnythingOfType("*v1.ReplicaSet")).
+					Run(func(args mock.Arguments) {
+						if replicaSet, ok := args.Get(2).(*appsv1.ReplicaSet); ok {
+							replicaSet.Spec.Selector = selector
+						}
+					}).
+					Return(nil)
+			},
+			want: selector,
+		},
+		{
+			name:       "Job",
+			controller: metav1.OwnerReference{Kind: "Job", Name: "test-job"},
+			mockSetup: func(mockCache *mockCache.MockCache) {
+				mockCache.On("Get", mock.Anything,
+					types.NamespacedName{Name: "test-job", Namespace: "default"},
+					mock.AnythingOfType("*v1.Job")).
+					Run(func(args mock.Arguments) {
+						if job, ok := args.Get(2).(*batchv1.Job); ok {
+							job.Spec.Selector = selector
+						}
+					}).
+					Return(nil)
+			},
+			want: selector,
+		},
+		{
+			name:       "Controller without a selector",
+			controller: metav1.OwnerReference{Kind: "Node", Name: "test-node"},
+			mockSetup:  func(mockCache *mockCache.MockCache) {},
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCache := new(mockCache.MockCache)
+			tt.mockSetup(mockCache)
+
+			client := &kubernetesClient{
+				cacheClient: mockCache,
+				config:      config,
+			}
+
+			result, getErr := client.GetControllerSelector(ctx, "default", tt.controller)
+
+			assert.Nil(t, getErr)
+			assert.Equal(t, tt.want, result)
+
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
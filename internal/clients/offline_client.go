@@ -0,0 +1,159 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+var ErrOfflineWrite = errors.New("objects of an offline client are never written")
+
+// offlineKubernetesClient a client reading objects kept in memory, writes always fail.
+type offlineKubernetesClient struct {
+	*kubernetesClient
+}
+
+/*
+NewOfflineKubernetesClient a client of objects kept in memory instead of a cluster, e.g. objects fetched
+from a cluster or read from files to inspect reflection offline. pods are indexed with the given indexes
+the way the cache of the manager indexes them. objects are only read, writes fail with ErrOfflineWrite.
+*/
+func NewOfflineKubernetesClient(
+	objects []client.Object, podIndexes map[string]client.IndexerFunc, config *common.Config,
+) KubernetesClient {
+	scheme := runtime.NewScheme()
+
+	common.Must(clientgoscheme.AddToScheme(scheme))
+
+	reader := &memoryReader{
+		scheme:     scheme,
+		objects:    make(map[schema.GroupVersionKind][]client.Object),
+		podIndexes: podIndexes,
+	}
+
+	for _, object := range objects {
+		gvk, gvkErr := apiutil.GVKForObject(object, scheme)
+		common.Must(gvkErr)
+
+		reader.objects[gvk] = append(reader.objects[gvk], object)
+	}
+
+	return &offlineKubernetesClient{
+		kubernetesClient: &kubernetesClient{
+			cacheClient: reader,
+			config:      config,
+		},
+	}
+}
+
+// PatchMetadata always fails, objects of an offline client are never written.
+func (c *offlineKubernetesClient) PatchMetadata(_ context.Context, _ *metav1.PartialObjectMetadata) error {
+	return ErrOfflineWrite
+}
+
+// SetPodCondition always fails, objects of an offline client are never written.
+func (c *offlineKubernetesClient) SetPodCondition(_ context.Context, _ v1.Pod, _ v1.PodCondition) error {
+	return ErrOfflineWrite
+}
+
+/*
+memoryReader a read-only reader of objects kept in memory. objects are read the way the cache of the manager
+reads them: by namespace, label selector and exact values of indexes of pods. read objects are copies.
+*/
+type memoryReader struct {
+	scheme     *runtime.Scheme
+	objects    map[schema.GroupVersionKind][]client.Object
+	podIndexes map[string]client.IndexerFunc
+}
+
+// Get copy the object with the key into the given object, typed or metadata only.
+func (m *memoryReader) Get(
+	_ context.Context, key client.ObjectKey, object client.Object, _ ...client.GetOption,
+) error {
+	gvk, gvkErr := apiutil.GVKForObject(object, m.scheme)
+	if gvkErr != nil {
+		return gvkErr
+	}
+
+	for _, storedObject := range m.objects[gvk] {
+		if storedObject.GetNamespace() == key.Namespace && storedObject.GetName() == key.Name {
+			return copyObject(storedObject, object)
+		}
+	}
+
+	return apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}, key.Name)
+}
+
+// List copy objects of the kind of the list matching the list options into the list.
+func (m *memoryReader) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	gvk, gvkErr := apiutil.GVKForObject(list, m.scheme)
+	if gvkErr != nil {
+		return gvkErr
+	}
+
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+
+	listOptions := &client.ListOptions{}
+	listOptions.ApplyOptions(opts)
+
+	var items []runtime.Object
+
+	for _, storedObject := range m.objects[gvk] {
+		if m.matches(storedObject, listOptions) {
+			items = append(items, storedObject.DeepCopyObject())
+		}
+	}
+
+	return meta.SetList(list, items)
+}
+
+// check whether the object matches the namespace, the label selector and the indexes of the list options.
+func (m *memoryReader) matches(object client.Object, listOptions *client.ListOptions) bool {
+	if listOptions.Namespace != "" && object.GetNamespace() != listOptions.Namespace {
+		return false
+	}
+
+	if listOptions.LabelSelector != nil && !listOptions.LabelSelector.Matches(labels.Set(object.GetLabels())) {
+		return false
+	}
+
+	if listOptions.FieldSelector == nil {
+		return true
+	}
+
+	_, isPod := object.(*v1.Pod)
+
+	for _, requirement := range listOptions.FieldSelector.Requirements() {
+		indexer, indexed := m.podIndexes[requirement.Field]
+		if !isPod || !indexed || !slices.Contains(indexer(object), requirement.Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// copy the stored object into the given object, which can be of the same type or only hold its metadata.
+func copyObject(storedObject client.Object, object client.Object) error {
+	content, marshalErr := json.Marshal(storedObject)
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	return json.Unmarshal(content, object)
+}
@@ -0,0 +1,88 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNewOfflineKubernetesClient(t *testing.T) {
+	ctx := context.Background()
+
+	objects := []client.Object{
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: "pod1", Namespace: "default", Labels: map[string]string{"config": "app-config"},
+		}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "default"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}},
+	}
+
+	kubeClient := NewOfflineKubernetesClient(objects, map[string]client.IndexerFunc{
+		"config": func(object client.Object) []string {
+			return []string{object.GetLabels()["config"]}
+		},
+	}, &common.Config{})
+
+	podList, listErr := kubeClient.ListPodsByIndex(ctx, "default", "config", "app-config")
+
+	assert.Nil(t, listErr)
+	assert.Len(t, podList.Items, 1)
+	assert.Equal(t, "pod1", podList.Items[0].Name)
+
+	configMap, getErr := kubeClient.GetMetadata(ctx, v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		types.NamespacedName{Namespace: "default", Name: "app-config"})
+
+	assert.Nil(t, getErr)
+	assert.Equal(t, "app-config", configMap.Name)
+
+	// objects in memory are never written
+	configMap.SetLabels(map[string]string{"team": "payments"})
+
+	assert.ErrorIs(t, kubeClient.PatchMetadata(ctx, configMap), ErrOfflineWrite)
+	assert.ErrorIs(t, kubeClient.SetPodCondition(ctx, v1.Pod{}, v1.PodCondition{}), ErrOfflineWrite)
+
+	unchangedConfigMap, getUnchangedErr := kubeClient.GetMetadata(ctx, v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		types.NamespacedName{Namespace: "default", Name: "app-config"})
+
+	assert.Nil(t, getUnchangedErr)
+	assert.Empty(t, unchangedConfigMap.Labels)
+
+	_, getMissingErr := kubeClient.GetDeployment(ctx, types.NamespacedName{Namespace: "default", Name: "missing"})
+
+	assert.True(t, apierrors.IsNotFound(getMissingErr))
+}
+
+func TestNewOfflineKubernetesClient_ListPods(t *testing.T) {
+	objects := []client.Object{
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: "pod1", Namespace: "default", Labels: map[string]string{"app": "test"},
+		}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: "pod2", Namespace: "other", Labels: map[string]string{"app": "test"},
+		}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod3", Namespace: "default"}},
+	}
+
+	kubeClient := NewOfflineKubernetesClient(objects, nil, &common.Config{})
+
+	podList, listErr := kubeClient.ListPods(context.Background(), "default", labels.SelectorFromSet(labels.Set{
+		"app": "test",
+	}))
+
+	assert.Nil(t, listErr)
+	assert.Len(t, podList.Items, 1)
+	assert.Equal(t, "pod1", podList.Items[0].Name)
+
+	// listed pods are copies of the pods in memory
+	podList.Items[0].Labels["app"] = "other"
+
+	assert.Equal(t, "test", objects[0].GetLabels()["app"])
+}
@@ -0,0 +1,142 @@
+package clients
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/*
+TrimPod a cache transform keeping only the parts of a pod the reflector reads:
+metadata, config maps and secrets referenced by volumes and containers and conditions of the status.
+trimmed pods can't be updated as a whole, their metadata is patched instead.
+*/
+func TrimPod(object any) (any, error) {
+	pod, ok := object.(*v1.Pod)
+	if !ok {
+		return object, nil
+	}
+
+	trimmedPod := &v1.Pod{
+		TypeMeta:   pod.TypeMeta,
+		ObjectMeta: pod.ObjectMeta,
+		Spec: v1.PodSpec{
+			Volumes:             trimVolumes(pod.Spec.Volumes),
+			InitContainers:      trimContainers(pod.Spec.InitContainers),
+			Containers:          trimContainers(pod.Spec.Containers),
+			EphemeralContainers: trimEphemeralContainers(pod.Spec.EphemeralContainers),
+		},
+		Status: v1.PodStatus{
+			Conditions: pod.Status.Conditions,
+		},
+	}
+
+	trimmedPod.ManagedFields = nil
+
+	return trimmedPod, nil
+}
+
+/*
+TrimPodController a cache transform keeping only the metadata and the pod selector of replica sets,
+stateful sets and daemon sets, the reflector only reads their selectors to find labels it must not reflect to pods.
+*/
+func TrimPodController(object any) (any, error) {
+	var trimmedController metav1.Object
+
+	switch controller := object.(type) {
+	case *appsv1.ReplicaSet:
+		trimmedController = &appsv1.ReplicaSet{
+			TypeMeta:   controller.TypeMeta,
+			ObjectMeta: controller.ObjectMeta,
+			Spec:       appsv1.ReplicaSetSpec{Selector: controller.Spec.Selector},
+		}
+	case *appsv1.StatefulSet:
+		trimmedController = &appsv1.StatefulSet{
+			TypeMeta:   controller.TypeMeta,
+			ObjectMeta: controller.ObjectMeta,
+			Spec:       appsv1.StatefulSetSpec{Selector: controller.Spec.Selector},
+		}
+	case *appsv1.DaemonSet:
+		trimmedController = &appsv1.DaemonSet{
+			TypeMeta:   controller.TypeMeta,
+			ObjectMeta: controller.ObjectMeta,
+			Spec:       appsv1.DaemonSetSpec{Selector: controller.Spec.Selector},
+		}
+	default:
+		return object, nil
+	}
+
+	trimmedController.SetManagedFields(nil)
+
+	return trimmedController, nil
+}
+
+// keep volumes referencing config maps and secrets.
+func trimVolumes(volumes []v1.Volume) []v1.Volume {
+	var trimmedVolumes []v1.Volume
+
+	for _, volume := range volumes {
+		if volume.ConfigMap == nil && volume.Secret == nil && volume.Projected == nil {
+			continue
+		}
+
+		trimmedVolumes = append(trimmedVolumes, v1.Volume{
+			Name: volume.Name,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: volume.ConfigMap,
+				Secret:    volume.Secret,
+				Projected: volume.Projected,
+			},
+		})
+	}
+
+	return trimmedVolumes
+}
+
+// keep environment variables of containers referencing config maps and secrets.
+func trimContainers(containers []v1.Container) []v1.Container {
+	trimmedContainers := make([]v1.Container, 0, len(containers))
+
+	for _, container := range containers {
+		trimmedContainers = append(trimmedContainers, trimContainer(container))
+	}
+
+	return trimmedContainers
+}
+
+func trimEphemeralContainers(containers []v1.EphemeralContainer) []v1.EphemeralContainer {
+	trimmedContainers := make([]v1.EphemeralContainer, 0, len(containers))
+
+	for _, container := range containers {
+		trimmedContainers = append(trimmedContainers, v1.EphemeralContainer{
+			EphemeralContainerCommon: v1.EphemeralContainerCommon(
+				trimContainer(v1.Container(container.EphemeralContainerCommon))),
+		})
+	}
+
+	return trimmedContainers
+}
+
+func trimContainer(container v1.Container) v1.Container {
+	var env []v1.EnvVar
+
+	for _, envVar := range container.Env {
+		if envVar.ValueFrom == nil || (envVar.ValueFrom.ConfigMapKeyRef == nil && envVar.ValueFrom.SecretKeyRef == nil) {
+			continue
+		}
+
+		env = append(env, v1.EnvVar{
+			Name: envVar.Name,
+			ValueFrom: &v1.EnvVarSource{
+				ConfigMapKeyRef: envVar.ValueFrom.ConfigMapKeyRef,
+				SecretKeyRef:    envVar.ValueFrom.SecretKeyRef,
+			},
+		})
+	}
+
+	return v1.Container{
+		Name:    container.Name,
+		EnvFrom: container.EnvFrom,
+		Env:     env,
+	}
+}
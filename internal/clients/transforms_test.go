@@ -0,0 +1,145 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTrimPod(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "test-pod",
+			Namespace:     "default",
+			Labels:        map[string]string{"app": "test"},
+			Annotations:   map[string]string{"example.com/config": "value"},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubelet"}},
+		},
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{
+				{Name: "config", VolumeSource: v1.VolumeSource{
+					ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "config"}},
+				}},
+				{Name: "cache", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+			},
+			Containers: []v1.Container{
+				{
+					Name:    "app",
+					Image:   "app:latest",
+					Command: []string{"/app"},
+					EnvFrom: []v1.EnvFromSource{
+						{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "credentials"}}},
+					},
+					Env: []v1.EnvVar{
+						{Name: "LOG_LEVEL", Value: "debug"},
+						{Name: "TOKEN", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "token"}, Key: "token",
+						}}},
+						{Name: "POD_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{
+							FieldPath: "metadata.name",
+						}}},
+					},
+				},
+			},
+			NodeName: "node1",
+		},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			PodIP:      "10.0.0.1",
+		},
+	}
+
+	trimmed, err := TrimPod(pod)
+
+	assert.Nil(t, err)
+
+	trimmedPod, ok := trimmed.(*v1.Pod)
+	assert.True(t, ok)
+
+	assert.Equal(t, pod.Name, trimmedPod.Name)
+	assert.Equal(t, pod.Labels, trimmedPod.Labels)
+	assert.Equal(t, pod.Annotations, trimmedPod.Annotations)
+	assert.Nil(t, trimmedPod.ManagedFields)
+	assert.Equal(t, pod.Status.Conditions, trimmedPod.Status.Conditions)
+	assert.Empty(t, trimmedPod.Status.PodIP)
+	assert.Empty(t, trimmedPod.Spec.NodeName)
+	assert.Empty(t, trimmedPod.Spec.Containers[0].Image)
+
+	// references of config maps and secrets are kept
+	assert.Equal(t, []v1.Volume{pod.Spec.Volumes[0]}, trimmedPod.Spec.Volumes)
+	assert.Equal(t, pod.Spec.Containers[0].EnvFrom, trimmedPod.Spec.Containers[0].EnvFrom)
+	assert.Equal(t, []v1.EnvVar{pod.Spec.Containers[0].Env[1]}, trimmedPod.Spec.Containers[0].Env)
+}
+
+func TestTrimPod_OtherObject(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"}}
+
+	trimmed, err := TrimPod(deployment)
+
+	assert.Nil(t, err)
+	assert.Same(t, deployment, trimmed)
+}
+
+func TestTrimPodController(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}}
+	objectMeta := metav1.ObjectMeta{
+		Name:          "test",
+		Namespace:     "default",
+		Labels:        map[string]string{"app": "test"},
+		ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kube-controller-manager"}},
+	}
+	template := v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "app:latest"}}}}
+
+	tests := []struct {
+		name       string
+		controller any
+		want       any
+	}{
+		{
+			name: "Replica set",
+			controller: &appsv1.ReplicaSet{
+				ObjectMeta: objectMeta,
+				Spec:       appsv1.ReplicaSetSpec{Selector: selector, Template: template},
+				Status:     appsv1.ReplicaSetStatus{Replicas: 1},
+			},
+			want: &appsv1.ReplicaSet{ObjectMeta: objectMeta, Spec: appsv1.ReplicaSetSpec{Selector: selector}},
+		},
+		{
+			name: "Stateful set",
+			controller: &appsv1.StatefulSet{
+				ObjectMeta: objectMeta,
+				Spec:       appsv1.StatefulSetSpec{Selector: selector, Template: template},
+			},
+			want: &appsv1.StatefulSet{ObjectMeta: objectMeta, Spec: appsv1.StatefulSetSpec{Selector: selector}},
+		},
+		{
+			name: "Daemon set",
+			controller: &appsv1.DaemonSet{
+				ObjectMeta: objectMeta,
+				Spec:       appsv1.DaemonSetSpec{Selector: selector, Template: template},
+			},
+			want: &appsv1.DaemonSet{ObjectMeta: objectMeta, Spec: appsv1.DaemonSetSpec{Selector: selector}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trimmed, err := TrimPodController(tt.controller)
+
+			assert.Nil(t, err)
+
+			tt.want.(metav1.Object).SetManagedFields(nil)
+			assert.Equal(t, tt.want, trimmed)
+		})
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod"}}
+
+	trimmed, err := TrimPodController(pod)
+
+	assert.Nil(t, err)
+	assert.Same(t, pod, trimmed)
+}
@@ -1,19 +1,103 @@
 package common
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
 )
 
+var (
+	ErrInvalidConfig     = errors.New("invalid configuration")
+	ErrInvalidConfigFile = errors.New("invalid configuration file")
+)
+
+const maxPort = 65535
+
+// SourceKind a kind of objects that metadata can be reflected from, configured with SOURCE_KINDS.
+type SourceKind string
+
+const (
+	SourceKindDeployment SourceKind = "Deployment"
+	SourceKindConfigMap  SourceKind = "ConfigMap"
+	SourceKindSecret     SourceKind = "Secret"
+	SourceKindCronJob    SourceKind = "CronJob"
+	SourceKindJob        SourceKind = "Job"
+)
+
+// ConflictPolicy a policy deciding what happens when a target already has a key with a different value
+// that wasn't reflected.
+type ConflictPolicy string
+
+const (
+	ConflictPolicyOverwrite     ConflictPolicy = "overwrite"
+	ConflictPolicySkipIfPresent ConflictPolicy = "skip-if-present"
+	ConflictPolicyFail          ConflictPolicy = "fail"
+)
+
+// InvalidLabelPolicy a policy deciding what happens with labels whose keys or values are not valid.
+type InvalidLabelPolicy string
+
+const (
+	InvalidLabelPolicyReject   InvalidLabelPolicy = "reject"
+	InvalidLabelPolicySanitize InvalidLabelPolicy = "sanitize"
+)
+
+// AnnotationSizePolicy a policy deciding what happens with annotations that don't fit the annotation size limit
+// of a target.
+type AnnotationSizePolicy string
+
+const (
+	AnnotationSizePolicySkip     AnnotationSizePolicy = "skip"
+	AnnotationSizePolicyTruncate AnnotationSizePolicy = "truncate"
+)
+
+func supportedSourceKinds() []SourceKind {
+	return []SourceKind{
+		SourceKindDeployment, SourceKindConfigMap, SourceKindSecret, SourceKindCronJob, SourceKindJob,
+	}
+}
+
+func supportedConflictPolicies() []ConflictPolicy {
+	return []ConflictPolicy{ConflictPolicyOverwrite, ConflictPolicySkipIfPresent, ConflictPolicyFail}
+}
+
+func supportedInvalidLabelPolicies() []InvalidLabelPolicy {
+	return []InvalidLabelPolicy{InvalidLabelPolicyReject, InvalidLabelPolicySanitize}
+}
+
+func supportedAnnotationSizePolicies() []AnnotationSizePolicy {
+	return []AnnotationSizePolicy{AnnotationSizePolicySkip, AnnotationSizePolicyTruncate}
+}
+
 //go:generate go run github.com/g4s8/envdoc@latest -output ../../environments.md -type Config
 type Config struct {
+	// the path of an optional YAML or JSON configuration file, can also be set with the --config flag
+	// keys of the file are names of these environment variables and take precedence over them
+	// the file is watched and reloadable settings take effect without a restart
+	ConfigFile string `env:"CONFIG_FILE" envDefault:""`
 	// the interval of the background propagation task
 	BackgroundReflectionInterval time.Duration `env:"BACKGROUND_REFLECTION_INTERVAL" envDefault:"5m"`
 	// a deployment selector to limit the watched resources
 	// should be provided in this format https://pkg.go.dev/k8s.io/apimachinery/pkg/labels#Parse
 	// if empty, all deployments will match
 	DeploymentSelector string `env:"DEPLOYMENT_SELECTOR" envDefault:""`
+	// a pod selector to limit the cached pods, pods not matching it are never reflected to
+	// should be provided in this format https://pkg.go.dev/k8s.io/apimachinery/pkg/labels#Parse
+	// if empty, all pods are cached
+	PodSelector string `env:"POD_SELECTOR" envDefault:""`
 	// a comma-separated list of namespaces where to watch the deployments
 	// if empty, all namespaces will be watched
 	Namespaces []string `env:"NAMESPACES" envDefault:""`
@@ -21,17 +105,334 @@ type Config struct {
 	PrometheusMetricsPort int `env:"PROMETHEUS_METRICS_PORT" envDefault:"9090"`
 	// the port for health checking
 	HealthCheckPort int `env:"HEALTH_CHECK_PORT" envDefault:"8083"`
+	// whether to serve debug endpoints explaining reconciliation plans on the Prometheus server port
+	// the endpoints expose metadata of sources and targets to anyone who can reach the port
+	EnableDebugEndpoints bool `env:"ENABLE_DEBUG_ENDPOINTS" envDefault:"false"`
 	// whether to enable leader election
 	EnableLeaderElection bool `env:"ENABLE_LEADER_ELECTION" envDefault:"false"`
 	// the number of reconciliations the controller can perform concurrently
 	MaxConcurrentReconciles int `env:"MAX_CONCURRENT_RECONCILES" envDefault:"1"`
+	// the number of requests per second the manager can send to the Kubernetes API, reads and writes alike
+	KubeAPIQPS float32 `env:"KUBE_API_QPS" envDefault:"20"`
+	// the number of requests the manager can send to the Kubernetes API at once above KUBE_API_QPS
+	KubeAPIBurst int `env:"KUBE_API_BURST" envDefault:"30"`
+	// the number of writes per second to targets, shared by all reconciliations
+	// keeps large fan-outs from being throttled by the API priority and fairness of the cluster
+	WriteQPS float32 `env:"WRITE_QPS" envDefault:"10"`
+	// the number of writes to targets that can be sent at once above WRITE_QPS
+	WriteBurst int `env:"WRITE_BURST" envDefault:"20"`
+	// the number of writes to targets a single reconciliation can perform
+	// the reconciliation is requeued to continue with the remaining targets once the budget is spent
+	// if 0, reconciliations are not limited
+	MaxWritesPerReconcile int `env:"MAX_WRITES_PER_RECONCILE" envDefault:"0"`
+	// the number of targets a single reconciliation can write concurrently
+	// writes of all reconciliations are still limited by WRITE_QPS and WRITE_BURST
+	MaxConcurrentTargetWrites int `env:"MAX_CONCURRENT_TARGET_WRITES" envDefault:"1"`
 	// the log level (debug, info, warn, error)
 	LogLevel string `env:"LOG_LEVEL" envDefault:"info"`
+	// a comma-separated list of kinds to reflect metadata from (Deployment, ConfigMap, Secret, CronJob, Job)
+	// metadata of ConfigMaps and Secrets is reflected to pods referencing them
+	// metadata of CronJobs is reflected to running Jobs spawned by them and their pods
+	SourceKinds []string `env:"SOURCE_KINDS" envDefault:"Deployment"`
+	// what to do when a target already has a key with a different value that wasn't set by the reflector
+	// overwrite - set the value anyway, skip-if-present - keep the target value, fail - leave the target untouched
+	// can be overridden per source with the metadata-reflector.spaceship.com/conflict-policy annotation
+	ConflictPolicy string `env:"CONFLICT_POLICY" envDefault:"overwrite"`
+	// a comma-separated list of regular expressions of label and annotation keys the reflector may write
+	// if empty, all keys are allowed
+	AllowedKeys []string `env:"ALLOWED_KEYS" envDefault:""`
+	// a comma-separated list of regular expressions of label and annotation keys the reflector never writes
+	// takes precedence over ALLOWED_KEYS, e.g. pod-security\.kubernetes\.io/.*,istio\.io/rev
+	DeniedKeys []string `env:"DENIED_KEYS" envDefault:""`
+	// what to do with labels whose keys or values are not valid Kubernetes labels
+	// reject - don't reflect them and fail the reconciliation once valid labels are reflected
+	// sanitize - make values valid by replacing illegal characters and truncating them with a hash suffix
+	// invalid keys are always rejected
+	InvalidLabelPolicy string `env:"INVALID_LABEL_POLICY" envDefault:"reject"`
+	// what to do with reflected annotations that would exceed the 256KiB total annotation size of a target
+	// skip - don't reflect the largest annotations until the rest fits, truncate - truncate their values to fit
+	AnnotationSizePolicy string `env:"ANNOTATION_SIZE_POLICY" envDefault:"skip"`
+	// whether to reflect metadata to Services whose selector matches the pod template of the source
+	EnableServiceReflection bool `env:"ENABLE_SERVICE_REFLECTION" envDefault:"false"`
+	// whether to reflect metadata to EndpointSlices of the matching Services
+	EnableEndpointSliceReflection bool `env:"ENABLE_ENDPOINT_SLICE_REFLECTION" envDefault:"false"`
+	// whether to write the status of reflection to sources in the metadata-reflector.spaceship.com/status annotation
+	// existing status annotations are removed when it's disabled
+	EnableSourceStatus bool `env:"ENABLE_SOURCE_STATUS" envDefault:"false"`
+	// the minimum interval between writes of a status that only refresh its last reconcile time
+	// changes of the status are written right away
+	SourceStatusInterval time.Duration `env:"SOURCE_STATUS_INTERVAL" envDefault:"5m"`
 }
 
-func NewConfig() *Config {
+/*
+NewConfig parse the configuration from environment variables and the configuration file, if any.
+the path of the file is taken from CONFIG_FILE when configFile is empty.
+the configuration is validated, so errors are returned instead of failing later at runtime.
+*/
+func NewConfig(configFile string) (*Config, error) {
+	environment := env.ToMap(os.Environ())
+
+	if configFile == "" {
+		configFile = environment["CONFIG_FILE"]
+	}
+
+	if configFile != "" {
+		fileEnvironment, fileErr := readConfigFile(configFile)
+		if fileErr != nil {
+			return nil, fileErr
+		}
+
+		maps.Copy(environment, fileEnvironment)
+	}
+
 	config := &Config{}
-	Must(env.Parse(config))
 
-	return config
+	if parseErr := env.ParseWithOptions(config, env.Options{Environment: environment}); parseErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, readableParseError(parseErr))
+	}
+
+	config.ConfigFile = configFile
+
+	if validationErr := config.Validate(); validationErr != nil {
+		return nil, validationErr
+	}
+
+	return config, nil
+}
+
+// Current the configuration itself, a static configuration never changes.
+func (c *Config) Current() *Config {
+	return c
+}
+
+// Validate check settings that would otherwise only fail once they are used, all problems are reported at once.
+func (c *Config) Validate() error {
+	var validationErrors []error
+
+	if c.BackgroundReflectionInterval <= 0 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("BACKGROUND_REFLECTION_INTERVAL should be positive, got %s", c.BackgroundReflectionInterval))
+	}
+
+	if c.SourceStatusInterval < 0 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("SOURCE_STATUS_INTERVAL should not be negative, got %s", c.SourceStatusInterval))
+	}
+
+	if _, selectorErr := labels.Parse(c.DeploymentSelector); selectorErr != nil {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("DEPLOYMENT_SELECTOR %q cannot be parsed: %w", c.DeploymentSelector, selectorErr))
+	}
+
+	if _, selectorErr := labels.Parse(c.PodSelector); selectorErr != nil {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("POD_SELECTOR %q cannot be parsed: %w", c.PodSelector, selectorErr))
+	}
+
+	if c.PrometheusMetricsPort < 1 || c.PrometheusMetricsPort > maxPort {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("PROMETHEUS_METRICS_PORT should be between 1 and %d, got %d", maxPort, c.PrometheusMetricsPort))
+	}
+
+	if c.HealthCheckPort < 1 || c.HealthCheckPort > maxPort {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("HEALTH_CHECK_PORT should be between 1 and %d, got %d", maxPort, c.HealthCheckPort))
+	}
+
+	if c.MaxConcurrentReconciles < 1 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("MAX_CONCURRENT_RECONCILES should be at least 1, got %d", c.MaxConcurrentReconciles))
+	}
+
+	validationErrors = append(validationErrors, c.validateRateLimits()...)
+
+	for _, pattern := range slices.Concat(c.AllowedKeys, c.DeniedKeys) {
+		if _, patternErr := regexp.Compile(ExactMatchRegex(pattern)); patternErr != nil {
+			validationErrors = append(validationErrors,
+				fmt.Errorf("key pattern %q cannot be compiled: %w", pattern, patternErr))
+		}
+	}
+
+	validationErrors = append(validationErrors, c.validatePolicies()...)
+
+	if _, levelErr := zapcore.ParseLevel(c.LogLevel); levelErr != nil {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("LOG_LEVEL %q is not one of debug, info, warn, error", c.LogLevel))
+	}
+
+	if len(validationErrors) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", ErrInvalidConfig, errors.Join(validationErrors...))
+}
+
+// check that source kinds and policies are supported.
+func (c *Config) validatePolicies() []error {
+	var validationErrors []error
+
+	if len(c.SourceKinds) == 0 {
+		validationErrors = append(validationErrors, errors.New("SOURCE_KINDS should list at least one kind"))
+	}
+
+	for _, kind := range c.SourceKinds {
+		validationErrors = appendUnsupported(validationErrors, "SOURCE_KINDS", kind, supportedSourceKinds())
+	}
+
+	validationErrors = appendUnsupported(validationErrors, "CONFLICT_POLICY", c.ConflictPolicy,
+		supportedConflictPolicies())
+	validationErrors = appendUnsupported(validationErrors, "INVALID_LABEL_POLICY", c.InvalidLabelPolicy,
+		supportedInvalidLabelPolicies())
+	validationErrors = appendUnsupported(validationErrors, "ANNOTATION_SIZE_POLICY", c.AnnotationSizePolicy,
+		supportedAnnotationSizePolicies())
+
+	return validationErrors
+}
+
+// append an error when the value of the setting is not one of the supported values.
+func appendUnsupported[T ~string](validationErrors []error, setting string, value string, supported []T) []error {
+	if slices.Contains(supported, T(value)) {
+		return validationErrors
+	}
+
+	supportedValues := make([]string, 0, len(supported))
+	for _, supportedValue := range supported {
+		supportedValues = append(supportedValues, string(supportedValue))
+	}
+
+	return append(validationErrors, fmt.Errorf("%s %q is not one of %s",
+		setting, value, strings.Join(supportedValues, ", ")))
+}
+
+// check limits of requests to the Kubernetes API.
+func (c *Config) validateRateLimits() []error {
+	var validationErrors []error
+
+	if c.KubeAPIQPS <= 0 || c.KubeAPIBurst < 1 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("KUBE_API_QPS and KUBE_API_BURST should be positive, got %g and %d", c.KubeAPIQPS, c.KubeAPIBurst))
+	}
+
+	if c.WriteQPS <= 0 || c.WriteBurst < 1 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("WRITE_QPS and WRITE_BURST should be positive, got %g and %d", c.WriteQPS, c.WriteBurst))
+	}
+
+	if c.MaxConcurrentTargetWrites < 1 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("MAX_CONCURRENT_TARGET_WRITES should be at least 1, got %d", c.MaxConcurrentTargetWrites))
+	}
+
+	if c.MaxWritesPerReconcile < 0 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("MAX_WRITES_PER_RECONCILE should not be negative, got %d", c.MaxWritesPerReconcile))
+	}
+
+	return validationErrors
+}
+
+// name settings that cannot be parsed after their environment variables rather than fields of the configuration.
+func readableParseError(parseErr error) error {
+	var aggregateErr env.AggregateError
+	if !errors.As(parseErr, &aggregateErr) {
+		return parseErr
+	}
+
+	readableErrors := make([]error, 0, len(aggregateErr.Errors))
+
+	for _, fieldErr := range aggregateErr.Errors {
+		var fieldParseErr env.ParseError
+		if !errors.As(fieldErr, &fieldParseErr) {
+			readableErrors = append(readableErrors, fieldErr)
+
+			continue
+		}
+
+		field, ok := reflect.TypeFor[Config]().FieldByName(fieldParseErr.Name)
+		if !ok {
+			readableErrors = append(readableErrors, fieldErr)
+
+			continue
+		}
+
+		readableErrors = append(readableErrors,
+			fmt.Errorf("%s cannot be parsed as %s: %w", field.Tag.Get("env"), fieldParseErr.Type, fieldParseErr.Err))
+	}
+
+	return errors.Join(readableErrors...)
+}
+
+/*
+read the configuration file as a map of environment variables.
+lists are joined with commas and other scalars are formatted as strings, so they are parsed like environment variables.
+*/
+func readConfigFile(configFile string) (map[string]string, error) {
+	content, readErr := os.ReadFile(configFile)
+	if readErr != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidConfigFile, configFile, readErr)
+	}
+
+	// YAML is a superset of JSON, so both formats are parsed the same way
+	var values map[string]any
+	if unmarshalErr := yaml.Unmarshal(content, &values); unmarshalErr != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidConfigFile, configFile, unmarshalErr)
+	}
+
+	fieldParams, fieldParamsErr := env.GetFieldParams(&Config{})
+	if fieldParamsErr != nil {
+		return nil, fieldParamsErr
+	}
+
+	knownKeys := make([]string, 0, len(fieldParams))
+	for _, fieldParam := range fieldParams {
+		knownKeys = append(knownKeys, fieldParam.Key)
+	}
+
+	environment := make(map[string]string, len(values))
+
+	for key, value := range values {
+		if !slices.Contains(knownKeys, key) {
+			return nil, fmt.Errorf("%w %s: unknown key %q", ErrInvalidConfigFile, configFile, key)
+		}
+
+		formattedValue, formatErr := formatConfigValue(value)
+		if formatErr != nil {
+			return nil, fmt.Errorf("%w %s: key %q: %w", ErrInvalidConfigFile, configFile, key, formatErr)
+		}
+
+		environment[key] = formattedValue
+	}
+
+	return environment, nil
+}
+
+// format a value of the configuration file the way it would be set in an environment variable.
+func formatConfigValue(value any) (string, error) {
+	switch typedValue := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return typedValue, nil
+	case bool:
+		return strconv.FormatBool(typedValue), nil
+	case float64:
+		return strconv.FormatFloat(typedValue, 'f', -1, 64), nil
+	case []any:
+		items := make([]string, 0, len(typedValue))
+
+		for _, item := range typedValue {
+			formattedItem, formatErr := formatConfigValue(item)
+			if formatErr != nil {
+				return "", formatErr
+			}
+
+			items = append(items, formattedItem)
+		}
+
+		return strings.Join(items, ","), nil
+	default:
+		encodedValue, _ := json.Marshal(typedValue)
+
+		return "", fmt.Errorf("unsupported value %s", encodedValue)
+	}
 }
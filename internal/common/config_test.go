@@ -2,27 +2,185 @@ package common
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	configFile := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(configFile, []byte(content), 0o600))
+
+	return configFile
+}
+
 func TestNewConfig_SuccessfulParse(t *testing.T) {
 	deploymentSelector := "app=test"
 
-	os.Setenv("DEPLOYMENT_SELECTOR", deploymentSelector)
-	defer os.Unsetenv("DEPLOYMENT_SELECTOR")
+	t.Setenv("DEPLOYMENT_SELECTOR", deploymentSelector)
 
-	config := NewConfig()
+	config, err := NewConfig("")
 
+	assert.Nil(t, err)
 	assert.Equal(t, config.DeploymentSelector, deploymentSelector)
 }
 
 func TestNewConfig_UnsuccessfulParse(t *testing.T) {
 	invalidPrometheusPort := "nine-thousand-ninety"
 
-	os.Setenv("PROMETHEUS_METRICS_PORT", invalidPrometheusPort)
-	defer os.Unsetenv("PROMETHEUS_METRICS_PORT")
+	t.Setenv("PROMETHEUS_METRICS_PORT", invalidPrometheusPort)
+
+	config, err := NewConfig("")
+
+	assert.Nil(t, config)
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, "PROMETHEUS_METRICS_PORT")
+}
+
+func TestNewConfig_InvalidSettings(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("DEPLOYMENT_SELECTOR", "app in (")
+	t.Setenv("POD_SELECTOR", "tier notin")
+	t.Setenv("MAX_CONCURRENT_RECONCILES", "0")
+	t.Setenv("DENIED_KEYS", `istio\.io/rev,pod-security\.kubernetes\.io/(`)
+	t.Setenv("INVALID_LABEL_POLICY", "truncate")
+	t.Setenv("ANNOTATION_SIZE_POLICY", "fail")
+	t.Setenv("WRITE_QPS", "0")
+	t.Setenv("MAX_WRITES_PER_RECONCILE", "-1")
+	t.Setenv("MAX_CONCURRENT_TARGET_WRITES", "0")
+	t.Setenv("SOURCE_STATUS_INTERVAL", "-1m")
+	t.Setenv("CONFLICT_POLICY", "merge")
+	t.Setenv("SOURCE_KINDS", "Deployment,StatefulSet")
+
+	config, err := NewConfig("")
+
+	// all problems are reported at once
+	assert.Nil(t, config)
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, `LOG_LEVEL "verbose"`)
+	assert.ErrorContains(t, err, `DEPLOYMENT_SELECTOR "app in ("`)
+	assert.ErrorContains(t, err, `POD_SELECTOR "tier notin"`)
+	assert.ErrorContains(t, err, "MAX_CONCURRENT_RECONCILES should be at least 1")
+	assert.ErrorContains(t, err, `key pattern "pod-security\\.kubernetes\\.io/(" cannot be compiled`)
+	assert.ErrorContains(t, err, `INVALID_LABEL_POLICY "truncate" is not one of reject, sanitize`)
+	assert.ErrorContains(t, err, `ANNOTATION_SIZE_POLICY "fail" is not one of skip, truncate`)
+	assert.ErrorContains(t, err, "WRITE_QPS and WRITE_BURST should be positive, got 0 and 20")
+	assert.ErrorContains(t, err, "MAX_WRITES_PER_RECONCILE should not be negative")
+	assert.ErrorContains(t, err, "MAX_CONCURRENT_TARGET_WRITES should be at least 1")
+	assert.ErrorContains(t, err, "SOURCE_STATUS_INTERVAL should not be negative")
+	assert.ErrorContains(t, err, `CONFLICT_POLICY "merge" is not one of overwrite, skip-if-present, fail`)
+	assert.ErrorContains(t, err, `SOURCE_KINDS "StatefulSet" is not one of Deployment, ConfigMap, Secret, CronJob, Job`)
+}
+
+func TestNewConfig_ConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+	}{
+		{
+			name:     "YAML",
+			fileName: "config.yaml",
+			content: `
+BACKGROUND_REFLECTION_INTERVAL: 1m
+NAMESPACES:
+  - default
+  - payments
+MAX_CONCURRENT_RECONCILES: 4
+ENABLE_SERVICE_REFLECTION: true
+`,
+		},
+		{
+			name:     "JSON",
+			fileName: "config.json",
+			content: `{"BACKGROUND_REFLECTION_INTERVAL": "1m", "NAMESPACES": "default,payments",
+				"MAX_CONCURRENT_RECONCILES": 4, "ENABLE_SERVICE_REFLECTION": true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_CONCURRENT_RECONCILES", "2")
+			t.Setenv("LOG_LEVEL", "debug")
+
+			configFile := writeConfigFile(t, tt.fileName, tt.content)
+
+			config, err := NewConfig(configFile)
+
+			// the file takes precedence over environment variables
+			assert.Nil(t, err)
+			assert.Equal(t, configFile, config.ConfigFile)
+			assert.Equal(t, time.Minute, config.BackgroundReflectionInterval)
+			assert.Equal(t, []string{"default", "payments"}, config.Namespaces)
+			assert.Equal(t, 4, config.MaxConcurrentReconciles)
+			assert.True(t, config.EnableServiceReflection)
+			assert.Equal(t, "debug", config.LogLevel)
+		})
+	}
+}
+
+func TestNewConfig_ConfigFileFromEnvironment(t *testing.T) {
+	configFile := writeConfigFile(t, "config.yaml", "LOG_LEVEL: warn")
+
+	t.Setenv("CONFIG_FILE", configFile)
+
+	config, err := NewConfig("")
+
+	assert.Nil(t, err)
+	assert.Equal(t, configFile, config.ConfigFile)
+	assert.Equal(t, "warn", config.LogLevel)
+}
+
+func TestNewConfig_InvalidConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name:    "Unparsable file",
+			content: "LOG_LEVEL: [debug",
+			wantErr: ErrInvalidConfigFile,
+		},
+		{
+			name:    "Unknown key",
+			content: "LOG_LVL: debug",
+			wantErr: ErrInvalidConfigFile,
+			wantMsg: `unknown key "LOG_LVL"`,
+		},
+		{
+			name:    "Unsupported value",
+			content: "NAMESPACES:\n  default: true",
+			wantErr: ErrInvalidConfigFile,
+			wantMsg: `key "NAMESPACES"`,
+		},
+		{
+			name:    "Invalid setting",
+			content: "BACKGROUND_REFLECTION_INTERVAL: 0s",
+			wantErr: ErrInvalidConfig,
+			wantMsg: "BACKGROUND_REFLECTION_INTERVAL should be positive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := writeConfigFile(t, "config.yaml", tt.content)
+
+			config, err := NewConfig(configFile)
+
+			assert.Nil(t, config)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.ErrorContains(t, err, tt.wantMsg)
+		})
+	}
+}
+
+func TestNewConfig_MissingConfigFile(t *testing.T) {
+	config, err := NewConfig(filepath.Join(t.TempDir(), "config.yaml"))
 
-	assert.Panics(t, func() { NewConfig() })
+	assert.Nil(t, config)
+	assert.ErrorIs(t, err, ErrInvalidConfigFile)
 }
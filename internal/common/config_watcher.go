@@ -0,0 +1,180 @@
+package common
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// ConfigProvider provides the current configuration, which changes when the configuration file is reloaded.
+type ConfigProvider interface {
+	Current() *Config
+}
+
+/*
+ConfigWatcher watches the configuration file and reloads the configuration when it changes.
+an invalid file is reported and the last valid configuration is kept.
+settings used to build the manager are only read on startup, their changes are reported and ignored.
+*/
+type ConfigWatcher struct {
+	logger    logr.Logger
+	current   atomic.Pointer[Config]
+	callbacks []func(*Config)
+}
+
+func NewConfigWatcher(config *Config, logger logr.Logger) *ConfigWatcher {
+	watcher := &ConfigWatcher{logger: logger}
+	watcher.current.Store(config)
+
+	return watcher
+}
+
+// Current the last valid configuration.
+func (w *ConfigWatcher) Current() *Config {
+	return w.current.Load()
+}
+
+// OnReload register a callback called with the new configuration after every reload, e.g. to change the log level.
+// callbacks should be registered before the watcher is started.
+func (w *ConfigWatcher) OnReload(callback func(*Config)) {
+	w.callbacks = append(w.callbacks, callback)
+}
+
+// NeedLeaderElection the configuration is reloaded on every replica, not only on the leader.
+func (w *ConfigWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// reloadDelay a delay to wait for further changes before the file is reloaded,
+// so a file that is being written, e.g. truncated but not written yet, isn't loaded.
+const reloadDelay = 100 * time.Millisecond
+
+/*
+Start watch the configuration file until the context is done.
+the directory of the file is watched rather than the file itself, as the file can be replaced,
+e.g. a mounted ConfigMap is updated by swapping a symlink.
+*/
+func (w *ConfigWatcher) Start(ctx context.Context) error {
+	configFile := w.Current().ConfigFile
+	if configFile == "" {
+		return nil
+	}
+
+	fileWatcher, watcherErr := fsnotify.NewWatcher()
+	if watcherErr != nil {
+		return watcherErr
+	}
+	defer fileWatcher.Close()
+
+	if addErr := fileWatcher.Add(filepath.Dir(configFile)); addErr != nil {
+		return addErr
+	}
+
+	w.logger.Info("Watching configuration file", "file", configFile)
+
+	reloadTimer := time.NewTimer(reloadDelay)
+	reloadTimer.Stop()
+
+	defer reloadTimer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-fileWatcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if !event.Has(fsnotify.Chmod) {
+				reloadTimer.Reset(reloadDelay)
+			}
+		case <-reloadTimer.C:
+			w.reload()
+		case watchErr, ok := <-fileWatcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			w.logger.Error(watchErr, "Failed to watch configuration file", "file", configFile)
+		}
+	}
+}
+
+// reload the configuration file, the current configuration is kept when the file is invalid or unchanged.
+func (w *ConfigWatcher) reload() {
+	currentConfig := w.Current()
+
+	newConfig, configErr := NewConfig(currentConfig.ConfigFile)
+	if configErr != nil {
+		w.logger.Error(configErr, "Failed to reload configuration, keeping the current one",
+			"file", currentConfig.ConfigFile)
+
+		return
+	}
+
+	if ignoredSettings := keepStartupSettings(currentConfig, newConfig); len(ignoredSettings) > 0 {
+		w.logger.Info("Changed settings require a restart to take effect", "settings", ignoredSettings)
+	}
+
+	if reflect.DeepEqual(currentConfig, newConfig) {
+		return
+	}
+
+	w.current.Store(newConfig)
+	w.logger.Info("Configuration reloaded", "file", newConfig.ConfigFile)
+
+	for _, callback := range w.callbacks {
+		callback(newConfig)
+	}
+}
+
+/*
+keep settings that are only read on startup, when the manager and its caches are created.
+returns names of such settings that were changed.
+*/
+func keepStartupSettings(currentConfig *Config, newConfig *Config) []string {
+	var changedSettings []string
+
+	reportChange := func(name string, changed bool) {
+		if changed {
+			changedSettings = append(changedSettings, name)
+		}
+	}
+
+	reportChange("DEPLOYMENT_SELECTOR", currentConfig.DeploymentSelector != newConfig.DeploymentSelector)
+	reportChange("POD_SELECTOR", currentConfig.PodSelector != newConfig.PodSelector)
+	reportChange("NAMESPACES", !slices.Equal(currentConfig.Namespaces, newConfig.Namespaces))
+	reportChange("PROMETHEUS_METRICS_PORT", currentConfig.PrometheusMetricsPort != newConfig.PrometheusMetricsPort)
+	reportChange("HEALTH_CHECK_PORT", currentConfig.HealthCheckPort != newConfig.HealthCheckPort)
+	reportChange("ENABLE_DEBUG_ENDPOINTS", currentConfig.EnableDebugEndpoints != newConfig.EnableDebugEndpoints)
+	reportChange("ENABLE_LEADER_ELECTION", currentConfig.EnableLeaderElection != newConfig.EnableLeaderElection)
+	reportChange("MAX_CONCURRENT_RECONCILES", currentConfig.MaxConcurrentReconciles != newConfig.MaxConcurrentReconciles)
+	reportChange("KUBE_API_QPS", currentConfig.KubeAPIQPS != newConfig.KubeAPIQPS)
+	reportChange("KUBE_API_BURST", currentConfig.KubeAPIBurst != newConfig.KubeAPIBurst)
+	reportChange("WRITE_QPS", currentConfig.WriteQPS != newConfig.WriteQPS)
+	reportChange("WRITE_BURST", currentConfig.WriteBurst != newConfig.WriteBurst)
+	reportChange("SOURCE_KINDS", !slices.Equal(currentConfig.SourceKinds, newConfig.SourceKinds))
+
+	newConfig.DeploymentSelector = currentConfig.DeploymentSelector
+	newConfig.PodSelector = currentConfig.PodSelector
+	newConfig.Namespaces = currentConfig.Namespaces
+	newConfig.PrometheusMetricsPort = currentConfig.PrometheusMetricsPort
+	newConfig.HealthCheckPort = currentConfig.HealthCheckPort
+	newConfig.EnableDebugEndpoints = currentConfig.EnableDebugEndpoints
+	newConfig.EnableLeaderElection = currentConfig.EnableLeaderElection
+	newConfig.MaxConcurrentReconciles = currentConfig.MaxConcurrentReconciles
+	newConfig.KubeAPIQPS = currentConfig.KubeAPIQPS
+	newConfig.KubeAPIBurst = currentConfig.KubeAPIBurst
+	newConfig.WriteQPS = currentConfig.WriteQPS
+	newConfig.WriteBurst = currentConfig.WriteBurst
+	newConfig.SourceKinds = currentConfig.SourceKinds
+
+	return changedSettings
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestConfigWatcher_Start(t *testing.T) {
+	configFile := writeConfigFile(t, "config.yaml", "LOG_LEVEL: info\nNAMESPACES: default")
+
+	config, configErr := NewConfig(configFile)
+	assert.Nil(t, configErr)
+
+	watcher := NewConfigWatcher(config, zap.New())
+
+	var reloads atomic.Int32
+
+	watcher.OnReload(func(*Config) { reloads.Add(1) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcherDone := make(chan error)
+
+	go func() { watcherDone <- watcher.Start(ctx) }()
+
+	// give the watcher some time to start watching the directory
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Nil(t, os.WriteFile(configFile, []byte("LOG_LEVEL: debug\nNAMESPACES: payments"), 0o600))
+
+	assert.Eventually(t, func() bool {
+		return watcher.Current().LogLevel == "debug"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// settings read on startup are kept
+	assert.Equal(t, []string{"default"}, watcher.Current().Namespaces)
+	assert.Positive(t, reloads.Load())
+
+	// an invalid file is ignored
+	assert.Nil(t, os.WriteFile(configFile, []byte("LOG_LEVEL: verbose"), 0o600))
+	time.Sleep(3 * reloadDelay)
+
+	assert.Equal(t, "debug", watcher.Current().LogLevel)
+
+	cancel()
+	assert.Nil(t, <-watcherDone)
+}
+
+func TestConfigWatcher_StartWithoutConfigFile(t *testing.T) {
+	watcher := NewConfigWatcher(&Config{}, zap.New())
+
+	assert.Nil(t, watcher.Start(context.Background()))
+	assert.False(t, watcher.NeedLeaderElection())
+}
+
+func TestConfigWatcher_reload(t *testing.T) {
+	configFile := writeConfigFile(t, "config.yaml", "BACKGROUND_REFLECTION_INTERVAL: 5m\nSOURCE_KINDS: Deployment")
+
+	config, configErr := NewConfig(configFile)
+	assert.Nil(t, configErr)
+
+	watcher := NewConfigWatcher(config, zap.New())
+
+	var reloadedConfig *Config
+
+	watcher.OnReload(func(newConfig *Config) { reloadedConfig = newConfig })
+
+	// an unchanged file doesn't trigger callbacks
+	watcher.reload()
+	assert.Nil(t, reloadedConfig)
+
+	assert.Nil(t, os.WriteFile(configFile,
+		[]byte("BACKGROUND_REFLECTION_INTERVAL: 1m\nSOURCE_KINDS: Deployment,ConfigMap\n"+
+			"WRITE_QPS: 50\nMAX_WRITES_PER_RECONCILE: 100"), 0o600))
+
+	watcher.reload()
+
+	assert.Same(t, watcher.Current(), reloadedConfig)
+	assert.Equal(t, time.Minute, watcher.Current().BackgroundReflectionInterval)
+	assert.Equal(t, []string{"Deployment"}, watcher.Current().SourceKinds)
+	assert.Equal(t, float32(10), watcher.Current().WriteQPS)
+	assert.Equal(t, 100, watcher.Current().MaxWritesPerReconcile)
+	assert.Equal(t, 5*time.Minute, config.BackgroundReflectionInterval, "The previous configuration is not mutated.")
+}
@@ -1,157 +1,188 @@
 package reflector
 
 import (
-	"context"
 	"maps"
 	"strings"
 
 	"github.com/NCCloud/metadata-reflector/internal/common"
 	"github.com/hashicorp/go-multierror"
-	appsv1 "k8s.io/api/apps/v1"
-	v1 "k8s.io/api/core/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *Controller) reconcileAnnotations(ctx context.Context, deployment *appsv1.Deployment) (ctrl.Result, error) {
+// plan annotations of the targets of the source.
+func (r *Controller) reconcileAnnotations(source client.Object, plan *reflectionPlan) error {
 	r.logger.V(1).Info("Starting annotation reconciliation",
-		"deployment", deployment.Name, "namespace", deployment.Namespace)
+		"kind", sourceKind(source), "source", source.GetName(), "namespace", source.GetNamespace())
 	defer r.logger.V(1).Info("Finished annotation reconciliation",
-		"deployment", deployment.Name, "namespace", deployment.Namespace)
+		"kind", sourceKind(source), "source", source.GetName(), "namespace", source.GetNamespace())
 
-	var (
-		annotationReflectResult ctrl.Result
-		annotationReflectError  error
-	)
-
-	if common.MapHasPrefix(ReflectorAnnotationsAnnotationDomain, deployment.Annotations) {
-		annotationReflectResult, annotationReflectError = r.reflectAnnotations(ctx, deployment)
-	} else {
-		annotationReflectResult, annotationReflectError = r.unsetReflectedAnnotations(ctx, deployment)
+	if len(r.findReflectorAnnotations(ReflectorAnnotationsAnnotationDomain, source)) > 0 {
+		return r.reflectAnnotations(source, plan)
 	}
 
-	return annotationReflectResult, annotationReflectError
+	return r.unsetReflectedAnnotations(source, plan)
 }
 
-// reflect configuration from deployment to managed pods.
-func (r *Controller) reflectAnnotations(ctx context.Context, deployment *appsv1.Deployment,
-) (ctrl.Result, error) {
-	deploymentName := deployment.Name
+// reflect configuration from the source to targets of the plan.
+func (r *Controller) reflectAnnotations(source client.Object, plan *reflectionPlan) error {
+	sourceName := source.GetName()
 
 	// a map of reflector annotations present on the object
-	reflectorAnnotations := common.FindPartialKeys(
-		ReflectorAnnotationsAnnotationDomain, deployment.Annotations)
+	reflectorAnnotations := r.findReflectorAnnotations(ReflectorAnnotationsAnnotationDomain, source)
 
 	annotationsToReflect, annotationsErr := r.keysToReflect(
-		reflectorAnnotations, deployment.Annotations)
+		reflectorAnnotations, source.GetAnnotations())
 	if annotationsErr != nil {
 		r.logger.Error(
 			annotationsErr, "Could not get annotations to reflect",
-			"deployment", deploymentName,
+			"kind", sourceKind(source), "source", sourceName,
 		)
 
-		return ctrl.Result{}, annotationsErr
+		return annotationsErr
 	}
 
+	dropReflectorAnnotations(annotationsToReflect)
+
+	annotationsToReflect = r.dropBlockedKeys(source, "annotations", annotationsToReflect)
+
 	// nothing to reflect, let's try to unset reflected annotations
 	if len(annotationsToReflect) == 0 {
-		return r.unsetReflectedAnnotations(ctx, deployment)
+		return r.unsetReflectedAnnotations(source, plan)
 	}
 
-	specialReflectorAnn := r.getReflectorAnnForAnnotations(common.MapKeysAsString(annotationsToReflect))
-
-	maps.Copy(annotationsToReflect, specialReflectorAnn)
-
-	pods, podListError := r.getManagedPods(ctx, deployment)
-	if podListError != nil {
-		r.logger.Error(podListError,
-			"Error listing pods for deployment",
-			"deployment", deploymentName,
-		)
+	conflictPolicy, conflictPolicyErr := r.getConflictPolicy(source)
+	if conflictPolicyErr != nil {
+		return conflictPolicyErr
+	}
 
-		return ctrl.Result{}, podListError
+	priority, priorityErr := r.getSourcePriority(source)
+	if priorityErr != nil {
+		return priorityErr
 	}
 
-	var podUpdateErrors *multierror.Error
+	var reflectErrors *multierror.Error
 
-	for _, pod := range pods.Items {
-		shouldUpdatePod := false
+	for _, target := range plan.targets {
+		shouldUpdateTarget, conflictErr := r.reflectAnnotationsToTarget(
+			source, target, annotationsToReflect, conflictPolicy, priority)
+		if conflictErr != nil {
+			reflectErrors = multierror.Append(reflectErrors, conflictErr)
 
-		if annotationsUpdated := r.setAnnotations(annotationsToReflect, &pod); annotationsUpdated {
-			shouldUpdatePod = true
+			continue
 		}
 
-		excessiveAnnotationsUnset := r.unsetExcessiveAnnotations(annotationsToReflect, &pod)
-		if excessiveAnnotationsUnset {
-			shouldUpdatePod = true
+		if shouldUpdateTarget {
+			plan.markChanged(target)
 		}
+	}
 
-		if !shouldUpdatePod {
-			continue
-		}
+	return reflectErrors.ErrorOrNil()
+}
 
-		updateErr := r.kubeClient.UpdatePod(ctx, pod)
-		if updateErr != nil {
-			r.logger.Error(updateErr, "Failed to update pod metadata",
-				"pod", pod.Name,
-			)
+/*
+reflect annotations to a single target in memory respecting the conflict policy and the annotation size limit.
+returns whether the target needs to be updated, with the fail policy a conflict
+leaves the target untouched and is returned as an error.
+*/
+func (r *Controller) reflectAnnotationsToTarget(
+	source client.Object, target client.Object, annotationsToReflect map[string]string,
+	conflictPolicy string, priority int,
+) (bool, error) {
+	record, recordErr := r.getOwnershipRecord(
+		source, target, target.GetAnnotations(),
+		ReflectorAnnotationsOwnershipAnnotation, ReflectorAnnotationsReflectedAnnotation)
+	if recordErr != nil {
+		return false, recordErr
+	}
 
-			podUpdateErrors = multierror.Append(podUpdateErrors, updateErr)
+	annotationsToSet, conflicts := r.resolveConflicts(
+		annotationsToReflect, target.GetAnnotations(), record, conflictPolicy)
+	if len(conflicts) > 0 {
+		r.reportConflicts(source, target, "annotations", conflictPolicy, conflicts)
+
+		if conflictPolicy == ConflictPolicyFail {
+			return false, ErrKeyConflict
 		}
 	}
 
-	return ctrl.Result{}, podUpdateErrors.ErrorOrNil()
+	return r.applyAnnotationsWithinBudget(source, target, annotationsToReflect, annotationsToSet, priority)
 }
 
-// generate a map of annotations that help identify what annotations
-// were set by the reflector on the managed object.
-func (r *Controller) getReflectorAnnForAnnotations(labelsToReflect string) map[string]string {
-	annotationsToReflect := make(map[string]string)
-	annotationsToReflect[ReflectorAnnotationsReflectedAnnotation] = labelsToReflect
+// apply annotations of the source to the target in memory, the target is expected to fit the annotation size limit.
+// returns whether the target needs to be updated.
+func (r *Controller) applyAnnotations(
+	source client.Object, target client.Object, annotationsToReflect map[string]string,
+	annotationsToSet map[string]string, priority int,
+) (bool, error) {
+	record, recordErr := r.getOwnershipRecord(
+		source, target, target.GetAnnotations(),
+		ReflectorAnnotationsOwnershipAnnotation, ReflectorAnnotationsReflectedAnnotation)
+	if recordErr != nil {
+		return false, recordErr
+	}
 
-	return annotationsToReflect
+	// annotations the source reflected before but doesn't reflect anymore
+	excessiveAnnotations := common.ExcessiveElements(
+		common.MapKeysAsSlice(annotationsToReflect), record.keysClaimedBy(source.GetUID()))
+	annotationsRestored := r.restoreAnnotations(record, source.GetUID(), excessiveAnnotations, target)
+
+	// prior values are recorded before they are overwritten, the value with the highest precedence is set
+	sourceClaim := KeyClaim{Source: source.GetUID(), Kind: sourceKind(source), Priority: priority}
+	annotationsToClaim := record.claim(sourceClaim, annotationsToSet, target.GetAnnotations())
+	annotationsUpdated := r.setAnnotations(annotationsToClaim, target)
+
+	recordUpdated, recordErr := r.setOwnershipRecord(
+		record, target, ReflectorAnnotationsOwnershipAnnotation, ReflectorAnnotationsReflectedAnnotation)
+
+	return annotationsRestored || annotationsUpdated || recordUpdated, recordErr
 }
 
-// reflect annotation to managed pods.
-// returns whether any pod annotation was updated.
-func (r *Controller) setAnnotations(annotations map[string]string, pod *v1.Pod) bool {
-	podUpdated := false
+// reflect annotation to managed targets.
+// returns whether any target annotation was updated.
+func (r *Controller) setAnnotations(annotations map[string]string, target metav1.Object) bool {
+	targetUpdated := false
 
-	if pod.Annotations == nil {
-		pod.Annotations = make(map[string]string)
+	targetAnnotations := target.GetAnnotations()
+	if targetAnnotations == nil {
+		targetAnnotations = make(map[string]string)
+		target.SetAnnotations(targetAnnotations)
 	}
 
 	for key, value := range annotations {
-		annotationValue, annotationOk := pod.Annotations[key]
+		annotationValue, annotationOk := targetAnnotations[key]
 		if annotationOk && annotationValue == value {
 			continue
 		}
 
-		r.logger.Info("Setting annotation for pod", "pod", pod.Name, "annotation", key, "value", value)
+		r.logger.Info("Setting annotation for target", "target", target.GetName(), "annotation", key, "value", value)
 
-		pod.Annotations[key] = value
-		podUpdated = true
+		targetAnnotations[key] = value
+		targetUpdated = true
 	}
 
-	return podUpdated
+	return targetUpdated
 }
 
-// unset managed annotations from a pod.
+// unset managed annotations from a target.
 // returns whether any annotation was unset.
-func (r *Controller) unsetAnnotations(annotations []string, pod *v1.Pod) bool {
+func (r *Controller) unsetAnnotations(annotations []string, target metav1.Object) bool {
 	anyAnnotationUnset := false
 
-	if pod.Annotations == nil {
+	targetAnnotations := target.GetAnnotations()
+	if targetAnnotations == nil {
 		return anyAnnotationUnset
 	}
 
 	for _, annotation := range annotations {
-		if _, annotationExists := pod.Annotations[annotation]; !annotationExists {
+		if _, annotationExists := targetAnnotations[annotation]; !annotationExists {
 			continue
 		}
 
-		r.logger.Info("Unsetting annotation from pod", "pod", pod.Name, "annotation", annotation)
+		r.logger.Info("Unsetting annotation from target", "target", target.GetName(), "annotation", annotation)
 
-		delete(pod.Annotations, annotation)
+		delete(targetAnnotations, annotation)
 
 		anyAnnotationUnset = true
 	}
@@ -159,77 +190,68 @@ func (r *Controller) unsetAnnotations(annotations []string, pod *v1.Pod) bool {
 	return anyAnnotationUnset
 }
 
-// unset excessive annotations given the annotations we expect to be present on an object.
-// returns if any annotation was unset.
-func (r *Controller) unsetExcessiveAnnotations(annotationsToReflect map[string]string, pod *v1.Pod) bool {
-	var annotationsToUnset []string
-
-	podReflectedAnnValue, podReflectedAnnExists := pod.Annotations[ReflectorAnnotationsReflectedAnnotation]
-	if !podReflectedAnnExists {
-		return false
-	}
-
-	currentKeys := strings.Split(podReflectedAnnValue, ",")
-	expectedKeys := common.MapKeysAsSlice(annotationsToReflect)
-	annotationsToUnset = common.ExcessiveElements(expectedKeys, currentKeys)
+// release annotations claimed by the source, annotations claimed by other sources take the value of the next claim,
+// the rest are restored to the values they had before they were reflected or unset if they weren't present.
+// returns whether any annotation was updated.
+func (r *Controller) restoreAnnotations(
+	record ownershipRecord, sourceUID types.UID, annotations []string, target metav1.Object,
+) bool {
+	valuesToSet, annotationsToUnset := record.release(sourceUID, annotations)
 
-	if len(annotationsToUnset) == 0 {
-		return false
-	}
-
-	if annotationsUpdated := r.unsetAnnotations(annotationsToUnset, pod); annotationsUpdated {
-		return annotationsUpdated
-	}
+	annotationsRestored := len(valuesToSet) > 0 && r.setAnnotations(valuesToSet, target)
+	annotationsUnset := r.unsetAnnotations(annotationsToUnset, target)
 
-	return false
+	return annotationsRestored || annotationsUnset
 }
 
-func (r *Controller) unsetReflectedAnnotations(ctx context.Context, deployment *appsv1.Deployment,
-) (ctrl.Result, error) {
-	deploymentName := deployment.Name
+// restore annotations the source has reflected to targets of the plan.
+func (r *Controller) unsetReflectedAnnotations(source client.Object, plan *reflectionPlan) error {
+	var recordErrors *multierror.Error
 
-	pods, podListError := r.getManagedPods(ctx, deployment)
-	if podListError != nil {
-		r.logger.Error(podListError,
-			"Error listing pods for deployment",
-			"deployment", deploymentName,
-		)
-
-		return ctrl.Result{}, podListError
-	}
+	for _, target := range plan.targets {
+		targetUpdated, recordErr := r.unsetReflectedAnnotationsFromTarget(source, target)
+		if recordErr != nil {
+			recordErrors = multierror.Append(recordErrors, recordErr)
 
-	var podUpdateErrors *multierror.Error
-
-	for _, pod := range pods.Items {
-		annotationValue, hasReflectorAnnotation := pod.Annotations[ReflectorAnnotationsReflectedAnnotation]
-		// if the annotation is not present, configuration is either already unset
-		// or the annotation was deleted manually and we don't know what annotations to remove
-		if !hasReflectorAnnotation {
 			continue
 		}
 
-		shouldUpdatePod := false
+		if targetUpdated {
+			plan.markChanged(target)
+		}
+	}
 
-		annotationsToUnset := strings.Split(annotationValue, ",")
-		annotationsToUnset = append(annotationsToUnset, ReflectorAnnotationsReflectedAnnotation)
+	return recordErrors.ErrorOrNil()
+}
 
-		if annotationsUpdated := r.unsetAnnotations(annotationsToUnset, &pod); annotationsUpdated {
-			shouldUpdatePod = true
-		}
+// restore all annotations the source has reflected to the target and remove them from the ownership record.
+// returns whether the target was updated.
+func (r *Controller) unsetReflectedAnnotationsFromTarget(source client.Object, target client.Object) (bool, error) {
+	// if there is no record, configuration is either already unset
+	// or the record was deleted manually and we don't know what annotations to restore
+	record, recordErr := r.getOwnershipRecord(
+		source, target, target.GetAnnotations(),
+		ReflectorAnnotationsOwnershipAnnotation, ReflectorAnnotationsReflectedAnnotation)
+	if recordErr != nil {
+		return false, recordErr
+	}
 
-		if !shouldUpdatePod {
-			continue
-		}
+	annotationsRestored := r.restoreAnnotations(
+		record, source.GetUID(), record.keysClaimedBy(source.GetUID()), target)
 
-		updateErr := r.kubeClient.UpdatePod(ctx, pod)
-		if updateErr != nil {
-			r.logger.Error(updateErr, "Failed to unset metadata from pod",
-				"pod", pod.Name,
-			)
+	recordUpdated, recordErr := r.setOwnershipRecord(
+		record, target, ReflectorAnnotationsOwnershipAnnotation, ReflectorAnnotationsReflectedAnnotation)
 
-			podUpdateErrors = multierror.Append(podUpdateErrors, updateErr)
-		}
-	}
+	return annotationsRestored || recordUpdated, recordErr
+}
 
-	return ctrl.Result{}, podUpdateErrors.ErrorOrNil()
+/*
+drop annotations of the reflector from annotations to reflect, whatever operation matched them.
+an object can be both a source and a target, e.g. a job of a cron job, so reflecting the configuration
+or the state of the reflector would turn targets into sources or take over their bookkeeping.
+*/
+func dropReflectorAnnotations(annotations map[string]string) {
+	maps.DeleteFunc(annotations, func(annotation string, _ string) bool {
+		return strings.Contains(annotation, ReflectorAnnotationDomain)
+	})
 }
@@ -1,32 +1,33 @@
 package reflector
 
 import (
-	"context"
-	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"testing"
 
 	"github.com/NCCloud/metadata-reflector/internal/common"
 	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 func TestController_reconcileAnnotations(t *testing.T) {
 	type args struct {
 		deployment *appsv1.Deployment
+		pods       []v1.Pod
 	}
 	tests := []struct {
-		name      string
-		args      args
-		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
-		want      ctrl.Result
-		wantErr   bool
+		name        string
+		args        args
+		wantChanged []string
+		wantErr     bool
 	}{
 		{
 			name: "Successful reconciliation: add new annotations",
@@ -40,35 +41,31 @@ func TestController_reconcileAnnotations(t *testing.T) {
 							"annotation1": "value1",
 						},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
+				},
+				pods: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "pod1",
+							Namespace:   "default",
+							Labels:      map[string]string{},
+							Annotations: map[string]string{},
 						},
 					},
-				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:        "pod1",
-									Namespace:   "default",
-									Labels:      map[string]string{},
-									Annotations: map[string]string{},
-								},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod2",
+							Namespace: "default",
+							Annotations: map[string]string{
+								"annotation1": "value1",
+								ReflectorAnnotationsOwnershipAnnotation: `{"annotation1":{"source":"","claims":[` +
+									`{"source":"","kind":"Deployment","value":"value1"}]}}`,
 							},
 						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(nil)
+					},
+				},
 			},
-			want:    ctrl.Result{},
-			wantErr: false,
+			wantChanged: []string{"pod1"},
+			wantErr:     false,
 		},
 		{
 			name: "Successful reconciliation: remove annotations",
@@ -78,37 +75,22 @@ func TestController_reconcileAnnotations(t *testing.T) {
 						Name:      "test-deployment",
 						Namespace: "default",
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
 				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:      "pod1",
-									Namespace: "default",
-									Annotations: map[string]string{
-										fmt.Sprintf("%s/reflected-list", ReflectorAnnotationsAnnotationDomain): "annotation1",
-										"annotation1": "value1",
-									},
-								},
+				pods: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod1",
+							Namespace: "default",
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/reflected-list", ReflectorAnnotationsAnnotationDomain): "annotation1",
+								"annotation1": "value1",
 							},
 						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(nil)
+					},
+				},
 			},
-			want:    ctrl.Result{},
-			wantErr: false,
+			wantChanged: []string{"pod1"},
+			wantErr:     false,
 		},
 		{
 			name: "Failed reconciliation",
@@ -119,88 +101,51 @@ func TestController_reconcileAnnotations(t *testing.T) {
 						Namespace: "default",
 						Annotations: map[string]string{
 							fmt.Sprintf("%s/list", ReflectorAnnotationsAnnotationDomain): "annotation1",
-							"annotation1": "value1",
+							ReflectorConflictPolicyAnnotation:                            ConflictPolicyFail,
+							"annotation1":                                                "value1",
 						},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
+				},
+				pods: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "pod1",
+							Namespace:   "default",
+							Annotations: map[string]string{"annotation1": "other-value"},
 						},
 					},
 				},
 			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:        "pod1",
-									Namespace:   "default",
-									Labels:      map[string]string{},
-									Annotations: map[string]string{},
-								},
-							},
-						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(errors.New("failed"))
-			},
-			want:    ctrl.Result{},
-			wantErr: true,
+			wantChanged: nil,
+			wantErr:     true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := new(mockKubernetesClient.MockKubernetesClient)
-
 			logger := zap.New()
 			config := &common.Config{}
 
 			controller := &Controller{
-				kubeClient: mockClient,
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
 				logger:     logger,
 				config:     config,
+				recorder:   events.NewFakeRecorder(10),
 			}
-			tt.mockSetup(mockClient)
 
-			got, err := controller.reconcileAnnotations(context.Background(), tt.args.deployment)
+			plan := newReflectionPlan(podsAsTargets(&v1.PodList{Items: tt.args.pods}), nil)
+
+			err := controller.reconcileAnnotations(tt.args.deployment, plan)
 			if tt.wantErr {
 				assert.Error(t, err)
-				return
+			} else {
+				assert.Nil(t, err)
 			}
 
-			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantChanged, targetNames(plan.changedTargets))
 		})
 	}
 }
 
-func TestController_getReflectorAnnForAnnotations(t *testing.T) {
-	mockClient := new(mockKubernetesClient.MockKubernetesClient)
-
-	logger := zap.New()
-	config := &common.Config{}
-
-	controller := &Controller{
-		kubeClient: mockClient,
-		logger:     logger,
-		config:     config,
-	}
-
-	annotationsToReflect := "key1=value1,key2=value2"
-	expectedAnnotations := map[string]string{
-		ReflectorAnnotationsReflectedAnnotation: annotationsToReflect,
-	}
-
-	result := controller.getReflectorAnnForAnnotations(annotationsToReflect)
-
-	assert.NotNil(t, result, "resulting annotations should not be nil")
-	assert.Equal(t, expectedAnnotations, result, "annotations should match the expected map")
-}
-
 func TestController_setAnnotations(t *testing.T) {
 	mockClient := new(mockKubernetesClient.MockKubernetesClient)
 
@@ -328,7 +273,7 @@ func TestController_unsetAnnotationsFromPodWithoutAnyExistingOnes(t *testing.T)
 	assert.False(t, annotationsUnset, "annotationsUnset should be false because there are no annotations to unset")
 }
 
-func TestController_unsetExcessiveAnnotations(t *testing.T) {
+func TestController_restoreAnnotations(t *testing.T) {
 	mockClient := new(mockKubernetesClient.MockKubernetesClient)
 
 	logger := zap.New()
@@ -340,27 +285,69 @@ func TestController_unsetExcessiveAnnotations(t *testing.T) {
 		config:     config,
 	}
 
+	priorValue := "injected"
+
 	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-pod",
 			Namespace: "default",
 			Annotations: map[string]string{
-				ReflectorAnnotationsReflectedAnnotation: "annotation1,annotation2,annotation3",
-				"annotation1":                           "value1",
-				"annotation2":                           "value2",
-				"annotation3":                           "value3",
+				"annotation1": "value1",
+				"annotation2": "value2",
+				"annotation3": "value3",
 			},
 		},
 	}
 
-	expectedAnnotations := map[string]string{
-		"annotation1": "value1", // Expected annotation
-		"annotation2": "value2", // Expected annotation
+	record := ownershipRecord{
+		"annotation1": {Source: "deployment-uid", Claims: []KeyClaim{{Source: "deployment-uid", Value: "value1"}}},
+		"annotation2": {
+			Source:     "deployment-uid",
+			PriorValue: &priorValue,
+			Claims:     []KeyClaim{{Source: "deployment-uid", Value: "value2"}},
+		},
+	}
+
+	anyAnnotationUpdated := controller.restoreAnnotations(
+		record, "deployment-uid", []string{"annotation1", "annotation2"}, pod)
+
+	assert.True(t, anyAnnotationUpdated, "Some annotations should be updated.")
+	assert.NotContains(t, pod.Annotations, "annotation1", "Annotation 'annotation1' should be unset.")
+	assert.Equal(t, "injected", pod.Annotations["annotation2"], "Annotation 'annotation2' should be restored.")
+	assert.Equal(t, "value3", pod.Annotations["annotation3"], "Annotation 'annotation3' should remain.")
+	assert.Empty(t, record)
+}
+
+func TestController_reflectAnnotationsSkipsReflectorAnnotations(t *testing.T) {
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   events.NewFakeRecorder(10),
+	}
+
+	// a regex matching every annotation of a cron job would otherwise turn its jobs into sources
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cronjob",
+			Namespace: "default",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/regex", ReflectorAnnotationsAnnotationDomain): ".*",
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain):       "team",
+				ReflectorPriorityAnnotation:                                   "10",
+				ReflectorConflictPolicyAnnotation:                             ConflictPolicyOverwrite,
+				ReflectorTargetSelectorAnnotation:                             "track=stable",
+				ReflectorStatusAnnotation:                                     "{}",
+				"owner":                                                       "payments@example.com",
+			},
+		},
 	}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "test-job", Namespace: "default"}}
 
-	anyAnnotationDeleted := controller.unsetExcessiveAnnotations(expectedAnnotations, pod)
+	plan := newReflectionPlan([]client.Object{job}, nil)
 
-	assert.True(t, anyAnnotationDeleted, "Some annotations should be deleted.")
-	assert.NotContains(t, pod.Annotations, "annotation3", "Annotation 'annotation3' should be unset.")
-	assert.Contains(t, pod.Annotations, "annotation2", "Label 'annotation2' should remain.")
+	assert.Nil(t, controller.reconcileAnnotations(cronJob, plan))
+	assert.Equal(t, "payments@example.com", job.Annotations["owner"])
+	assert.Equal(t, []string{ReflectorAnnotationsOwnershipAnnotation, "owner"},
+		slices.Sorted(maps.Keys(job.Annotations)))
 }
@@ -0,0 +1,128 @@
+package reflector
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+apply annotations of the source to the target keeping the total size of its annotations within the limit
+of the API server, so an oversized target doesn't fail on every reconciliation.
+annotations are applied to a copy of the target, while it's over the limit the largest annotation of the source
+is skipped or its value is truncated according to the configuration and the annotations are applied again.
+the ownership record keeps a copy of every reflected value, so the size is measured rather than estimated.
+*/
+func (r *Controller) applyAnnotationsWithinBudget(
+	source client.Object, target client.Object, annotationsToReflect map[string]string,
+	annotationsToSet map[string]string, priority int,
+) (bool, error) {
+	truncate := r.config.Current().AnnotationSizePolicy == AnnotationSizePolicyTruncate
+
+	// skipped annotations are treated as not reflected, so their claims are released
+	annotationsToReflect = maps.Clone(annotationsToReflect)
+	annotationsToSet = maps.Clone(annotationsToSet)
+
+	var skippedAnnotations, truncatedAnnotations []string
+
+	for {
+		candidate, ok := target.DeepCopyObject().(client.Object)
+		if !ok {
+			return false, ErrUnsupportedTarget
+		}
+
+		targetUpdated, applyErr := r.applyAnnotations(source, candidate, annotationsToReflect, annotationsToSet, priority)
+		if applyErr != nil {
+			return false, applyErr
+		}
+
+		overflow := annotationsSize(candidate.GetAnnotations()) - apivalidation.TotalAnnotationSizeLimitB
+		if overflow <= 0 || len(annotationsToSet) == 0 {
+			target.SetAnnotations(candidate.GetAnnotations())
+
+			r.reportOversizedAnnotations(source, target, AnnotationSizePolicySkip, skippedAnnotations)
+			r.reportOversizedAnnotations(source, target, AnnotationSizePolicyTruncate, truncatedAnnotations)
+
+			return targetUpdated, nil
+		}
+
+		annotation := largestAnnotation(annotationsToSet)
+		value := annotationsToSet[annotation]
+
+		// a winning value is stored twice, in the annotation and in the ownership record,
+		// so the value is truncated by half of the overflow and measured again
+		truncatedLength := len(value) - (overflow+1)/2
+		if truncate && truncatedLength > 0 {
+			annotationsToSet[annotation] = truncateAnnotationValue(value, truncatedLength)
+
+			if !slices.Contains(truncatedAnnotations, annotation) {
+				truncatedAnnotations = append(truncatedAnnotations, annotation)
+			}
+
+			continue
+		}
+
+		delete(annotationsToSet, annotation)
+		delete(annotationsToReflect, annotation)
+
+		truncatedAnnotations = slices.DeleteFunc(truncatedAnnotations, func(truncated string) bool {
+			return truncated == annotation
+		})
+		skippedAnnotations = append(skippedAnnotations, annotation)
+	}
+}
+
+// report annotations of the source that didn't fit the target through logs, metrics and an event on the source.
+func (r *Controller) reportOversizedAnnotations(
+	source client.Object, target client.Object, action string, annotations []string,
+) {
+	if len(annotations) == 0 {
+		return
+	}
+
+	slices.Sort(annotations)
+
+	actionDone := map[string]string{
+		AnnotationSizePolicySkip:     "skipped",
+		AnnotationSizePolicyTruncate: "truncated",
+	}[action]
+
+	r.logger.Info("Annotations exceed the size limit of target",
+		"kind", sourceKind(source), "source", source.GetName(), "targetKind", targetKind(target),
+		"target", target.GetName(), "annotations", annotations, "action", action)
+
+	r.addToCounter(oversizedAnnotationsTotal.WithLabelValues(sourceKind(source), targetKind(target), action),
+		len(annotations))
+
+	r.recorder.Eventf(source, target, v1.EventTypeWarning, "AnnotationSizeExceeded", "Reflect",
+		"annotations %s exceed the size limit of %s %s and were %s",
+		strings.Join(annotations, ","), targetKind(target), target.GetName(), actionDone)
+}
+
+// get the total size of annotations the way the API server computes it.
+func annotationsSize(annotations map[string]string) int {
+	size := 0
+
+	for key, value := range annotations {
+		size += len(key) + len(value)
+	}
+
+	return size
+}
+
+// get the annotation with the largest value, ties are broken by the key so the result is deterministic.
+func largestAnnotation(annotations map[string]string) string {
+	return slices.MaxFunc(slices.Collect(maps.Keys(annotations)), func(a string, b string) int {
+		return cmp.Or(cmp.Compare(len(annotations[a]), len(annotations[b])), strings.Compare(b, a))
+	})
+}
+
+// truncate the value to at most length bytes without splitting a multibyte character.
+func truncateAnnotationValue(value string, length int) string {
+	return strings.ToValidUTF8(value[:length], "")
+}
@@ -0,0 +1,135 @@
+package reflector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_reflectAnnotationsToTargetOverSizeLimit(t *testing.T) {
+	// a pod that only has room for about 50KiB of reflected annotations and their ownership record
+	existingAnnotations := map[string]string{"existing": strings.Repeat("e", 200*1024)}
+	largeConfig := strings.Repeat("c", 60*1024)
+
+	tests := []struct {
+		name           string
+		policy         string
+		wantAction     string
+		wantEvent      string
+		wantConfigFunc func(t *testing.T, value string, ok bool)
+	}{
+		{
+			name:       "Skip",
+			policy:     AnnotationSizePolicySkip,
+			wantAction: AnnotationSizePolicySkip,
+			wantEvent: "Warning AnnotationSizeExceeded annotations example.com/config " +
+				"exceed the size limit of Pod pod1 and were skipped",
+			wantConfigFunc: func(t *testing.T, value string, ok bool) {
+				t.Helper()
+				assert.False(t, ok)
+			},
+		},
+		{
+			name:       "Truncate",
+			policy:     AnnotationSizePolicyTruncate,
+			wantAction: AnnotationSizePolicyTruncate,
+			wantEvent: "Warning AnnotationSizeExceeded annotations example.com/config " +
+				"exceed the size limit of Pod pod1 and were truncated",
+			wantConfigFunc: func(t *testing.T, value string, ok bool) {
+				t.Helper()
+				assert.True(t, ok)
+				assert.NotEmpty(t, value)
+				assert.True(t, strings.HasPrefix(largeConfig, value))
+				assert.Less(t, len(value), len(largeConfig))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := events.NewFakeRecorder(10)
+
+			controller := &Controller{
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+				logger:     zap.New(),
+				config:     &common.Config{AnnotationSizePolicy: tt.policy},
+				recorder:   recorder,
+			}
+
+			source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", UID: "deployment-uid"}}
+			target := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Annotations: existingAnnotations}}
+
+			annotationsToReflect := map[string]string{"example.com/config": largeConfig, "example.com/team": "payments"}
+
+			counter := oversizedAnnotationsTotal.WithLabelValues(SourceKindDeployment, "Pod", tt.wantAction)
+			countBefore := testutil.ToFloat64(counter)
+
+			gotUpdate, err := controller.reflectAnnotationsToTarget(
+				source, target, annotationsToReflect, ConflictPolicyOverwrite, 0)
+
+			assert.Nil(t, err)
+			assert.True(t, gotUpdate)
+			assert.LessOrEqual(t, annotationsSize(target.Annotations), apivalidation.TotalAnnotationSizeLimitB)
+			assert.Equal(t, "payments", target.Annotations["example.com/team"], "Annotations that fit are reflected.")
+
+			configValue, ok := target.Annotations["example.com/config"]
+			tt.wantConfigFunc(t, configValue, ok)
+
+			assert.Equal(t, tt.wantEvent, <-recorder.Events)
+			assert.Equal(t, countBefore+1, testutil.ToFloat64(counter))
+			assert.Len(t, annotationsToReflect, 2, "Annotations to reflect to other targets are not changed.")
+		})
+	}
+}
+
+func TestController_reflectAnnotationsToTargetReleasesOversizedAnnotations(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{AnnotationSizePolicy: AnnotationSizePolicySkip},
+		recorder:   recorder,
+	}
+
+	source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", UID: "deployment-uid"}}
+	target := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}
+
+	// the annotation fits the pod at first
+	_, err := controller.reflectAnnotationsToTarget(
+		source, target, map[string]string{"example.com/config": "small"}, ConflictPolicyOverwrite, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "small", target.Annotations["example.com/config"])
+
+	// and stops fitting once it grows
+	gotUpdate, err := controller.reflectAnnotationsToTarget(source, target,
+		map[string]string{"example.com/config": strings.Repeat("c", apivalidation.TotalAnnotationSizeLimitB)},
+		ConflictPolicyOverwrite, 0)
+
+	assert.Nil(t, err)
+	assert.True(t, gotUpdate)
+	assert.NotContains(t, target.Annotations, "example.com/config")
+	assert.NotContains(t, target.Annotations, ReflectorAnnotationsOwnershipAnnotation)
+	assert.Equal(t, "Warning AnnotationSizeExceeded annotations example.com/config "+
+		"exceed the size limit of Pod pod1 and were skipped", <-recorder.Events)
+}
+
+func Test_largestAnnotation(t *testing.T) {
+	assert.Equal(t, "b", largestAnnotation(map[string]string{"a": "1", "b": "123", "c": "12"}))
+	assert.Equal(t, "a", largestAnnotation(map[string]string{"a": "12", "b": "12"}))
+}
+
+func Test_truncateAnnotationValue(t *testing.T) {
+	assert.Equal(t, "abc", truncateAnnotationValue("abcdef", 3))
+	// the euro sign takes three bytes, it's dropped rather than split
+	assert.Equal(t, "price: ", truncateAnnotationValue("price: €100", 8))
+}
@@ -0,0 +1,95 @@
+package reflector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/*
+surface keys that sources reflect to the pod with different values as a condition of the pod.
+the condition is only added once there is a conflict, it's set to false when conflicts are resolved.
+*/
+func (r *Controller) syncConflictCondition(ctx context.Context, pod *v1.Pod) error {
+	conflictingLabels := conflictingKeysOf(pod, ReflectorLabelsOwnershipAnnotation, pod.GetLabels())
+	conflictingAnnotations := conflictingKeysOf(pod, ReflectorAnnotationsOwnershipAnnotation, pod.GetAnnotations())
+
+	conditionIndex := slices.IndexFunc(pod.Status.Conditions, func(condition v1.PodCondition) bool {
+		return condition.Type == ReflectorConflictConditionType
+	})
+
+	condition := v1.PodCondition{
+		Type:   ReflectorConflictConditionType,
+		Status: v1.ConditionFalse,
+		Reason: "NoConflictingValues",
+	}
+
+	if len(conflictingLabels) > 0 || len(conflictingAnnotations) > 0 {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = "ConflictingValues"
+		condition.Message = conflictMessage(conflictingLabels, conflictingAnnotations)
+	}
+
+	if conditionIndex == -1 {
+		if condition.Status == v1.ConditionFalse {
+			return nil
+		}
+
+		condition.LastTransitionTime = metav1.Now()
+	} else {
+		existingCondition := pod.Status.Conditions[conditionIndex]
+		if existingCondition.Status == condition.Status && existingCondition.Message == condition.Message {
+			return nil
+		}
+
+		condition.LastTransitionTime = existingCondition.LastTransitionTime
+		if existingCondition.Status != condition.Status {
+			condition.LastTransitionTime = metav1.Now()
+		}
+	}
+
+	// the condition is a write like any other, so it spends the write budget of the reconciliation
+	if budgetErr := spendWrite(ctx); budgetErr != nil {
+		return budgetErr
+	}
+
+	r.logger.Info("Setting conflict condition of target", "target", pod.GetName(),
+		"status", condition.Status, "labels", conflictingLabels, "annotations", conflictingAnnotations)
+
+	return r.kubeClient.SetPodCondition(ctx, *pod, condition)
+}
+
+// get keys with conflicting claims from the ownership record in the annotation of the pod.
+// an unparsable record has no conflicts, it's reported when the record is read during reflection.
+func conflictingKeysOf(pod *v1.Pod, recordAnnotation string, targetKeys map[string]string) []string {
+	recordValue, ok := pod.GetAnnotations()[recordAnnotation]
+	if !ok {
+		return nil
+	}
+
+	record, parseErr := parseOwnershipRecord(recordValue, targetKeys)
+	if parseErr != nil {
+		return nil
+	}
+
+	return record.conflictingKeys()
+}
+
+func conflictMessage(conflictingLabels []string, conflictingAnnotations []string) string {
+	var conflicts []string
+
+	if len(conflictingLabels) > 0 {
+		conflicts = append(conflicts, fmt.Sprintf("labels %s", strings.Join(conflictingLabels, ",")))
+	}
+
+	if len(conflictingAnnotations) > 0 {
+		conflicts = append(conflicts, fmt.Sprintf("annotations %s", strings.Join(conflictingAnnotations, ",")))
+	}
+
+	return fmt.Sprintf("sources reflect different values of %s, the value with the highest precedence is set",
+		strings.Join(conflicts, " and "))
+}
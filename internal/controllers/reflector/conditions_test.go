@@ -0,0 +1,127 @@
+package reflector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_syncConflictCondition(t *testing.T) {
+	conflictingRecord := `{"team":{"source":"deployment-uid","claims":[` +
+		`{"source":"deployment-uid","kind":"Deployment","value":"payments"},` +
+		`{"source":"configmap-uid","kind":"ConfigMap","value":"billing"}]}}`
+	resolvedRecord := `{"team":{"source":"deployment-uid","claims":[` +
+		`{"source":"deployment-uid","kind":"Deployment","value":"payments"}]}}`
+	// records written by older versions have no claims
+	recordWithoutClaims := `{"team":{"source":"uid-1"}}`
+	conflictMessage := "sources reflect different values of labels team, the value with the highest precedence is set"
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		conditions  []v1.PodCondition
+		mockSetup   func(*mockKubernetesClient.MockKubernetesClient)
+	}{
+		{
+			name:        "No conflicts and no condition",
+			annotations: map[string]string{ReflectorLabelsOwnershipAnnotation: resolvedRecord},
+			conditions:  nil,
+			mockSetup:   func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+		},
+		{
+			name:        "Record without claims has no conflicts",
+			annotations: map[string]string{ReflectorLabelsOwnershipAnnotation: recordWithoutClaims},
+			conditions:  nil,
+			mockSetup:   func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+		},
+		{
+			name:        "Conflicting values",
+			annotations: map[string]string{ReflectorLabelsOwnershipAnnotation: conflictingRecord},
+			conditions:  nil,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("SetPodCondition", mock.Anything, mock.Anything,
+					mock.MatchedBy(func(condition v1.PodCondition) bool {
+						return condition.Type == ReflectorConflictConditionType &&
+							condition.Status == v1.ConditionTrue && condition.Message == conflictMessage
+					})).Return(nil)
+			},
+		},
+		{
+			name:        "Conflict is already reported",
+			annotations: map[string]string{ReflectorLabelsOwnershipAnnotation: conflictingRecord},
+			conditions: []v1.PodCondition{
+				{Type: ReflectorConflictConditionType, Status: v1.ConditionTrue, Message: conflictMessage},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+		},
+		{
+			name:        "Conflict is resolved",
+			annotations: map[string]string{ReflectorLabelsOwnershipAnnotation: resolvedRecord},
+			conditions: []v1.PodCondition{
+				{Type: ReflectorConflictConditionType, Status: v1.ConditionTrue, Message: conflictMessage},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("SetPodCondition", mock.Anything, mock.Anything,
+					mock.MatchedBy(func(condition v1.PodCondition) bool {
+						return condition.Type == ReflectorConflictConditionType &&
+							condition.Status == v1.ConditionFalse && condition.Message == ""
+					})).Return(nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     zap.New(),
+				config:     &common.Config{},
+			}
+			tt.mockSetup(mockClient)
+
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod1", Annotations: tt.annotations},
+				Status:     v1.PodStatus{Conditions: tt.conditions},
+			}
+
+			err := controller.syncConflictCondition(context.Background(), pod)
+
+			assert.Nil(t, err)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_syncConflictConditionSpendsWriteBudget(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config:     &common.Config{},
+	}
+
+	conflictingRecord := `{"team":{"source":"deployment-uid","claims":[` +
+		`{"source":"deployment-uid","kind":"Deployment","value":"payments"},` +
+		`{"source":"configmap-uid","kind":"ConfigMap","value":"billing"}]}}`
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "pod1",
+		Annotations: map[string]string{ReflectorLabelsOwnershipAnnotation: conflictingRecord},
+	}}
+
+	ctx := withWriteBudget(context.Background(), 1)
+	assert.Nil(t, spendWrite(ctx))
+
+	err := controller.syncConflictCondition(ctx, pod)
+
+	assert.ErrorIs(t, err, ErrWriteBudgetSpent)
+	mockClient.AssertNotCalled(t, "SetPodCondition", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,91 @@
+package reflector
+
+import (
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// get the conflict policy of the source, the annotation on the source takes precedence over the configuration.
+func (r *Controller) getConflictPolicy(source client.Object) (string, error) {
+	conflictPolicy := r.config.Current().ConflictPolicy
+
+	if sourceConflictPolicy, ok := source.GetAnnotations()[ReflectorConflictPolicyAnnotation]; ok {
+		conflictPolicy = sourceConflictPolicy
+	}
+
+	if conflictPolicy == "" {
+		return ConflictPolicyOverwrite, nil
+	}
+
+	if !slices.Contains(supportedConflictPolicies(), conflictPolicy) {
+		r.logger.Error(ErrUnsupportedConflictPolicy, "Source has an unsupported conflict policy",
+			"kind", sourceKind(source), "source", source.GetName(),
+			"policy", conflictPolicy, "supportedConflictPolicies", supportedConflictPolicies(),
+		)
+
+		return "", ErrUnsupportedConflictPolicy
+	}
+
+	return conflictPolicy, nil
+}
+
+/*
+get keys that should be set on the target and keys that conflict with its current metadata.
+a key conflicts when the target already has it with a different value and no source claims it,
+i.e. it was set by someone else. a conflicting key is only set with the overwrite policy.
+keys claimed by other sources are resolved by precedence of the claims instead.
+a key with the same value that no source claims is left as is, so it's never claimed.
+*/
+func (r *Controller) resolveConflicts(
+	keysToReflect map[string]string, targetKeys map[string]string,
+	record ownershipRecord, conflictPolicy string,
+) (map[string]string, []string) {
+	keysToSet := make(map[string]string)
+
+	var conflicts []string
+
+	for key, value := range keysToReflect {
+		targetValue, present := targetKeys[key]
+
+		if !present || record.isClaimed(key) {
+			keysToSet[key] = value
+
+			continue
+		}
+
+		if targetValue == value {
+			continue
+		}
+
+		conflicts = append(conflicts, key)
+
+		if conflictPolicy == ConflictPolicyOverwrite {
+			keysToSet[key] = value
+		}
+	}
+
+	slices.Sort(conflicts)
+
+	return keysToSet, conflicts
+}
+
+// report conflicting keys of the target through logs, metrics and an event on the source.
+func (r *Controller) reportConflicts(
+	source client.Object, target client.Object, metadata string, conflictPolicy string, conflicts []string,
+) {
+	r.logger.Info("Target already has keys with a different value",
+		"kind", sourceKind(source), "source", source.GetName(),
+		"targetKind", targetKind(target), "target", target.GetName(),
+		"metadata", metadata, "keys", conflicts, "policy", conflictPolicy,
+	)
+
+	r.addToCounter(conflictsTotal.WithLabelValues(sourceKind(source), targetKind(target), metadata, conflictPolicy),
+		len(conflicts))
+
+	r.recorder.Eventf(source, target, v1.EventTypeWarning, "MetadataConflict", "Reflect",
+		"%s %s already has %s %s with a different value, conflict policy is %s",
+		targetKind(target), target.GetName(), metadata, strings.Join(conflicts, ","), conflictPolicy)
+}
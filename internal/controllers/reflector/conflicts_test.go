@@ -0,0 +1,312 @@
+package reflector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_getConflictPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      *common.Config
+		annotations map[string]string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:    "Overwrite when nothing is configured",
+			config:  &common.Config{},
+			want:    ConflictPolicyOverwrite,
+			wantErr: false,
+		},
+		{
+			name:    "Configured policy",
+			config:  &common.Config{ConflictPolicy: ConflictPolicySkipIfPresent},
+			want:    ConflictPolicySkipIfPresent,
+			wantErr: false,
+		},
+		{
+			name:        "Source policy overrides configured policy",
+			config:      &common.Config{ConflictPolicy: ConflictPolicySkipIfPresent},
+			annotations: map[string]string{ReflectorConflictPolicyAnnotation: ConflictPolicyFail},
+			want:        ConflictPolicyFail,
+			wantErr:     false,
+		},
+		{
+			name:        "Unsupported source policy",
+			config:      &common.Config{},
+			annotations: map[string]string{ReflectorConflictPolicyAnnotation: "ignore"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := &Controller{
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+				logger:     zap.New(),
+				config:     tt.config,
+			}
+
+			source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+
+			got, err := controller.getConflictPolicy(source)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnsupportedConflictPolicy)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestController_resolveConflicts(t *testing.T) {
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+	}
+
+	keysToReflect := map[string]string{
+		"new":       "value",
+		"owned":     "updated",
+		"same":      "value",
+		"conflict":  "source",
+		"conflict2": "source",
+	}
+	targetKeys := map[string]string{
+		"owned":     "value",
+		"same":      "value",
+		"conflict":  "target",
+		"conflict2": "target",
+	}
+	record := ownershipRecord{
+		"owned":     {Source: "deployment-uid", Claims: []KeyClaim{{Source: "deployment-uid", Value: "value"}}},
+		"conflict2": {Source: "other-uid", Claims: []KeyClaim{{Source: "other-uid", Value: "target"}}},
+	}
+
+	tests := []struct {
+		name          string
+		policy        string
+		wantKeysToSet map[string]string
+	}{
+		{
+			name:   "Overwrite",
+			policy: ConflictPolicyOverwrite,
+			wantKeysToSet: map[string]string{
+				"new": "value", "owned": "updated", "conflict": "source", "conflict2": "source",
+			},
+		},
+		{
+			name:          "Skip if present",
+			policy:        ConflictPolicySkipIfPresent,
+			wantKeysToSet: map[string]string{"new": "value", "owned": "updated", "conflict2": "source"},
+		},
+		{
+			name:          "Fail",
+			policy:        ConflictPolicyFail,
+			wantKeysToSet: map[string]string{"new": "value", "owned": "updated", "conflict2": "source"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keysToSet, conflicts := controller.resolveConflicts(
+				keysToReflect, targetKeys, record, tt.policy)
+
+			// a key claimed by another source is resolved by precedence of the claims instead
+			assert.Equal(t, tt.wantKeysToSet, keysToSet)
+			assert.Equal(t, []string{"conflict"}, conflicts)
+		})
+	}
+}
+
+func TestController_reflectLabelsToTarget(t *testing.T) {
+	labelsToReflect := map[string]string{"team": "payments", "istio.io/rev": "reflected"}
+
+	tests := []struct {
+		name       string
+		policy     string
+		wantUpdate bool
+		wantErr    bool
+		wantLabels map[string]string
+		wantAnn    map[string]string
+	}{
+		{
+			name:       "Overwrite",
+			policy:     ConflictPolicyOverwrite,
+			wantUpdate: true,
+			wantErr:    false,
+			wantLabels: map[string]string{"team": "payments", "istio.io/rev": "reflected"},
+			wantAnn: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"istio.io/rev":{"source":"deployment-uid","priorValue":"injected",` +
+					`"claims":[{"source":"deployment-uid","kind":"Deployment","value":"reflected"}]},` +
+					`"team":{"source":"deployment-uid",` +
+					`"claims":[{"source":"deployment-uid","kind":"Deployment","value":"payments"}]}}`,
+			},
+		},
+		{
+			name:       "Skip if present",
+			policy:     ConflictPolicySkipIfPresent,
+			wantUpdate: true,
+			wantErr:    false,
+			wantLabels: map[string]string{"team": "payments", "istio.io/rev": "injected"},
+			wantAnn: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"deployment-uid",` +
+					`"claims":[{"source":"deployment-uid","kind":"Deployment","value":"payments"}]}}`,
+			},
+		},
+		{
+			name:       "Fail",
+			policy:     ConflictPolicyFail,
+			wantUpdate: false,
+			wantErr:    true,
+			wantLabels: map[string]string{"istio.io/rev": "injected"},
+			wantAnn:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := events.NewFakeRecorder(10)
+
+			controller := &Controller{
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+				logger:     zap.New(),
+				config:     &common.Config{},
+				recorder:   recorder,
+			}
+
+			source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", UID: "deployment-uid"}}
+			target := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "pod1",
+					Labels: map[string]string{"istio.io/rev": "injected"},
+				},
+			}
+
+			gotUpdate, err := controller.reflectLabelsToTarget(context.Background(), source, target, labelsToReflect, tt.policy, 0)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrKeyConflict)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			assert.Equal(t, tt.wantUpdate, gotUpdate)
+			assert.Equal(t, tt.wantLabels, target.Labels)
+			assert.Equal(t, tt.wantAnn, target.Annotations)
+			assert.Len(t, recorder.Events, 1)
+		})
+	}
+}
+
+func TestController_reflectAnnotationsToTarget(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   recorder,
+	}
+
+	source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"}}
+	target := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pod1",
+			Annotations: map[string]string{
+				"sidecar.istio.io/status":               "injected",
+				"stale":                                 "value",
+				ReflectorAnnotationsReflectedAnnotation: "stale",
+			},
+		},
+	}
+
+	gotUpdate, err := controller.reflectAnnotationsToTarget(source, target,
+		map[string]string{"sidecar.istio.io/status": "reflected"}, ConflictPolicySkipIfPresent, 0)
+
+	// the stale annotation from the legacy list is unset, the conflicting one is kept and never owned
+	assert.Nil(t, err)
+	assert.True(t, gotUpdate)
+	assert.Equal(t, map[string]string{"sidecar.istio.io/status": "injected"}, target.Annotations)
+	assert.Len(t, recorder.Events, 1)
+}
+
+func TestController_reportConflicts(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   recorder,
+	}
+
+	source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"}}
+	target := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}
+
+	counter := conflictsTotal.WithLabelValues(SourceKindDeployment, "Pod", "labels", ConflictPolicyFail)
+	countBefore := testutil.ToFloat64(counter)
+
+	controller.reportConflicts(source, target, "labels", ConflictPolicyFail, []string{"a", "b"})
+
+	assert.Equal(t, countBefore+2, testutil.ToFloat64(counter))
+	assert.Equal(t,
+		"Warning MetadataConflict Pod pod1 already has labels a,b with a different value, conflict policy is fail",
+		<-recorder.Events)
+}
+
+func TestController_reflectLabelsWithFailPolicy(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   events.NewFakeRecorder(10),
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team",
+				ReflectorConflictPolicyAnnotation:                       ConflictPolicyFail,
+			},
+			Labels: map[string]string{"team": "payments"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "test"},
+			},
+		},
+	}
+
+	pods := &v1.PodList{
+		Items: []v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "conflicting", Labels: map[string]string{"team": "platform"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "clean"}},
+		},
+	}
+	plan := newReflectionPlan(podsAsTargets(pods), nil)
+
+	err := controller.reflectLabels(context.Background(), deployment, plan)
+
+	assert.ErrorIs(t, err, ErrKeyConflict)
+
+	// the clean pod is still updated
+	assert.Equal(t, []string{"clean"}, targetNames(plan.changedTargets))
+	assert.Equal(t, "payments", pods.Items[1].Labels["team"])
+	assert.Equal(t, "platform", pods.Items[0].Labels["team"])
+}
@@ -2,7 +2,9 @@ package reflector
 
 import (
 	"context"
+	"maps"
 	"reflect"
+	"strings"
 
 	"github.com/NCCloud/metadata-reflector/internal/clients"
 	"github.com/NCCloud/metadata-reflector/internal/common"
@@ -10,25 +12,39 @@ import (
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 type Controller struct {
 	kubeClient clients.KubernetesClient
 	logger     logr.Logger
-	config     *common.Config
+	config     common.ConfigProvider
+	recorder   events.EventRecorder
+	// dryRun the controller only explains plans, nothing is counted in metrics
+	dryRun bool
 }
 
 func NewController(
-	kubeClient clients.KubernetesClient, logger logr.Logger, config *common.Config,
+	kubeClient clients.KubernetesClient, logger logr.Logger, config common.ConfigProvider,
+	recorder events.EventRecorder,
 ) Controller {
 	return Controller{
 		kubeClient: kubeClient,
 		logger:     logger,
 		config:     config,
+		recorder:   recorder,
 	}
 }
 
@@ -52,75 +68,174 @@ func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, getDeployErr
 	}
 
-	var reflectorErrors *multierror.Error
+	return r.reconcileSource(ctx, deployment)
+}
+
+// ReconcileConfigMap reflect metadata of a config map to pods referencing it.
+func (r *Controller) ReconcileConfigMap(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileMetadataSource(ctx, req, v1.SchemeGroupVersion.WithKind(SourceKindConfigMap))
+}
+
+// ReconcileSecret reflect metadata of a secret to pods referencing it.
+func (r *Controller) ReconcileSecret(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileMetadataSource(ctx, req, v1.SchemeGroupVersion.WithKind(SourceKindSecret))
+}
+
+// ReconcileCronJob reflect metadata of a cron job to its running jobs and their pods.
+func (r *Controller) ReconcileCronJob(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileTypedSource(ctx, req, SourceKindCronJob,
+		func(ctx context.Context, namespacedName types.NamespacedName) (client.Object, error) {
+			return r.kubeClient.GetCronJob(ctx, namespacedName)
+		})
+}
+
+// ReconcileJob reflect metadata of a job to its pods.
+func (r *Controller) ReconcileJob(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileTypedSource(ctx, req, SourceKindJob,
+		func(ctx context.Context, namespacedName types.NamespacedName) (client.Object, error) {
+			return r.kubeClient.GetJob(ctx, namespacedName)
+		})
+}
+
+// reconcile a source that is fetched with the given function.
+func (r *Controller) reconcileTypedSource(
+	ctx context.Context, req ctrl.Request, kind string,
+	getSource func(context.Context, types.NamespacedName) (client.Object, error),
+) (ctrl.Result, error) {
+	namespacedName := req.NamespacedName
+
+	r.logger.V(1).Info("Starting reconciliation", "kind", kind, "namespacedName", namespacedName)
+	defer r.logger.V(1).Info("Finished reconciliation", "kind", kind, "namespacedName", namespacedName)
 
-	labelReflectResult, labelReflectError := r.reconcileLabels(ctx, deployment)
+	source, getSourceErr := getSource(ctx, namespacedName)
+	if getSourceErr != nil {
+		if errors.IsNotFound(getSourceErr) {
+			r.logger.Info("Source not found, skipping reconciliation",
+				"kind", kind, "namespacedName", namespacedName)
 
-	annReflectResult, annReflectError := r.reconcileAnnotations(ctx, deployment)
+			return ctrl.Result{}, nil
+		}
 
-	reflectorErrors = multierror.Append(reflectorErrors, labelReflectError, annReflectError)
+		r.logger.Error(getSourceErr, "Failed to get source", "kind", kind, "namespacedName", namespacedName)
 
-	// if the error is not nil, it always takes precedence over the result
-	// the idea is not to requeue after any error as there can be other independent phases
-	// but also maintain the possibility to requeue now/after some time when there was no error
-	// and the result explicitly states that we need to requeue
-	if r.shouldRequeueNow(labelReflectResult) {
-		return labelReflectResult, labelReflectError
-	} else if r.shouldRequeueNow(annReflectResult) {
-		return annReflectResult, annReflectError
+		return ctrl.Result{}, getSourceErr
 	}
 
-	return ctrl.Result{RequeueAfter: r.config.BackgroundReflectionInterval}, reflectorErrors.ErrorOrNil()
+	return r.reconcileSource(ctx, source)
 }
 
-func (r *Controller) FilterCreateEvents(e event.CreateEvent) bool {
-	deployment, ok := e.Object.(*appsv1.Deployment)
-	if !ok {
-		return false
+// reconcile a source of which only metadata is cached.
+func (r *Controller) reconcileMetadataSource(
+	ctx context.Context, req ctrl.Request, gvk schema.GroupVersionKind,
+) (ctrl.Result, error) {
+	namespacedName := req.NamespacedName
+
+	r.logger.V(1).Info("Starting reconciliation", "kind", gvk.Kind, "namespacedName", namespacedName)
+	defer r.logger.V(1).Info("Finished reconciliation", "kind", gvk.Kind, "namespacedName", namespacedName)
+
+	source, getSourceErr := r.kubeClient.GetMetadata(ctx, gvk, namespacedName)
+	if getSourceErr != nil {
+		if errors.IsNotFound(getSourceErr) {
+			r.logger.Info("Source not found, skipping reconciliation",
+				"kind", gvk.Kind, "namespacedName", namespacedName)
+
+			return ctrl.Result{}, nil
+		}
+
+		r.logger.Error(getSourceErr, "Failed to get source", "kind", gvk.Kind, "namespacedName", namespacedName)
+
+		return ctrl.Result{}, getSourceErr
 	}
 
-	// check if the deployment contains any reflector annotation
-	if common.MapContainsPartialKey(ReflectorAnnotationDomain, deployment.Annotations) {
-		return true
+	return r.reconcileSource(ctx, source)
+}
+
+// plan the metadata of all targets of the source and write every changed target once.
+func (r *Controller) reconcileSource(ctx context.Context, source client.Object) (ctrl.Result, error) {
+	ctx = withWriteBudget(ctx, r.config.Current().MaxWritesPerReconcile)
+
+	// targets are written even when some could not be planned, as reflection phases are independent
+	plan, planErr := r.planReflection(ctx, source)
+	applyErr := r.applyPlan(ctx, plan)
+
+	reflectorErrors := multierror.Append(nil, planErr, applyErr)
+
+	result := ctrl.Result{RequeueAfter: r.config.Current().BackgroundReflectionInterval}
+	reconcileErr := reflectorErrors.ErrorOrNil()
+
+	// a source without reflector annotations only had its keys released, e.g. a config map enqueued by a pod event,
+	// it isn't reflected in the background until it gets reflector annotations
+	if len(FindReflectorAnnotations(ReflectorAnnotationDomain, source)) == 0 {
+		result = ctrl.Result{}
 	}
 
-	return false
+	if budgetResult, budgetSpent, budgetErr := r.requeueOnSpentWriteBudget(source, reflectorErrors); budgetSpent {
+		result, reconcileErr = budgetResult, budgetErr
+	}
+
+	errClass, reconcileErr := classifyErrors(reconcileErr)
+
+	r.writeSourceStatus(ctx, source, plan, reconcileErr)
+
+	return r.requeueByErrorClass(source, result, errClass, reconcileErr)
 }
 
-func (r *Controller) FilterUpdateEvents(e event.UpdateEvent) bool {
-	newDeployment, ok := e.ObjectNew.(*appsv1.Deployment)
-	if !ok {
-		return false
+/*
+requeue the source according to the class of the errors of its reconciliation.
+transient errors are returned, so the source is retried with the exponential backoff of the controller.
+permanent errors are logged and not retried with backoff, the source is only reflected again in the background
+or when it changes, so drift of its other targets is still corrected. expected states are not errors.
+*/
+func (r *Controller) requeueByErrorClass(
+	source client.Object, result ctrl.Result, errClass errorClass, reconcileErr error,
+) (ctrl.Result, error) {
+	if errClass == errorClassExpected {
+		return result, nil
 	}
 
-	oldDeployment, ok := e.ObjectOld.(*appsv1.Deployment)
-	if !ok {
-		return false
+	reconcileErrorsTotal.WithLabelValues(sourceKind(source), errClass.String()).Inc()
+
+	if errClass == errorClassTransient {
+		return ctrl.Result{}, reconcileErr
 	}
 
-	oldDepHasReflectorAnn := common.MapContainsPartialKey(ReflectorAnnotationDomain, oldDeployment.Annotations)
-	newDepHasReflectorAnn := common.MapContainsPartialKey(ReflectorAnnotationDomain, newDeployment.Annotations)
+	r.logger.Error(reconcileErr, "Reconciliation failed, the source is only retried in the background or when it changes",
+		"kind", sourceKind(source), "source", source.GetName())
 
-	// the deployment doesn't have the reflector annotation
-	if !oldDepHasReflectorAnn && !newDepHasReflectorAnn {
+	return result, nil
+}
+
+func (r *Controller) FilterCreateEvents(e event.CreateEvent) bool {
+	if !isSupportedSource(e.Object) {
 		return false
 	}
 
-	// annotations updated on deployment
-	if !reflect.DeepEqual(newDeployment.Annotations, oldDeployment.Annotations) {
-		return true
+	// check if the source contains any reflector annotation, annotations the reflector writes itself don't count
+	return len(FindReflectorAnnotations(ReflectorAnnotationDomain, e.Object)) > 0
+}
+
+// FilterUpdateEvents check whether the metadata of the source changed.
+// status changes are ignored, new pods of the source are reflected to when they are created.
+func (r *Controller) FilterUpdateEvents(e event.UpdateEvent) bool {
+	if !isSupportedSource(e.ObjectNew) || !isSupportedSource(e.ObjectOld) {
+		return false
 	}
 
-	// deployment scaled, we need to re-apply labels
-	newDeploymentReadyReplicas := newDeployment.Status.ReadyReplicas
-	oldDeploymentReadyReplicas := oldDeployment.Status.ReadyReplicas
+	oldSourceHasReflectorAnn := len(FindReflectorAnnotations(ReflectorAnnotationDomain, e.ObjectOld)) > 0
+	newSourceHasReflectorAnn := len(FindReflectorAnnotations(ReflectorAnnotationDomain, e.ObjectNew)) > 0
 
-	if newDeploymentReadyReplicas > oldDeploymentReadyReplicas {
+	// the source doesn't have the reflector annotation
+	if !oldSourceHasReflectorAnn && !newSourceHasReflectorAnn {
+		return false
+	}
+
+	// annotations updated on source, the status and ownership records written by the reflector are not changes of it
+	if !maps.Equal(withoutBookkeeping(e.ObjectNew.GetAnnotations()), withoutBookkeeping(e.ObjectOld.GetAnnotations())) {
 		return true
 	}
 
-	// labels updated on deployment
-	if !reflect.DeepEqual(newDeployment.Labels, oldDeployment.Labels) {
+	// labels updated on source
+	if !reflect.DeepEqual(e.ObjectNew.GetLabels(), e.ObjectOld.GetLabels()) {
 		return true
 	}
 
@@ -143,12 +258,89 @@ func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
 		},
 	}
 
+	podIndexRegistered := false
+
+	for _, kind := range r.config.Current().SourceKinds {
+		var setupErr error
+
+		switch kind {
+		case SourceKindDeployment:
+			setupErr = ctrl.NewControllerManagedBy(mgr).
+				For(&appsv1.Deployment{}, builder.WithPredicates(predicate)).
+				Watches(&v1.Pod{}, r.podSourcesHandler(kind), builder.WithPredicates(r.podPredicate())).
+				Complete(r)
+		case SourceKindCronJob:
+			setupErr = ctrl.NewControllerManagedBy(mgr).
+				For(&batchv1.CronJob{}, builder.WithPredicates(predicate)).
+				Watches(&v1.Pod{}, r.podSourcesHandler(kind), builder.WithPredicates(r.podPredicate())).
+				Complete(reconcile.Func(r.ReconcileCronJob))
+		case SourceKindJob:
+			setupErr = ctrl.NewControllerManagedBy(mgr).
+				For(&batchv1.Job{}, builder.WithPredicates(predicate)).
+				Watches(&v1.Pod{}, r.podSourcesHandler(kind), builder.WithPredicates(r.podPredicate())).
+				Complete(reconcile.Func(r.ReconcileJob))
+		case SourceKindConfigMap, SourceKindSecret:
+			if !podIndexRegistered {
+				setupErr = mgr.GetFieldIndexer().IndexField(
+					context.Background(), &v1.Pod{}, PodReferencedObjectsIndex, IndexPodReferencedObjects)
+				if setupErr != nil {
+					return setupErr
+				}
+
+				podIndexRegistered = true
+			}
+
+			setupErr = r.setupMetadataSourceWithManager(mgr, kind, predicate)
+		default:
+			r.logger.Error(ErrUnsupportedSource, "Unsupported source kind",
+				"kind", kind, "supportedSourceKinds", supportedSourceKinds())
+
+			return ErrUnsupportedSource
+		}
+
+		if setupErr != nil {
+			return setupErr
+		}
+	}
+
+	return nil
+}
+
+// set up a controller for a source kind of which only metadata is watched and cached.
+func (r *Controller) setupMetadataSourceWithManager(
+	mgr ctrl.Manager, kind string, predicate predicate.Predicate,
+) error {
+	var (
+		object     client.Object
+		reconciler reconcile.Func
+	)
+
+	switch kind {
+	case SourceKindConfigMap:
+		object, reconciler = &v1.ConfigMap{}, r.ReconcileConfigMap
+	case SourceKindSecret:
+		object, reconciler = &v1.Secret{}, r.ReconcileSecret
+	default:
+		return ErrUnsupportedSource
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&appsv1.Deployment{}).
-		WithEventFilter(predicate).
-		Complete(r)
+		Named(strings.ToLower(kind)).
+		For(object, builder.OnlyMetadata, builder.WithPredicates(predicate)).
+		Watches(&v1.Pod{}, r.podSourcesHandler(kind), builder.WithPredicates(r.podPredicate())).
+		Complete(reconciler)
 }
 
-func (r *Controller) shouldRequeueNow(result ctrl.Result) bool {
-	return result.RequeueAfter != 0
+// check whether metadata can be reflected from the object.
+func isSupportedSource(object client.Object) bool {
+	switch typedObject := object.(type) {
+	case *appsv1.Deployment, *batchv1.CronJob, *batchv1.Job:
+		return true
+	case *metav1.PartialObjectMetadata:
+		kind := typedObject.GroupVersionKind().Kind
+
+		return kind == SourceKindConfigMap || kind == SourceKindSecret
+	default:
+		return false
+	}
 }
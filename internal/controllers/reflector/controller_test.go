@@ -12,12 +12,16 @@ import (
 
 	"github.com/NCCloud/metadata-reflector/internal/common"
 	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/hashicorp/go-multierror"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -29,7 +33,9 @@ func TestNewController(t *testing.T) {
 	logger := zap.New()
 	config := &common.Config{}
 
-	controller := NewController(mockClient, logger, config)
+	recorder := events.NewFakeRecorder(10)
+
+	controller := NewController(mockClient, logger, config, recorder)
 
 	assert.NotNil(t, controller)
 }
@@ -76,7 +82,7 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
 					Return(&v1.PodList{
 						Items: []v1.Pod{
 							{
@@ -91,7 +97,7 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
+				mockClient.On("PatchMetadata", mock.Anything, mock.Anything).
 					Return(nil)
 			},
 			want:    ctrl.Result{},
@@ -128,7 +134,7 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
 					Return(&v1.PodList{
 						Items: []v1.Pod{
 							{
@@ -147,7 +153,7 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
+				mockClient.On("PatchMetadata", mock.Anything, mock.Anything).
 					Return(nil)
 			},
 			want:    ctrl.Result{},
@@ -219,7 +225,7 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
 					Return(&v1.PodList{
 						Items: []v1.Pod{
 							{
@@ -234,7 +240,7 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
+				mockClient.On("PatchMetadata", mock.Anything, mock.Anything).
 					Return(nil)
 			},
 			want:    ctrl.Result{},
@@ -268,7 +274,7 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
 					Return(&v1.PodList{
 						Items: []v1.Pod{
 							{
@@ -285,14 +291,14 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
+				mockClient.On("PatchMetadata", mock.Anything, mock.Anything).
 					Return(nil)
 			},
 			want:    ctrl.Result{},
 			wantErr: false,
 		},
 		{
-			name: "Failed reconciliation of annotation reflection",
+			name: "Invalid annotation reflection is not retried",
 			args: args{
 				req: ctrl.Request{
 					NamespacedName: types.NamespacedName{
@@ -319,7 +325,7 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
 					Return(&v1.PodList{
 						Items: []v1.Pod{
 							{
@@ -332,11 +338,11 @@ func TestController_Reconcile(t *testing.T) {
 					}, nil)
 
 				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
+				mockClient.On("PatchMetadata", mock.Anything, mock.Anything).
 					Return(nil)
 			},
 			want:    ctrl.Result{},
-			wantErr: true,
+			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
@@ -364,99 +370,301 @@ func TestController_Reconcile(t *testing.T) {
 	}
 }
 
-func TestController_getManagedPods(t *testing.T) {
+func TestController_Reconcile_errorClasses(t *testing.T) {
+	labelsAnnotation := fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain)
+	podNotFound := k8serrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "pod1")
+	podInvalid := k8serrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "pod1", nil)
+	podConflict := k8serrors.NewConflict(schema.GroupResource{Resource: "pods"}, "pod1", errors.New("stale"))
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		pods        []v1.Pod
+		patchErr    error
+		want        ctrl.Result
+		wantErr     bool
+	}{
+		{
+			name:        "Deployment scaled to zero is not an error",
+			annotations: map[string]string{labelsAnnotation: "team"},
+			pods:        nil,
+			want:        ctrl.Result{RequeueAfter: time.Hour},
+			wantErr:     false,
+		},
+		{
+			name:        "Target deleted while it's written is not an error",
+			annotations: map[string]string{labelsAnnotation: "team"},
+			pods:        []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}},
+			patchErr:    podNotFound,
+			want:        ctrl.Result{RequeueAfter: time.Hour},
+			wantErr:     false,
+		},
+		{
+			name:        "Deployment without reflector annotations isn't reflected in the background",
+			annotations: nil,
+			pods:        []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}},
+			want:        ctrl.Result{},
+			wantErr:     false,
+		},
+		{
+			name:        "Failed write is retried with backoff",
+			annotations: map[string]string{labelsAnnotation: "team"},
+			pods:        []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}},
+			patchErr:    errors.New("conflict"),
+			want:        ctrl.Result{},
+			wantErr:     true,
+		},
+		{
+			name:        "Write of a stale target is retried with backoff",
+			annotations: map[string]string{labelsAnnotation: "team"},
+			pods:        []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}},
+			patchErr:    podConflict,
+			want:        ctrl.Result{},
+			wantErr:     true,
+		},
+		{
+			name:        "Write rejected by the API server is not retried before the background reflection",
+			annotations: map[string]string{labelsAnnotation: "team"},
+			pods:        []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}},
+			patchErr:    podInvalid,
+			want:        ctrl.Result{RequeueAfter: time.Hour},
+			wantErr:     false,
+		},
+		{
+			name: "Invalid annotation is not retried before the background reflection",
+			annotations: map[string]string{
+				fmt.Sprintf("%s/invalid", ReflectorLabelsAnnotationDomain): "team",
+			},
+			pods:    []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}},
+			want:    ctrl.Result{RequeueAfter: time.Hour},
+			wantErr: false,
+		},
+		{
+			name: "Invalid annotation and failed write are retried with backoff",
+			annotations: map[string]string{
+				labelsAnnotation: "team",
+				fmt.Sprintf("%s/invalid", ReflectorAnnotationsAnnotationDomain): "owner",
+			},
+			pods:     []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}},
+			patchErr: errors.New("conflict"),
+			want:     ctrl.Result{},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     zap.New(),
+				config:     &common.Config{BackgroundReflectionInterval: time.Hour},
+			}
+
+			mockClient.On("GetDeployment", mock.Anything, mock.Anything).
+				Return(&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "test-deployment",
+						Namespace:   "default",
+						Annotations: tt.annotations,
+						Labels:      map[string]string{"team": "payments"},
+					},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+					},
+				}, nil)
+			mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).Return(&v1.PodList{Items: tt.pods}, nil)
+			mockClient.On("PatchMetadata", mock.Anything, mock.Anything).Return(tt.patchErr)
+
+			got, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "test-deployment"},
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_classifyErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		reconcileErr  error
+		wantClass     errorClass
+		wantRemaining int
+	}{
+		{
+			name:          "No errors",
+			reconcileErr:  nil,
+			wantClass:     errorClassExpected,
+			wantRemaining: 0,
+		},
+		{
+			name:          "Target not found",
+			reconcileErr:  k8serrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "pod1"),
+			wantClass:     errorClassExpected,
+			wantRemaining: 0,
+		},
+		{
+			name:          "Wrapped invalid annotation",
+			reconcileErr:  fmt.Errorf("%w: labels.metadata-reflector.spaceship.com/invalid", ErrUnparsableOperation),
+			wantClass:     errorClassPermanent,
+			wantRemaining: 1,
+		},
+		{
+			name:          "Conflict with a key of a target",
+			reconcileErr:  fmt.Errorf("%w: team", ErrKeyConflict),
+			wantClass:     errorClassTransient,
+			wantRemaining: 1,
+		},
+		{
+			name:          "Label protected by the controller of a target",
+			reconcileErr:  ErrProtectedLabel,
+			wantClass:     errorClassTransient,
+			wantRemaining: 1,
+		},
+		{
+			name:          "Label selected by the source",
+			reconcileErr:  ErrSourceSelectorLabel,
+			wantClass:     errorClassPermanent,
+			wantRemaining: 1,
+		},
+		{
+			name:          "Invalid labels",
+			reconcileErr:  ErrInvalidLabel,
+			wantClass:     errorClassPermanent,
+			wantRemaining: 1,
+		},
+		{
+			name:          "Unknown error",
+			reconcileErr:  errors.New("connection refused"),
+			wantClass:     errorClassTransient,
+			wantRemaining: 1,
+		},
+		{
+			name: "Most severe class of nested errors",
+			reconcileErr: multierror.Append(k8serrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "pod1"),
+				multierror.Append(ErrKeyConflict, k8serrors.NewTooManyRequests("throttled", 1))),
+			wantClass:     errorClassTransient,
+			wantRemaining: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotClass, gotErr := classifyErrors(tt.reconcileErr)
+
+			assert.Equal(t, tt.wantClass, gotClass)
+
+			if tt.wantRemaining == 0 {
+				assert.Nil(t, gotErr)
+
+				return
+			}
+
+			var remaining *multierror.Error
+			if assert.ErrorAs(t, gotErr, &remaining) {
+				assert.Len(t, remaining.WrappedErrors(), tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestController_ReconcileConfigMap(t *testing.T) {
 	type args struct {
-		ctx        context.Context
-		deployment *appsv1.Deployment
+		req ctrl.Request
 	}
 	tests := []struct {
 		name      string
 		args      args
 		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
-		want      *v1.PodList
+		want      ctrl.Result
 		wantErr   bool
 	}{
 		{
-			name: "Successfully get managed pods",
+			name: "Successful reconciliation with label reflection to referencing pods",
 			args: args{
-				deployment: &appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-deployment",
+				req: ctrl.Request{
+					NamespacedName: types.NamespacedName{
 						Namespace: "default",
-					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
+						Name:      "test-config",
 					},
 				},
 			},
 			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
+				mockClient.On("GetMetadata", mock.Anything, v1.SchemeGroupVersion.WithKind(SourceKindConfigMap),
+					types.NamespacedName{Namespace: "default", Name: "test-config"}).
+					Return(&metav1.PartialObjectMetadata{
+						TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: SourceKindConfigMap},
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test-config",
+							Namespace: "default",
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "classification",
+							},
+							Labels: map[string]string{
+								"classification": "confidential",
+							},
+						},
+					}, nil)
+
+				mockClient.On("ListPodsByIndex", mock.Anything, "default",
+					PodReferencedObjectsIndex, "ConfigMap/test-config").
 					Return(&v1.PodList{
 						Items: []v1.Pod{
 							{
 								ObjectMeta: metav1.ObjectMeta{
-									Name:        "pod1",
-									Namespace:   "default",
-									Labels:      map[string]string{"label1": "value1"},
-									Annotations: map[string]string{},
+									Name:      "pod1",
+									Namespace: "default",
 								},
 							},
 						},
 					}, nil)
+
+				mockClient.On("PatchMetadata", mock.Anything, mock.MatchedBy(func(pod *metav1.PartialObjectMetadata) bool {
+					return pod.Labels["classification"] == "confidential"
+				})).Return(nil)
 			},
-			want: &v1.PodList{
-				Items: []v1.Pod{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:        "pod1",
-							Namespace:   "default",
-							Labels:      map[string]string{"label1": "value1"},
-							Annotations: map[string]string{},
-						},
-					},
-				},
-			},
+			want:    ctrl.Result{},
 			wantErr: false,
 		},
 		{
-			name: "Empty pod selector",
+			name: "Config map not found",
 			args: args{
-				deployment: &appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-deployment",
+				req: ctrl.Request{
+					NamespacedName: types.NamespacedName{
 						Namespace: "default",
-					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{},
+						Name:      "test-config",
 					},
 				},
 			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
-			want:      nil,
-			wantErr:   true,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetMetadata", mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, k8serrors.NewNotFound(schema.GroupResource{
+						Resource: "configmaps",
+					}, "test-config"))
+			},
+			want:    ctrl.Result{},
+			wantErr: false,
 		},
 		{
-			name: "Found no pods",
+			name: "Failed to get config map",
 			args: args{
-				deployment: &appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-deployment",
+				req: ctrl.Request{
+					NamespacedName: types.NamespacedName{
 						Namespace: "default",
-					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
+						Name:      "test-config",
 					},
 				},
 			},
 			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{Items: []v1.Pod{}}, nil)
+				mockClient.On("GetMetadata", mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, errors.New("failed to get config map"))
 			},
-			want:    nil,
+			want:    ctrl.Result{},
 			wantErr: true,
 		},
 	}
@@ -474,91 +682,591 @@ func TestController_getManagedPods(t *testing.T) {
 			}
 			tt.mockSetup(mockClient)
 
-			got, err := controller.getManagedPods(context.Background(), tt.args.deployment)
+			got, err := controller.ReconcileConfigMap(context.Background(), tt.args.req)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
 			}
 
+			assert.Nil(t, err)
 			assert.Equal(t, tt.want, got)
+			mockClient.AssertExpectations(t)
 		})
 	}
 }
 
-func TestController_FilterCreateEvents(t *testing.T) {
-	type args struct {
-		e event.CreateEvent
+func TestController_ReconcileSecret(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
 	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{
-			name: "Event is not a Deployment",
-			args: args{
-				e: event.CreateEvent{
-					Object: &v1.Pod{},
+
+	namespacedName := types.NamespacedName{Namespace: "default", Name: "credentials"}
+
+	mockClient.On("GetMetadata", mock.Anything, v1.SchemeGroupVersion.WithKind(SourceKindSecret), namespacedName).
+		Return(&metav1.PartialObjectMetadata{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: SourceKindSecret},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "credentials",
+				Namespace: "default",
+				Annotations: map[string]string{
+					fmt.Sprintf("%s/list", ReflectorAnnotationsAnnotationDomain): "classification",
+					"classification": "confidential",
 				},
 			},
-			want: false,
-		},
-		{
-			name: "Event contains reflector annotation",
-			args: args{
-				e: event.CreateEvent{
-					Object: &appsv1.Deployment{
-						ObjectMeta: metav1.ObjectMeta{
-							Annotations: map[string]string{
-								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "key",
-							},
-						},
+		}, nil)
+
+	mockClient.On("ListPodsByIndex", mock.Anything, "default", PodReferencedObjectsIndex, "Secret/credentials").
+		Return(&v1.PodList{
+			Items: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "pod1",
+						Namespace: "default",
 					},
 				},
 			},
-			want: true,
-		},
-		{
-			name: "Event does not contain reflector annotation",
-			args: args{
-				e: event.CreateEvent{
-					Object: &appsv1.Deployment{
-						ObjectMeta: metav1.ObjectMeta{
-							Annotations: map[string]string{},
+		}, nil)
+
+	mockClient.On("PatchMetadata", mock.Anything, mock.MatchedBy(func(pod *metav1.PartialObjectMetadata) bool {
+		return pod.Annotations["classification"] == "confidential"
+	})).Return(nil)
+
+	got, err := controller.ReconcileSecret(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+
+	assert.Nil(t, err)
+	assert.Equal(t, ctrl.Result{}, got)
+	mockClient.AssertExpectations(t)
+}
+
+func TestController_ReconcileCronJob(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	namespacedName := types.NamespacedName{Namespace: "default", Name: "test-cronjob"}
+
+	mockClient.On("GetCronJob", mock.Anything, namespacedName).
+		Return(&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cronjob",
+				Namespace: "default",
+				UID:       "cronjob-uid",
+				Annotations: map[string]string{
+					fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team,cost-center",
+				},
+				Labels: map[string]string{
+					"team": "payments",
+				},
+			},
+		}, nil)
+
+	// cost-center was removed from the cron job and should be removed from the targets
+	mockClient.On("ListJobs", mock.Anything, "default").
+		Return(&batchv1.JobList{
+			Items: []batchv1.Job{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "test-cronjob-1",
+						Namespace:       "default",
+						UID:             "job-uid",
+						OwnerReferences: controllerReference("CronJob", "test-cronjob", "cronjob-uid"),
+						Labels: map[string]string{
+							"team":        "payments",
+							"cost-center": "42",
+						},
+						Annotations: map[string]string{
+							ReflectorLabelsReflectedAnnotation: "team,cost-center",
+						},
+					},
+					Spec: batchv1.JobSpec{
+						Selector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"batch.kubernetes.io/controller-uid": "job-uid"},
 						},
 					},
 				},
 			},
-			want: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+		}, nil)
 
-			logger := zap.New()
-			config := &common.Config{}
+	mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+		Return(&v1.PodList{
+			Items: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "test-cronjob-1-abcde",
+						Namespace:       "default",
+						OwnerReferences: controllerReference("Job", "test-cronjob-1", "job-uid"),
+					},
+				},
+			},
+		}, nil)
 
-			controller := &Controller{
-				kubeClient: mockClient,
-				logger:     logger,
-				config:     config,
-			}
+	// selector keys of the job controlling the pod are protected
+	mockClient.On("GetControllerSelector", mock.Anything, "default", mock.Anything).
+		Return(&metav1.LabelSelector{
+			MatchLabels: map[string]string{"batch.kubernetes.io/controller-uid": "job-uid"},
+		}, nil)
 
-			got := controller.FilterCreateEvents(tt.args.e)
+	cronJobRecord := `{"team":{"source":"cronjob-uid",` +
+		`"claims":[{"source":"cronjob-uid","kind":"CronJob","value":"payments"}]}}`
 
-			assert.Equal(t, tt.want, got)
-		})
-	}
+	mockClient.On("PatchMetadata", mock.Anything, mock.MatchedBy(func(job *metav1.PartialObjectMetadata) bool {
+		_, hasCostCenter := job.Labels["cost-center"]
+
+		_, hasReflectedList := job.Annotations[ReflectorLabelsReflectedAnnotation]
+
+		// the legacy reflected list is migrated to an ownership record of the cron job
+		return !hasCostCenter && !hasReflectedList &&
+			job.Annotations[ReflectorLabelsOwnershipAnnotation] == cronJobRecord
+	})).Return(nil)
+
+	mockClient.On("PatchMetadata", mock.Anything, mock.MatchedBy(func(pod *metav1.PartialObjectMetadata) bool {
+		return pod.Labels["team"] == "payments" &&
+			pod.Annotations[ReflectorLabelsOwnershipAnnotation] == cronJobRecord
+	})).Return(nil)
+
+	got, err := controller.ReconcileCronJob(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+
+	assert.Nil(t, err)
+	assert.Equal(t, ctrl.Result{}, got)
+	mockClient.AssertExpectations(t)
 }
 
-func TestController_FilterUpdateEvents(t *testing.T) {
-	type args struct {
-		e event.UpdateEvent
-	}
+func TestController_ReconcileJob(t *testing.T) {
 	tests := []struct {
-		name string
-		args args
+		name      string
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		wantErr   bool
+	}{
+		{
+			name: "Job not found",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetJob", mock.Anything, mock.Anything).
+					Return(nil, k8serrors.NewNotFound(schema.GroupResource{
+						Group:    "batch",
+						Resource: "jobs",
+					}, "test-job"))
+			},
+			wantErr: false,
+		},
+		{
+			name: "Failed to get job",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetJob", mock.Anything, mock.Anything).
+					Return(nil, errors.New("failed to get job"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Finished job without reflector annotations",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetJob", mock.Anything, mock.Anything).
+					Return(&batchv1.Job{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test-job",
+							Namespace: "default",
+						},
+						Status: batchv1.JobStatus{
+							Conditions: []batchv1.JobCondition{
+								{Type: batchv1.JobComplete, Status: v1.ConditionTrue},
+							},
+						},
+					}, nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+			config := &common.Config{}
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     config,
+			}
+			tt.mockSetup(mockClient)
+
+			got, err := controller.ReconcileJob(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "test-job"},
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, ctrl.Result{}, got)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_getManagedPods(t *testing.T) {
+	type args struct {
+		ctx        context.Context
+		deployment *appsv1.Deployment
+	}
+	tests := []struct {
+		name      string
+		args      args
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		want      *v1.PodList
+		wantErr   bool
+	}{
+		{
+			name: "Successfully get managed pods",
+			args: args{
+				deployment: &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-deployment",
+						Namespace: "default",
+					},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "test"},
+						},
+					},
+				},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+					Return(&v1.PodList{
+						Items: []v1.Pod{
+							{
+								ObjectMeta: metav1.ObjectMeta{
+									Name:        "pod1",
+									Namespace:   "default",
+									Labels:      map[string]string{"label1": "value1"},
+									Annotations: map[string]string{},
+								},
+							},
+						},
+					}, nil)
+			},
+			want: &v1.PodList{
+				Items: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "pod1",
+							Namespace:   "default",
+							Labels:      map[string]string{"label1": "value1"},
+							Annotations: map[string]string{},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Pods narrowed by target selector",
+			args: args{
+				deployment: &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-deployment",
+						Namespace: "default",
+						Annotations: map[string]string{
+							ReflectorTargetSelectorAnnotation: "track=canary",
+						},
+					},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "test"},
+						},
+					},
+				},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.MatchedBy(func(selector labels.Selector) bool {
+					return selector.String() == "app=test,track=canary"
+				})).Return(&v1.PodList{
+					Items: []v1.Pod{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:   "pod1",
+								Labels: map[string]string{"app": "test", "track": "canary"},
+							},
+						},
+					},
+				}, nil)
+			},
+			want: &v1.PodList{
+				Items: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:   "pod1",
+							Labels: map[string]string{"app": "test", "track": "canary"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid target selector",
+			args: args{
+				deployment: &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-deployment",
+						Namespace: "default",
+						Annotations: map[string]string{
+							ReflectorTargetSelectorAnnotation: "track in canary",
+						},
+					},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "test"},
+						},
+					},
+				},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      nil,
+			wantErr:   true,
+		},
+		{
+			name: "Empty pod selector",
+			args: args{
+				deployment: &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-deployment",
+						Namespace: "default",
+					},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{},
+					},
+				},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      nil,
+			wantErr:   true,
+		},
+		{
+			name: "Found no pods",
+			args: args{
+				deployment: &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-deployment",
+						Namespace: "default",
+					},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "test"},
+						},
+					},
+				},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+					Return(&v1.PodList{Items: []v1.Pod{}}, nil)
+			},
+			want:    &v1.PodList{Items: []v1.Pod{}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+			config := &common.Config{}
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     config,
+			}
+			tt.mockSetup(mockClient)
+
+			got, err := controller.getManagedPods(context.Background(), tt.args.deployment)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestController_FilterCreateEvents(t *testing.T) {
+	type args struct {
+		e event.CreateEvent
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "Event is not a Deployment",
+			args: args{
+				e: event.CreateEvent{
+					Object: &v1.Pod{},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Event contains reflector annotation",
+			args: args{
+				e: event.CreateEvent{
+					Object: &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "key",
+							},
+						},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Config map contains reflector annotation",
+			args: args{
+				e: event.CreateEvent{
+					Object: &metav1.PartialObjectMetadata{
+						TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: SourceKindConfigMap},
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "key",
+							},
+						},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Cron job contains reflector annotation",
+			args: args{
+				e: event.CreateEvent{
+					Object: &batchv1.CronJob{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorAnnotationsAnnotationDomain): "key",
+							},
+						},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Job contains reflector annotation",
+			args: args{
+				e: event.CreateEvent{
+					Object: &batchv1.Job{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "key",
+							},
+						},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Metadata of an unsupported kind",
+			args: args{
+				e: event.CreateEvent{
+					Object: &metav1.PartialObjectMetadata{
+						TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "key",
+							},
+						},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Deployment only has the status of the reflector",
+			args: args{
+				e: event.CreateEvent{
+					Object: &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{ReflectorStatusAnnotation: `{"targets":1}`},
+						},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Job only has an ownership record of the reflector",
+			args: args{
+				e: event.CreateEvent{
+					Object: &batchv1.Job{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{ReflectorLabelsOwnershipAnnotation: `{}`},
+						},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Event does not contain reflector annotation",
+			args: args{
+				e: event.CreateEvent{
+					Object: &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{},
+						},
+					},
+				},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+			config := &common.Config{}
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     config,
+			}
+
+			got := controller.FilterCreateEvents(tt.args.e)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestController_FilterUpdateEvents(t *testing.T) {
+	type args struct {
+		e event.UpdateEvent
+	}
+	tests := []struct {
+		name string
+		args args
 		want bool
 	}{
 		{
@@ -599,6 +1307,71 @@ func TestController_FilterUpdateEvents(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "Only the status of the deployment changed",
+			args: args{
+				e: event.UpdateEvent{
+					ObjectNew: &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "value",
+								ReflectorStatusAnnotation:                               `{"targets":2}`,
+							},
+						},
+					},
+					ObjectOld: &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "value",
+								ReflectorStatusAnnotation:                               `{"targets":1}`,
+							},
+						},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Status of a deployment without reflector annotations changed",
+			args: args{
+				e: event.UpdateEvent{
+					ObjectNew: &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{ReflectorStatusAnnotation: `{"targets":2}`},
+						},
+					},
+					ObjectOld: &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{ReflectorStatusAnnotation: `{"targets":1}`},
+						},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Ownership record of a job reflected to by its cron job changed",
+			args: args{
+				e: event.UpdateEvent{
+					ObjectNew: &batchv1.Job{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "value",
+								ReflectorLabelsOwnershipAnnotation:                      `{"team":{"source":"uid-1"}}`,
+							},
+						},
+					},
+					ObjectOld: &batchv1.Job{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "value",
+							},
+						},
+					},
+				},
+			},
+			want: false,
+		},
 		{
 			name: "Deployment annotations changed",
 			args: args{
@@ -636,12 +1409,48 @@ func TestController_FilterUpdateEvents(t *testing.T) {
 						},
 					},
 					ObjectOld: &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "value",
+							},
+						},
 						Status: appsv1.DeploymentStatus{
 							ReadyReplicas: 0,
 						},
 					},
 				},
 			},
+			// new pods are reflected to when they are created
+			want: false,
+		},
+		{
+			name: "Secret labels changed",
+			args: args{
+				e: event.UpdateEvent{
+					ObjectNew: &metav1.PartialObjectMetadata{
+						TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: SourceKindSecret},
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								"classification": "confidential",
+							},
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "classification",
+							},
+						},
+					},
+					ObjectOld: &metav1.PartialObjectMetadata{
+						TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: SourceKindSecret},
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								"classification": "internal",
+							},
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "classification",
+							},
+						},
+					},
+				},
+			},
 			want: true,
 		},
 		{
@@ -692,47 +1501,3 @@ func TestController_FilterUpdateEvents(t *testing.T) {
 		})
 	}
 }
-
-func TestController_shouldRequeueNow(t *testing.T) {
-	type args struct {
-		result ctrl.Result
-	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{
-			name: "Requeue now",
-			args: args{
-				result: ctrl.Result{RequeueAfter: 1 * time.Second},
-			},
-			want: true,
-		},
-		{
-			name: "Delay requeue",
-			args: args{
-				result: ctrl.Result{},
-			},
-			want: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			mockClient := new(mockKubernetesClient.MockKubernetesClient)
-
-			logger := zap.New()
-			config := &common.Config{}
-
-			controller := &Controller{
-				kubeClient: mockClient,
-				logger:     logger,
-				config:     config,
-			}
-
-			got := controller.shouldRequeueNow(tt.args.result)
-
-			assert.Equal(t, tt.want, got)
-		})
-	}
-}
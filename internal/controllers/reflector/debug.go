@@ -0,0 +1,57 @@
+package reflector
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PlanEndpointPath a path of the debug endpoint explaining reconciliation plans of sources.
+var PlanEndpointPath = "/debug/reflector/plan"
+
+/*
+PlanHandler serve explained plans of sources as JSON, e.g. /debug/reflector/plan?namespace=default&name=app.
+the kind of the source is given with the kind parameter and defaults to Deployment.
+*/
+func (r *Controller) PlanHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		kind := cmp.Or(query.Get("kind"), SourceKindDeployment)
+		namespacedName := types.NamespacedName{Namespace: query.Get("namespace"), Name: query.Get("name")}
+
+		if namespacedName.Namespace == "" || namespacedName.Name == "" {
+			http.Error(writer, "namespace and name parameters are required", http.StatusBadRequest)
+
+			return
+		}
+
+		explanation, explainErr := r.ExplainSource(request.Context(), kind, namespacedName)
+
+		switch {
+		case errors.Is(explainErr, ErrUnsupportedSource):
+			http.Error(writer, "kind "+kind+" is not a configured source kind", http.StatusBadRequest)
+
+			return
+		case k8serrors.IsNotFound(explainErr):
+			http.Error(writer, explainErr.Error(), http.StatusNotFound)
+
+			return
+		case explainErr != nil:
+			r.logger.Error(explainErr, "Failed to explain plan of source",
+				"kind", kind, "namespacedName", namespacedName)
+			http.Error(writer, explainErr.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		if encodeErr := json.NewEncoder(writer).Encode(explanation); encodeErr != nil {
+			r.logger.Error(encodeErr, "Failed to write plan of source", "kind", kind, "namespacedName", namespacedName)
+		}
+	})
+}
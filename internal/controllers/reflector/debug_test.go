@@ -0,0 +1,96 @@
+package reflector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_PlanHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		mockSetup  func(mockClient *mockKubernetesClient.MockKubernetesClient)
+		wantStatus int
+	}{
+		{
+			name:  "Explain a deployment",
+			query: "?namespace=default&name=test-deployment",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetDeployment", mock.Anything,
+					types.NamespacedName{Namespace: "default", Name: "test-deployment"}).
+					Return(&appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"},
+						Spec: appsv1.DeploymentSpec{
+							Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+						},
+					}, nil)
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+					Return(&v1.PodList{Items: []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}}}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Name is missing",
+			query:      "?namespace=default",
+			mockSetup:  func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Kind is not a configured source kind",
+			query:      "?kind=Secret&namespace=default&name=test-secret",
+			mockSetup:  func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Source is not found",
+			query: "?namespace=default&name=test-deployment",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetDeployment", mock.Anything, mock.Anything).
+					Return(nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "deployments"}, "test-deployment"))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     zap.New(),
+				config:     &common.Config{SourceKinds: []string{SourceKindDeployment}},
+			}
+			tt.mockSetup(mockClient)
+
+			recorder := httptest.NewRecorder()
+			controller.PlanHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, PlanEndpointPath+tt.query, nil))
+
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var explanation Explanation
+
+			assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&explanation))
+			assert.Equal(t, "test-deployment", explanation.Name)
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
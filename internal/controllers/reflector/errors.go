@@ -1,11 +1,105 @@
 package reflector
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/go-multierror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
 
 var (
-	ErrUnparsableAnnotation = errors.New("annotation cannot be parsed")
-	ErrUnparsableOperation  = errors.New("operation cannot be parsed")
-	ErrEmptyPodSelector     = errors.New("empty pod selector")
-	ErrPodNotFound          = errors.New("failed to find pods")
-	ErrPodsUpdateFailed     = errors.New("failed to update pods")
+	ErrUnparsableAnnotation      = errors.New("annotation cannot be parsed")
+	ErrUnparsableOperation       = errors.New("operation cannot be parsed")
+	ErrEmptyPodSelector          = errors.New("empty pod selector")
+	ErrPodsUpdateFailed          = errors.New("failed to update pods")
+	ErrUnsupportedTarget         = errors.New("unsupported target kind")
+	ErrUnsupportedSource         = errors.New("unsupported source kind")
+	ErrInvalidTargetSelector     = errors.New("invalid target selector")
+	ErrUnsupportedConflictPolicy = errors.New("unsupported conflict policy")
+	ErrKeyConflict               = errors.New("target already has a key with a different value")
+	ErrUnparsableOwnershipRecord = errors.New("ownership record cannot be parsed")
+	ErrInvalidPriority           = errors.New("invalid source priority")
+	ErrProtectedLabel            = errors.New("label is used by the controller of the target to select it")
+	ErrInvalidLabel              = errors.New("label key or value is not valid")
+	ErrSourceSelectorLabel       = fmt.Errorf("%w, the source selects its pods by it", ErrProtectedLabel)
+	ErrWriteBudgetSpent          = errors.New("write budget of the reconciliation is spent")
 )
+
+// errorClass a class of errors of a reconciliation, it decides whether and when the source is reconciled again.
+// classes are ordered by severity, a reconciliation with errors of several classes follows the most severe one.
+type errorClass int
+
+const (
+	// errorClassExpected a normal state that is not an error, e.g. a target deleted while it's written
+	errorClassExpected errorClass = iota
+	// errorClassPermanent an error that only a change of the source fixes, e.g. an invalid reflector annotation
+	errorClassPermanent
+	// errorClassTransient an error that can succeed when retried, e.g. a failed write or a conflict with a target
+	errorClassTransient
+)
+
+/*
+errors of the configuration of a source or of its targets that fail the same way until they are changed.
+conflicts with keys of a target and labels protected by the controller of a target depend on the state
+of the target rather than the source, so they are transient, unless the label is selected by the source itself.
+*/
+var permanentErrors = []error{
+	ErrUnparsableAnnotation, ErrUnparsableOperation, ErrEmptyPodSelector, ErrUnsupportedTarget,
+	ErrUnsupportedSource, ErrInvalidTargetSelector, ErrUnsupportedConflictPolicy,
+	ErrUnparsableOwnershipRecord, ErrInvalidPriority, ErrInvalidLabel, ErrSourceSelectorLabel,
+}
+
+func (c errorClass) String() string {
+	switch c {
+	case errorClassExpected:
+		return "expected"
+	case errorClassPermanent:
+		return "permanent"
+	default:
+		return "transient"
+	}
+}
+
+/*
+classify a single error of a reconciliation, errors that are not known to be expected or permanent are transient.
+targets deleted in the meantime are expected, metadata rejected by the API server is permanent.
+writes of targets changed in the meantime are conflicts, the client reports failed patches of stale targets as such.
+*/
+func classifyError(err error) errorClass {
+	isAny := func(knownErrors []error) bool {
+		return slices.ContainsFunc(knownErrors, func(knownErr error) bool {
+			return errors.Is(err, knownErr)
+		})
+	}
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return errorClassExpected
+	case isAny(permanentErrors) || apierrors.IsInvalid(err) || apierrors.IsBadRequest(err):
+		return errorClassPermanent
+	default:
+		return errorClassTransient
+	}
+}
+
+// classify errors of a reconciliation and get the most severe class along with the errors that are not expected.
+func classifyErrors(reconcileErr error) (errorClass, error) {
+	var remainingErrors *multierror.Error
+
+	class := errorClassExpected
+
+	// errors are aggregated from several phases of the reconciliation, nested aggregates are classified one by one
+	for _, classifiedErr := range multierror.Append(nil, multierror.Flatten(reconcileErr)).WrappedErrors() {
+		errClass := classifyError(classifiedErr)
+		if errClass == errorClassExpected {
+			continue
+		}
+
+		class = max(class, errClass)
+		remainingErrors = multierror.Append(remainingErrors, classifiedErr)
+	}
+
+	return class, remainingErrors.ErrorOrNil()
+}
@@ -0,0 +1,254 @@
+package reflector
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/go-multierror"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Explanation a reconciliation plan of a source, computed without writing anything.
+type Explanation struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// reflector annotations of the source and the keys each of them matches
+	Operations []OperationExplanation `json:"operations"`
+	// targets of the source and the changes the plan makes to them
+	Targets []TargetExplanation `json:"targets"`
+	// errors the reconciliation would fail with, targets are still planned when some of them fail
+	Errors []string `json:"errors,omitempty"`
+}
+
+// OperationExplanation keys of the source matched by a single reflector annotation.
+// keys that are blocked, invalid or protected are matched but not necessarily reflected.
+type OperationExplanation struct {
+	Annotation  string   `json:"annotation"`
+	Metadata    string   `json:"metadata"`
+	Operation   string   `json:"operation"`
+	Value       string   `json:"value"`
+	MatchedKeys []string `json:"matchedKeys"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// TargetExplanation changes the plan makes to the metadata of a target.
+type TargetExplanation struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// the target selector of the source excludes the target, reflected metadata is only removed from it
+	Excluded bool `json:"excluded,omitempty"`
+	// the target is written when the plan is applied
+	Changed     bool        `json:"changed"`
+	Labels      []KeyChange `json:"labels,omitempty"`
+	Annotations []KeyChange `json:"annotations,omitempty"`
+}
+
+// KeyChange a change of a single key, there is no prior value of an added key and no new value of a removed one.
+type KeyChange struct {
+	Key  string  `json:"key"`
+	From *string `json:"from,omitempty"`
+	To   *string `json:"to,omitempty"`
+}
+
+// metadata of a target before it is planned.
+type metadataSnapshot struct {
+	labels      map[string]string
+	annotations map[string]string
+}
+
+/*
+Explain plan the metadata of targets of the source the way a reconciliation does, nothing is written.
+events are not recorded and metrics are not counted, so a plan can be explained any number of times.
+an error is returned only when the targets cannot be listed, other errors are part of the explanation.
+*/
+func (r *Controller) Explain(ctx context.Context, source client.Object) (*Explanation, error) {
+	explainer := *r
+	explainer.logger = logr.Discard()
+	explainer.recorder = discardRecorder{}
+	explainer.dryRun = true
+
+	plan, listErr := explainer.listPlanTargets(ctx, source)
+	if plan == nil {
+		return nil, listErr
+	}
+
+	snapshots := make(map[client.Object]metadataSnapshot, len(plan.targets)+len(plan.excludedTargets))
+	for _, target := range slices.Concat(plan.targets, plan.excludedTargets) {
+		snapshots[target] = metadataSnapshot{
+			labels:      maps.Clone(target.GetLabels()),
+			annotations: maps.Clone(target.GetAnnotations()),
+		}
+	}
+
+	planErr := explainer.planTargets(ctx, source, plan)
+
+	explanation := &Explanation{
+		Kind:       sourceKind(source),
+		Namespace:  source.GetNamespace(),
+		Name:       source.GetName(),
+		Operations: MatchOperations(source),
+		Targets:    make([]TargetExplanation, 0, len(snapshots)),
+	}
+
+	for _, target := range plan.targets {
+		explanation.Targets = append(explanation.Targets, explainTarget(plan, target, snapshots[target], false))
+	}
+
+	for _, target := range plan.excludedTargets {
+		explanation.Targets = append(explanation.Targets, explainTarget(plan, target, snapshots[target], true))
+	}
+
+	for _, explainedErr := range multierror.Append(nil, listErr, planErr).WrappedErrors() {
+		explanation.Errors = append(explanation.Errors, explainedErr.Error())
+	}
+
+	return explanation, nil
+}
+
+// ExplainSource explain the plan of a source of one of the configured kinds.
+func (r *Controller) ExplainSource(
+	ctx context.Context, kind string, namespacedName types.NamespacedName,
+) (*Explanation, error) {
+	if !slices.Contains(r.config.Current().SourceKinds, kind) {
+		return nil, ErrUnsupportedSource
+	}
+
+	source, getSourceErr := r.getSource(ctx, kind, namespacedName)
+	if getSourceErr != nil {
+		return nil, getSourceErr
+	}
+
+	return r.Explain(ctx, source)
+}
+
+// get a source of the given kind, only metadata of config maps and secrets is read.
+func (r *Controller) getSource(
+	ctx context.Context, kind string, namespacedName types.NamespacedName,
+) (client.Object, error) {
+	switch kind {
+	case SourceKindDeployment:
+		return r.kubeClient.GetDeployment(ctx, namespacedName)
+	case SourceKindCronJob:
+		return r.kubeClient.GetCronJob(ctx, namespacedName)
+	case SourceKindJob:
+		return r.kubeClient.GetJob(ctx, namespacedName)
+	case SourceKindConfigMap, SourceKindSecret:
+		return r.kubeClient.GetMetadata(ctx, v1.SchemeGroupVersion.WithKind(kind), namespacedName)
+	default:
+		return nil, ErrUnsupportedSource
+	}
+}
+
+// MatchOperations get keys of the source matched by each of its reflector annotations, ordered by the annotation.
+func MatchOperations(source metav1.Object) []OperationExplanation {
+	operations := []OperationExplanation{}
+
+	for _, domain := range supportedAnnotationDomains() {
+		reflectorAnnotations := FindReflectorAnnotations(domain, source)
+
+		sourceKeys := source.GetLabels()
+		if domain == ReflectorAnnotationsAnnotationDomain {
+			sourceKeys = source.GetAnnotations()
+		}
+
+		for _, annotation := range slices.Sorted(maps.Keys(reflectorAnnotations)) {
+			value := reflectorAnnotations[annotation]
+
+			operation := OperationExplanation{
+				Annotation:  annotation,
+				Metadata:    strings.SplitN(domain, ".", 2)[0],
+				Operation:   annotation[strings.LastIndex(annotation, "/")+1:],
+				Value:       value,
+				MatchedKeys: []string{},
+			}
+
+			matchedKeys, matchErr := KeysToReflect(map[string]string{annotation: value}, sourceKeys)
+			if matchErr != nil {
+				operation.Error = matchErr.Error()
+			}
+
+			// bookkeeping annotations are never reflected, even when an operation matches them
+			for _, bookkeepingAnnotation := range bookkeepingAnnotations() {
+				delete(matchedKeys, bookkeepingAnnotation)
+			}
+
+			if domain == ReflectorAnnotationsAnnotationDomain {
+				dropReflectorAnnotations(matchedKeys)
+			}
+
+			operation.MatchedKeys = append(operation.MatchedKeys, slices.Sorted(maps.Keys(matchedKeys))...)
+			operations = append(operations, operation)
+		}
+	}
+
+	return operations
+}
+
+func explainTarget(
+	plan *reflectionPlan, target client.Object, snapshot metadataSnapshot, excluded bool,
+) TargetExplanation {
+	_, changed := plan.changed[target]
+
+	return TargetExplanation{
+		Kind:        targetKind(target),
+		Namespace:   target.GetNamespace(),
+		Name:        target.GetName(),
+		Excluded:    excluded,
+		Changed:     changed,
+		Labels:      diffKeys(snapshot.labels, target.GetLabels()),
+		Annotations: diffKeys(snapshot.annotations, target.GetAnnotations()),
+	}
+}
+
+// get changes between two versions of labels or annotations, ordered by the key.
+func diffKeys(before map[string]string, after map[string]string) []KeyChange {
+	var changes []KeyChange
+
+	keys := slices.Sorted(maps.Keys(before))
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		beforeValue, inBefore := before[key]
+		afterValue, inAfter := after[key]
+
+		if inBefore && inAfter && beforeValue == afterValue {
+			continue
+		}
+
+		change := KeyChange{Key: key}
+		if inBefore {
+			change.From = &beforeValue
+		}
+
+		if inAfter {
+			change.To = &afterValue
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// an event recorder that drops events, plans are explained without reporting anything on sources.
+type discardRecorder struct{}
+
+func (discardRecorder) Eventf(
+	_ runtime.Object, _ runtime.Object, _ string, _ string, _ string, _ string, _ ...interface{},
+) {
+}
@@ -0,0 +1,182 @@
+package reflector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_Explain(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config:     &common.Config{DeniedKeys: []string{"secret"}},
+		recorder:   recorder,
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			UID:       "deployment-uid",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain):       "team,secret",
+				fmt.Sprintf("%s/regex", ReflectorAnnotationsAnnotationDomain): "owner.*",
+				"owner":   "payments@example.com",
+				"unknown": "value",
+			},
+			Labels: map[string]string{"team": "payments", "secret": "value", "app": "test"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	}
+
+	mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+		Return(&v1.PodList{Items: []v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{
+				Name: "pod1", Namespace: "default", Labels: map[string]string{"app": "test", "team": "checkout"},
+			}},
+		}}, nil)
+
+	counter := blockedKeysTotal.WithLabelValues(SourceKindDeployment, "labels")
+	countBefore := testutil.ToFloat64(counter)
+
+	explanation, err := controller.Explain(context.Background(), deployment)
+
+	assert.Nil(t, err)
+	assert.Equal(t, SourceKindDeployment, explanation.Kind)
+	assert.Equal(t, []OperationExplanation{
+		{
+			Annotation:  fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain),
+			Metadata:    "labels",
+			Operation:   ReflectorOperationList,
+			Value:       "team,secret",
+			MatchedKeys: []string{"secret", "team"},
+		},
+		{
+			Annotation:  fmt.Sprintf("%s/regex", ReflectorAnnotationsAnnotationDomain),
+			Metadata:    "annotations",
+			Operation:   ReflectorOperationRegex,
+			Value:       "owner.*",
+			MatchedKeys: []string{"owner"},
+		},
+	}, explanation.Operations)
+
+	assert.Len(t, explanation.Targets, 1)
+
+	target := explanation.Targets[0]
+	assert.Equal(t, "Pod", target.Kind)
+	assert.Equal(t, "pod1", target.Name)
+	assert.True(t, target.Changed)
+	assert.Contains(t, target.Labels, KeyChange{Key: "team", From: ptr.To("checkout"), To: ptr.To("payments")})
+	assert.NotContains(t, target.Labels, KeyChange{Key: "secret", To: ptr.To("value")})
+	assert.Contains(t, target.Annotations, KeyChange{Key: "owner", To: ptr.To("payments@example.com")})
+	assert.Empty(t, explanation.Errors)
+
+	// nothing is written or reported
+	mockClient.AssertNotCalled(t, "PatchMetadata", mock.Anything, mock.Anything)
+	assert.Empty(t, recorder.Events)
+	assert.Equal(t, countBefore, testutil.ToFloat64(counter))
+}
+
+func TestController_ExplainSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		mockSetup func(mockClient *mockKubernetesClient.MockKubernetesClient)
+		wantErr   error
+	}{
+		{
+			name: "Explain a deployment",
+			kind: SourceKindDeployment,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetDeployment", mock.Anything, mock.Anything).Return(&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+					},
+				}, nil)
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+					Return(&v1.PodList{Items: []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}}}, nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name:      "Kind is not a configured source kind",
+			kind:      SourceKindSecret,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			wantErr:   ErrUnsupportedSource,
+		},
+		{
+			name: "Targets cannot be listed",
+			kind: SourceKindDeployment,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetDeployment", mock.Anything, mock.Anything).Return(&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+					},
+				}, nil)
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).Return(nil, errListPods)
+			},
+			wantErr: errListPods,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     zap.New(),
+				config:     &common.Config{SourceKinds: []string{SourceKindDeployment}},
+			}
+			tt.mockSetup(mockClient)
+
+			explanation, err := controller.ExplainSource(context.Background(), tt.kind,
+				types.NamespacedName{Namespace: "default", Name: "test-deployment"})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, explanation)
+
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, "test-deployment", explanation.Name)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_diffKeys(t *testing.T) {
+	before := map[string]string{"kept": "value", "changed": "old", "removed": "value"}
+	after := map[string]string{"kept": "value", "changed": "new", "added": "value"}
+
+	assert.Equal(t, []KeyChange{
+		{Key: "added", To: ptr.To("value")},
+		{Key: "changed", From: ptr.To("old"), To: ptr.To("new")},
+		{Key: "removed", From: ptr.To("value")},
+	}, diffKeys(before, after))
+	assert.Empty(t, diffKeys(before, before))
+}
+
+var errListPods = errors.New("failed to list pods")
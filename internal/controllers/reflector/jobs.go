@@ -0,0 +1,112 @@
+package reflector
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+get a list of jobs spawned by the cron job and pods of these jobs.
+only jobs that haven't finished yet are returned as there is nothing to keep in sync on finished ones.
+it's normal for a cron job not to have any running jobs, so an empty list is not an error.
+*/
+func (r *Controller) getCronJobTargets(ctx context.Context, cronJob *batchv1.CronJob) ([]client.Object, error) {
+	jobs, jobListError := r.kubeClient.ListJobs(ctx, cronJob.Namespace)
+	if jobListError != nil {
+		return nil, jobListError
+	}
+
+	var targets []client.Object
+
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+
+		if !metav1.IsControlledBy(job, cronJob) || r.isJobFinished(job) {
+			continue
+		}
+
+		pods, podListError := r.getJobPods(ctx, job)
+		if podListError != nil {
+			return nil, podListError
+		}
+
+		targets = append(targets, job)
+		targets = append(targets, podsAsTargets(pods)...)
+	}
+
+	r.logger.V(1).Info("Found cron job targets",
+		"count", len(targets), "cronJob", cronJob.Name)
+
+	return targets, nil
+}
+
+// get a list of pods of the job, a finished job doesn't have any targets.
+func (r *Controller) getJobTargets(ctx context.Context, job *batchv1.Job) ([]client.Object, error) {
+	if r.isJobFinished(job) {
+		return nil, nil
+	}
+
+	pods, podListError := r.getJobPods(ctx, job)
+	if podListError != nil {
+		return nil, podListError
+	}
+
+	return podsAsTargets(pods), nil
+}
+
+// get a list of pods controlled by the job.
+func (r *Controller) getJobPods(ctx context.Context, job *batchv1.Job) (*v1.PodList, error) {
+	jobPods := &v1.PodList{}
+
+	if job.Spec.Selector == nil {
+		return jobPods, nil
+	}
+
+	podSelector, selectorErr := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if selectorErr != nil {
+		r.logger.Error(selectorErr, "Failed to get job pods", "job", job.Name)
+
+		return nil, selectorErr
+	}
+
+	if podSelector.Empty() {
+		r.logger.Error(ErrEmptyPodSelector,
+			"Cannot get job pods as the selector would match everything", "job", job.Name)
+
+		return nil, ErrEmptyPodSelector
+	}
+
+	pods, podListError := r.kubeClient.ListPods(ctx, job.Namespace, podSelector)
+	if podListError != nil {
+		return nil, podListError
+	}
+
+	// the selector is generated from the job uid, ownership check is there to be sure
+	// that manually defined selectors don't match pods of other jobs
+	for _, pod := range pods.Items {
+		if metav1.IsControlledBy(&pod, job) {
+			jobPods.Items = append(jobPods.Items, pod)
+		}
+	}
+
+	return jobPods, nil
+}
+
+// check whether the job has completed or failed.
+func (r *Controller) isJobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+
+		if condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed {
+			return true
+		}
+	}
+
+	return false
+}
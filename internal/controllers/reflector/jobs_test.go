@@ -0,0 +1,258 @@
+package reflector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func controllerReference(kind string, name string, uid types.UID) []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		{
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+			Kind:       kind,
+			Name:       name,
+			UID:        uid,
+			Controller: ptr.To(true),
+		},
+	}
+}
+
+func TestController_getCronJobTargets(t *testing.T) {
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cronjob",
+			Namespace: "default",
+			UID:       "cronjob-uid",
+		},
+	}
+
+	runningJob := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-cronjob-1",
+			Namespace:       "default",
+			UID:             "running-job-uid",
+			OwnerReferences: controllerReference("CronJob", "test-cronjob", "cronjob-uid"),
+		},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"batch.kubernetes.io/controller-uid": "running-job-uid"},
+			},
+		},
+	}
+
+	finishedJob := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-cronjob-0",
+			Namespace:       "default",
+			UID:             "finished-job-uid",
+			OwnerReferences: controllerReference("CronJob", "test-cronjob", "cronjob-uid"),
+		},
+		Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: v1.ConditionTrue},
+			},
+		},
+	}
+
+	unrelatedJob := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "other-cronjob-1",
+			Namespace:       "default",
+			UID:             "other-job-uid",
+			OwnerReferences: controllerReference("CronJob", "other-cronjob", "other-cronjob-uid"),
+		},
+	}
+
+	jobPod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-cronjob-1-abcde",
+			Namespace:       "default",
+			OwnerReferences: controllerReference("Job", "test-cronjob-1", "running-job-uid"),
+		},
+	}
+
+	strayPod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stray-pod",
+			Namespace: "default",
+		},
+	}
+
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	mockClient.On("ListJobs", mock.Anything, "default").
+		Return(&batchv1.JobList{Items: []batchv1.Job{runningJob, finishedJob, unrelatedJob}}, nil)
+	mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+		Return(&v1.PodList{Items: []v1.Pod{jobPod, strayPod}}, nil)
+
+	got, err := controller.getCronJobTargets(context.Background(), cronJob)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []client.Object{&runningJob, &jobPod}, got)
+	mockClient.AssertExpectations(t)
+}
+
+func TestController_getJobTargets(t *testing.T) {
+	tests := []struct {
+		name      string
+		job       *batchv1.Job
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		want      []client.Object
+	}{
+		{
+			name: "Running job",
+			job: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job", Namespace: "default", UID: "job-uid"},
+				Spec: batchv1.JobSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"batch.kubernetes.io/controller-uid": "job-uid"},
+					},
+				},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListPods", mock.Anything, "default", mock.Anything).
+					Return(&v1.PodList{
+						Items: []v1.Pod{
+							{
+								ObjectMeta: metav1.ObjectMeta{
+									Name:            "test-job-abcde",
+									OwnerReferences: controllerReference("Job", "test-job", "job-uid"),
+								},
+							},
+						},
+					}, nil)
+			},
+			want: []client.Object{
+				&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "test-job-abcde",
+						OwnerReferences: controllerReference("Job", "test-job", "job-uid"),
+					},
+				},
+			},
+		},
+		{
+			name: "Failed job",
+			job: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job", UID: "job-uid"},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{
+						{Type: batchv1.JobFailed, Status: v1.ConditionTrue},
+					},
+				},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      nil,
+		},
+		{
+			name:      "Job without a selector",
+			job:       &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "test-job", UID: "job-uid"}},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      []client.Object{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+			config := &common.Config{}
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     config,
+			}
+			tt.mockSetup(mockClient)
+
+			got, err := controller.getJobTargets(context.Background(), tt.job)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_isJobFinished(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	tests := []struct {
+		name       string
+		conditions []batchv1.JobCondition
+		want       bool
+	}{
+		{
+			name:       "No conditions",
+			conditions: nil,
+			want:       false,
+		},
+		{
+			name: "Completed",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: v1.ConditionTrue},
+			},
+			want: true,
+		},
+		{
+			name: "Failed",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: v1.ConditionTrue},
+			},
+			want: true,
+		},
+		{
+			name: "Suspended",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobSuspended, Status: v1.ConditionTrue},
+			},
+			want: false,
+		},
+		{
+			name: "Completion condition is not true",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: v1.ConditionFalse},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &batchv1.Job{Status: batchv1.JobStatus{Conditions: tt.conditions}}
+
+			got := controller.isJobFinished(job)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -2,199 +2,226 @@ package reflector
 
 import (
 	"context"
-	"strings"
+	"maps"
+	"slices"
 
 	"github.com/NCCloud/metadata-reflector/internal/common"
 	"github.com/hashicorp/go-multierror"
 
-	appsv1 "k8s.io/api/apps/v1"
-	v1 "k8s.io/api/core/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *Controller) reconcileLabels(ctx context.Context, deployment *appsv1.Deployment) (ctrl.Result, error) {
+// plan labels of the targets of the source.
+func (r *Controller) reconcileLabels(ctx context.Context, source client.Object, plan *reflectionPlan) error {
 	r.logger.V(1).Info("Starting label reconciliation",
-		"deployment", deployment.Name, "namespace", deployment.Namespace)
+		"kind", sourceKind(source), "source", source.GetName(), "namespace", source.GetNamespace())
 	defer r.logger.V(1).Info("Finished label reconciliation",
-		"deployment", deployment.Name, "namespace", deployment.Namespace)
+		"kind", sourceKind(source), "source", source.GetName(), "namespace", source.GetNamespace())
 
-	var (
-		labelReflectResult ctrl.Result
-		labelReflectError  error
-	)
-
-	if common.MapHasPrefix(ReflectorLabelsAnnotationDomain, deployment.Annotations) {
-		labelReflectResult, labelReflectError = r.reflectLabels(ctx, deployment)
-	} else {
-		labelReflectResult, labelReflectError = r.unsetReflectedLabels(ctx, deployment)
+	if len(r.findReflectorAnnotations(ReflectorLabelsAnnotationDomain, source)) > 0 {
+		return r.reflectLabels(ctx, source, plan)
 	}
 
-	return labelReflectResult, labelReflectError
+	return r.unsetReflectedLabels(ctx, source, plan)
 }
 
-// reflect configuration from deployment to managed pods.
-func (r *Controller) reflectLabels(ctx context.Context, deployment *appsv1.Deployment,
-) (ctrl.Result, error) {
-	deploymentName := deployment.Name
+// reflect configuration from the source to targets of the plan.
+func (r *Controller) reflectLabels(ctx context.Context, source client.Object, plan *reflectionPlan) error {
+	sourceName := source.GetName()
 
 	// a map of reflector annotations present on the object
-	reflectorAnnotations := common.FindPartialKeys(
-		ReflectorLabelsAnnotationDomain, deployment.Annotations)
+	reflectorAnnotations := r.findReflectorAnnotations(ReflectorLabelsAnnotationDomain, source)
 
 	labelsToReflect, labelsErr := r.keysToReflect(
-		reflectorAnnotations, deployment.Labels)
+		reflectorAnnotations, source.GetLabels())
 	if labelsErr != nil {
-		r.logger.Error(labelsErr, "Could not get labels to reflect", "deployment", deploymentName)
+		r.logger.Error(labelsErr, "Could not get labels to reflect", "kind", sourceKind(source), "source", sourceName)
 
-		return ctrl.Result{}, labelsErr
+		return labelsErr
 	}
 
+	labelsToReflect = r.dropBlockedKeys(source, "labels", labelsToReflect)
+
+	// invalid labels are reported once valid labels are reflected
+	labelsToReflect, invalidLabelsErr := r.validateLabels(source, labelsToReflect)
+	reflectErrors := multierror.Append(nil, invalidLabelsErr)
+
 	// nothing to reflect, let's try to unset reflected labels
 	if len(labelsToReflect) == 0 {
-		return r.unsetReflectedLabels(ctx, deployment)
+		return multierror.Append(reflectErrors, r.unsetReflectedLabels(ctx, source, plan)).ErrorOrNil()
 	}
 
-	reflectedAnnotations := r.getReflectorAnnForLabels(common.MapKeysAsString(labelsToReflect))
-
-	pods, podListError := r.getManagedPods(ctx, deployment)
-	if podListError != nil {
-		r.logger.Error(podListError,
-			"Error listing pods for deployment",
-			"deployment", deploymentName,
-		)
-
-		return ctrl.Result{}, podListError
+	conflictPolicy, conflictPolicyErr := r.getConflictPolicy(source)
+	if conflictPolicyErr != nil {
+		return multierror.Append(reflectErrors, conflictPolicyErr).ErrorOrNil()
 	}
 
-	var podUpdateErrors *multierror.Error
-
-	for _, pod := range pods.Items {
-		shouldUpdatePod := false
+	priority, priorityErr := r.getSourcePriority(source)
+	if priorityErr != nil {
+		return multierror.Append(reflectErrors, priorityErr).ErrorOrNil()
+	}
 
-		if labelsUpdated := r.setLabels(labelsToReflect, &pod); labelsUpdated {
-			shouldUpdatePod = true
+	for _, target := range plan.labelTargets() {
+		shouldUpdateTarget, reflectErr := r.reflectLabelsToTarget(
+			ctx, source, target, labelsToReflect, conflictPolicy, priority)
+		if reflectErr != nil {
+			reflectErrors = multierror.Append(reflectErrors, reflectErr)
 		}
 
-		if excessiveLabelsUnset := r.unsetExcessiveLabels(labelsToReflect, &pod); excessiveLabelsUnset {
-			shouldUpdatePod = true
+		if shouldUpdateTarget {
+			plan.markChanged(target)
 		}
+	}
 
-		if annotationsUpdated := r.setAnnotations(reflectedAnnotations, &pod); annotationsUpdated {
-			shouldUpdatePod = true
-		}
+	return reflectErrors.ErrorOrNil()
+}
 
-		if !shouldUpdatePod {
-			continue
-		}
+/*
+reflect labels to a single target in memory respecting the conflict policy.
+returns whether the target needs to be updated, with the fail policy a conflict
+leaves the target untouched and is returned as an error.
+protected labels are never reflected, they are returned as an error while other labels are still reflected.
+*/
+func (r *Controller) reflectLabelsToTarget(
+	ctx context.Context, source client.Object, target client.Object, labelsToReflect map[string]string,
+	conflictPolicy string, priority int,
+) (bool, error) {
+	protectedLabels, protectedErr := r.getProtectedLabels(ctx, source, target)
+	if protectedErr != nil {
+		return false, protectedErr
+	}
 
-		updateErr := r.kubeClient.UpdatePod(ctx, pod)
-		if updateErr != nil {
-			r.logger.Error(updateErr, "Failed to update pod metadata",
-				"pod", pod.Name,
-			)
+	labelsToReflect, protectedLabelsErr := r.dropProtectedLabels(source, target, labelsToReflect, protectedLabels)
 
-			podUpdateErrors = multierror.Append(podUpdateErrors, updateErr)
-		}
+	record, recordErr := r.getOwnershipRecord(
+		source, target, target.GetLabels(), ReflectorLabelsOwnershipAnnotation, ReflectorLabelsReflectedAnnotation)
+	if recordErr != nil {
+		return false, recordErr
 	}
 
-	return ctrl.Result{}, podUpdateErrors.ErrorOrNil()
-}
+	labelsToSet, conflicts := r.resolveConflicts(
+		labelsToReflect, target.GetLabels(), record, conflictPolicy)
+	if len(conflicts) > 0 {
+		r.reportConflicts(source, target, "labels", conflictPolicy, conflicts)
 
-func (r *Controller) unsetReflectedLabels(ctx context.Context, deployment *appsv1.Deployment,
-) (ctrl.Result, error) {
-	deploymentName := deployment.Name
+		if conflictPolicy == ConflictPolicyFail {
+			return false, multierror.Append(nil, protectedLabelsErr, ErrKeyConflict).ErrorOrNil()
+		}
+	}
 
-	pods, podListError := r.getManagedPods(ctx, deployment)
-	if podListError != nil {
-		r.logger.Error(podListError,
-			"Error listing pods for deployment",
-			"deployment", deploymentName,
-		)
+	// labels the source reflected before but doesn't reflect anymore
+	excessiveLabels := common.ExcessiveElements(
+		common.MapKeysAsSlice(labelsToReflect), record.keysClaimedBy(source.GetUID()))
+	labelsRestored := r.restoreLabels(record, source.GetUID(), excessiveLabels, protectedLabels, target)
 
-		return ctrl.Result{}, podListError
+	// prior values are recorded before they are overwritten, the value with the highest precedence is set
+	sourceClaim := KeyClaim{Source: source.GetUID(), Kind: sourceKind(source), Priority: priority}
+	labelsToClaim := record.claim(sourceClaim, labelsToSet, target.GetLabels())
+	labelsUpdated := r.setLabels(labelsToClaim, target)
+
+	recordUpdated, recordErr := r.setOwnershipRecord(
+		record, target, ReflectorLabelsOwnershipAnnotation, ReflectorLabelsReflectedAnnotation)
+	if recordErr != nil {
+		return false, recordErr
 	}
 
-	var podUpdateErrors *multierror.Error
+	return labelsRestored || labelsUpdated || recordUpdated, protectedLabelsErr
+}
 
-	for _, pod := range pods.Items {
-		annotationValue, hasReflectorAnnotation := pod.Annotations[ReflectorLabelsReflectedAnnotation]
-		// if the annotation is not present, configuration is either already unset
-		// or the annotation was deleted manually and we don't know what labels to remove
-		if !hasReflectorAnnotation {
-			continue
-		}
+// restore labels the source has reflected to targets of the plan.
+func (r *Controller) unsetReflectedLabels(ctx context.Context, source client.Object, plan *reflectionPlan) error {
+	var recordErrors *multierror.Error
 
-		shouldUpdatePod := false
+	for _, target := range plan.labelTargets() {
+		targetUpdated, recordErr := r.unsetReflectedLabelsFromTarget(ctx, source, target)
+		if recordErr != nil {
+			recordErrors = multierror.Append(recordErrors, recordErr)
 
-		labelsToUnset := strings.Split(annotationValue, ",")
-		if labelsUpdated := r.unsetLabels(labelsToUnset, &pod); labelsUpdated {
-			shouldUpdatePod = true
+			continue
 		}
 
-		annotationsToUnset := []string{ReflectorLabelsReflectedAnnotation}
-		if annotationsUpdated := r.unsetAnnotations(annotationsToUnset, &pod); annotationsUpdated {
-			shouldUpdatePod = true
+		if targetUpdated {
+			plan.markChanged(target)
 		}
+	}
 
-		if !shouldUpdatePod {
-			continue
-		}
+	return recordErrors.ErrorOrNil()
+}
 
-		updateErr := r.kubeClient.UpdatePod(ctx, pod)
-		if updateErr != nil {
-			r.logger.Error(updateErr, "Failed to unset metadata from pod",
-				"pod", pod.Name,
-			)
+// restore all labels the source has reflected to the target and remove them from the ownership record.
+// returns whether the target was updated.
+func (r *Controller) unsetReflectedLabelsFromTarget(
+	ctx context.Context, source client.Object, target client.Object,
+) (bool, error) {
+	protectedLabels, protectedErr := r.getProtectedLabels(ctx, source, target)
+	if protectedErr != nil {
+		return false, protectedErr
+	}
 
-			podUpdateErrors = multierror.Append(podUpdateErrors, updateErr)
-		}
+	// if there is no record, configuration is either already unset
+	// or the record was deleted manually and we don't know what labels to restore
+	record, recordErr := r.getOwnershipRecord(
+		source, target, target.GetLabels(), ReflectorLabelsOwnershipAnnotation, ReflectorLabelsReflectedAnnotation)
+	if recordErr != nil {
+		return false, recordErr
 	}
 
-	return ctrl.Result{}, podUpdateErrors.ErrorOrNil()
+	labelsRestored := r.restoreLabels(
+		record, source.GetUID(), record.keysClaimedBy(source.GetUID()), protectedLabels, target)
+
+	recordUpdated, recordErr := r.setOwnershipRecord(
+		record, target, ReflectorLabelsOwnershipAnnotation, ReflectorLabelsReflectedAnnotation)
+
+	return labelsRestored || recordUpdated, recordErr
 }
 
-// reflect a list of labels from deployment to the pod.
-// return whether any pod label was updated.
-func (r *Controller) setLabels(labels map[string]string, pod *v1.Pod) bool {
-	podUpdated := false
+// reflect a list of labels from the source to the target.
+// return whether any target label was updated.
+func (r *Controller) setLabels(labels map[string]string, target metav1.Object) bool {
+	targetUpdated := false
 
-	if pod.Labels == nil {
-		pod.Labels = make(map[string]string)
+	targetLabels := target.GetLabels()
+	if targetLabels == nil {
+		targetLabels = make(map[string]string)
+		target.SetLabels(targetLabels)
 	}
 
 	for key, value := range labels {
-		podLabelValue, ok := pod.Labels[key]
-		if ok && value == podLabelValue {
+		targetLabelValue, ok := targetLabels[key]
+		if ok && value == targetLabelValue {
 			continue
 		}
 
-		r.logger.Info("Setting pod label", "pod",
-			pod.Name, "label", key, "value", value)
+		r.logger.Info("Setting target label", "target",
+			target.GetName(), "label", key, "value", value)
 
-		pod.Labels[key] = value
-		podUpdated = true
+		targetLabels[key] = value
+		targetUpdated = true
 	}
 
-	return podUpdated
+	return targetUpdated
 }
 
-// unset managed labels from a pod.
+// unset managed labels from a target.
 // returns whether any label was unset.
-func (r *Controller) unsetLabels(labels []string, pod *v1.Pod) bool {
+func (r *Controller) unsetLabels(labels []string, target metav1.Object) bool {
 	anyLabelDeleted := false
 
-	if pod.Labels == nil {
+	targetLabels := target.GetLabels()
+	if targetLabels == nil {
 		return anyLabelDeleted
 	}
 
 	for _, label := range labels {
-		if _, ok := pod.Labels[label]; !ok {
+		if _, ok := targetLabels[label]; !ok {
 			continue
 		}
 
-		r.logger.Info("Unsetting label from pod", "pod", pod.Name, "label", label)
+		r.logger.Info("Unsetting label from target", "target", target.GetName(), "label", label)
 
-		delete(pod.Labels, label)
+		delete(targetLabels, label)
 
 		anyLabelDeleted = true
 	}
@@ -202,36 +229,21 @@ func (r *Controller) unsetLabels(labels []string, pod *v1.Pod) bool {
 	return anyLabelDeleted
 }
 
-// unset excessive labels given the labels we expect to be present on an object.
-// returns if any label was unset.
-func (r *Controller) unsetExcessiveLabels(labelsToReflect map[string]string, pod *v1.Pod) bool {
-	var labelsToUnset []string
-
-	podReflectedAnnValue, podReflectedAnnExists := pod.Annotations[ReflectorLabelsReflectedAnnotation]
-	if !podReflectedAnnExists {
-		return false
-	}
+// release labels claimed by the source, labels claimed by other sources take the value of the next claim,
+// the rest are restored to the values they had before they were reflected or unset if they weren't present.
+// protected labels are only released from the record and left as they are on the target.
+// returns whether any label was updated.
+func (r *Controller) restoreLabels(
+	record ownershipRecord, sourceUID types.UID, labels []string, protectedLabels []string, target metav1.Object,
+) bool {
+	valuesToSet, labelsToUnset := record.release(sourceUID, labels)
 
-	currentKeys := strings.Split(podReflectedAnnValue, ",")
-	expectedKeys := common.MapKeysAsSlice(labelsToReflect)
-	labelsToUnset = common.ExcessiveElements(expectedKeys, currentKeys)
-
-	if len(labelsToUnset) == 0 {
-		return false
-	}
-
-	if labelsUpdated := r.unsetLabels(labelsToUnset, pod); labelsUpdated {
-		return labelsUpdated
-	}
-
-	return false
-}
+	isProtected := func(label string) bool { return slices.Contains(protectedLabels, label) }
+	maps.DeleteFunc(valuesToSet, func(label string, _ string) bool { return isProtected(label) })
+	labelsToUnset = slices.DeleteFunc(labelsToUnset, isProtected)
 
-// generate a map of annotations that help identify what labels
-// were set by the reflector on the managed object.
-func (r *Controller) getReflectorAnnForLabels(labelsToReflect string) map[string]string {
-	annotationsToReflect := make(map[string]string)
-	annotationsToReflect[ReflectorLabelsReflectedAnnotation] = labelsToReflect
+	labelsRestored := len(valuesToSet) > 0 && r.setLabels(valuesToSet, target)
+	labelsUnset := r.unsetLabels(labelsToUnset, target)
 
-	return annotationsToReflect
+	return labelsRestored || labelsUnset
 }
@@ -2,31 +2,30 @@ package reflector
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/NCCloud/metadata-reflector/internal/common"
 	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 func TestController_reconcileLabels(t *testing.T) {
 	type args struct {
 		deployment *appsv1.Deployment
+		targets    []client.Object
 	}
 	tests := []struct {
-		name      string
-		args      args
-		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
-		want      ctrl.Result
-		wantErr   bool
+		name        string
+		args        args
+		wantChanged []string
+		wantErr     bool
 	}{
 		{
 			name: "Successful reconciliation: add new labels",
@@ -42,33 +41,13 @@ func TestController_reconcileLabels(t *testing.T) {
 							"label1": "value1",
 						},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
+				},
+				targets: []client.Object{
+					&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}},
 				},
 			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:      "pod1",
-									Namespace: "default",
-								},
-							},
-						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(nil)
-			},
-			want:    ctrl.Result{},
-			wantErr: false,
+			wantChanged: []string{"pod1"},
+			wantErr:     false,
 		},
 		{
 			name: "Successful reconciliation: remove labels",
@@ -78,42 +57,27 @@ func TestController_reconcileLabels(t *testing.T) {
 						Name:      "test-deployment",
 						Namespace: "default",
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
 				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:      "pod1",
-									Namespace: "default",
-									Annotations: map[string]string{
-										fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "label1",
-									},
-									Labels: map[string]string{
-										"label1": "value1",
-									},
-								},
+				targets: []client.Object{
+					&v1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod1",
+							Namespace: "default",
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "label1",
+							},
+							Labels: map[string]string{
+								"label1": "value1",
 							},
 						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(nil)
+					},
+				},
 			},
-			want:    ctrl.Result{},
-			wantErr: false,
+			wantChanged: []string{"pod1"},
+			wantErr:     false,
 		},
 		{
-			name: "Failed reconciliation",
+			name: "Labels are not reflected to endpoint slices",
 			args: args{
 				deployment: &appsv1.Deployment{
 					ObjectMeta: metav1.ObjectMeta{
@@ -126,58 +90,37 @@ func TestController_reconcileLabels(t *testing.T) {
 							"label1": "value1",
 						},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
+				},
+				targets: []client.Object{
+					&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "service1", Namespace: "default"}},
+					&discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Name: "service1-abcde", Namespace: "default"}},
 				},
 			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:        "pod1",
-									Namespace:   "default",
-									Labels:      map[string]string{},
-									Annotations: map[string]string{},
-								},
-							},
-						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(errors.New("failed"))
-			},
-			want:    ctrl.Result{},
-			wantErr: true,
+			wantChanged: []string{"service1"},
+			wantErr:     false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := new(mockKubernetesClient.MockKubernetesClient)
-
 			logger := zap.New()
 			config := &common.Config{}
 
 			controller := &Controller{
-				kubeClient: mockClient,
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
 				logger:     logger,
 				config:     config,
 			}
-			tt.mockSetup(mockClient)
 
-			got, err := controller.reconcileLabels(context.Background(), tt.args.deployment)
+			plan := newReflectionPlan(tt.args.targets, nil)
+
+			err := controller.reconcileLabels(context.Background(), tt.args.deployment, plan)
 			if tt.wantErr {
 				assert.Error(t, err)
-				return
+			} else {
+				assert.Nil(t, err)
 			}
 
-			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantChanged, targetNames(plan.changedTargets))
 		})
 	}
 }
@@ -185,13 +128,13 @@ func TestController_reconcileLabels(t *testing.T) {
 func TestController_reflectLabels(t *testing.T) {
 	type args struct {
 		deployment *appsv1.Deployment
+		pods       []v1.Pod
 	}
 	tests := []struct {
-		name      string
-		args      args
-		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
-		want      ctrl.Result
-		wantErr   bool
+		name        string
+		args        args
+		wantChanged []string
+		wantErr     bool
 	}{
 		{
 			name: "Successfully reflect labels",
@@ -208,43 +151,28 @@ func TestController_reflectLabels(t *testing.T) {
 							"label2": "value2",
 						},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
+				},
+				pods: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "pod1",
+							Namespace:   "default",
+							Labels:      map[string]string{"label1": "value1"},
+							Annotations: map[string]string{},
 						},
 					},
-				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:        "pod1",
-									Namespace:   "default",
-									Labels:      map[string]string{"label1": "value1"},
-									Annotations: map[string]string{},
-								},
-							},
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:        "pod2",
-									Namespace:   "default",
-									Labels:      map[string]string{"label1": "value1"},
-									Annotations: map[string]string{},
-								},
-							},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "pod2",
+							Namespace:   "default",
+							Labels:      map[string]string{"label1": "value1"},
+							Annotations: map[string]string{},
 						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(nil)
+					},
+				},
 			},
-			want:    ctrl.Result{},
-			wantErr: false,
+			wantChanged: []string{"pod1", "pod2"},
+			wantErr:     false,
 		},
 		{
 			name: "Invalid annotation",
@@ -258,16 +186,13 @@ func TestController_reflectLabels(t *testing.T) {
 						},
 						Labels: map[string]string{},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
+				},
+				pods: []v1.Pod{
+					{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}},
 				},
 			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
-			want:      ctrl.Result{},
-			wantErr:   true,
+			wantChanged: nil,
+			wantErr:     true,
 		},
 		{
 			name: "No labels to reflect",
@@ -281,83 +206,22 @@ func TestController_reflectLabels(t *testing.T) {
 						},
 						Labels: map[string]string{},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
 				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:        "pod1",
-									Namespace:   "default",
-									Annotations: map[string]string{},
-								},
-							},
-						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(nil)
-			},
-			want:    ctrl.Result{},
-			wantErr: false,
-		},
-		{
-			name: "Cannot unset metadata",
-			args: args{
-				deployment: &appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-deployment",
-						Namespace: "default",
-						Annotations: map[string]string{
-							fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "",
-						},
-						Labels: map[string]string{},
-					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
+				pods: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "pod1",
+							Namespace:   "default",
+							Annotations: map[string]string{},
 						},
 					},
 				},
 			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:      "pod1",
-									Namespace: "default",
-									Annotations: map[string]string{
-										fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "label1",
-									},
-									Labels: map[string]string{
-										"label1": "value1",
-									},
-								},
-							},
-						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(errors.New("update failed"))
-			},
-			want:    ctrl.Result{},
-			wantErr: true,
+			wantChanged: nil,
+			wantErr:     false,
 		},
 		{
-			name: "Error listing pods",
+			name: "Unsupported conflict policy",
 			args: args{
 				deployment: &appsv1.Deployment{
 					ObjectMeta: metav1.ObjectMeta{
@@ -365,96 +229,42 @@ func TestController_reflectLabels(t *testing.T) {
 						Namespace: "default",
 						Annotations: map[string]string{
 							fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "key1",
+							ReflectorConflictPolicyAnnotation:                       "ignore",
 						},
 						Labels: map[string]string{
 							"key1": "value1",
 						},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
 				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{}, errors.New("failed to list pods"))
-			},
-			want:    ctrl.Result{},
-			wantErr: true,
-		},
-		{
-			name: "Could not update all managed pods",
-			args: args{
-				deployment: &appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-deployment",
-						Namespace: "default",
-						Annotations: map[string]string{
-							fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "key1",
-						},
-						Labels: map[string]string{
-							"key1": "value1",
-						},
-					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
+				pods: []v1.Pod{
+					{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}},
 				},
 			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:      "pod1",
-									Namespace: "default",
-									Annotations: map[string]string{
-										fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "label1",
-									},
-									Labels: map[string]string{
-										"label1": "value1",
-									},
-								},
-							},
-						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(errors.New("update failed"))
-			},
-			want:    ctrl.Result{},
-			wantErr: true,
+			wantChanged: nil,
+			wantErr:     true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := new(mockKubernetesClient.MockKubernetesClient)
-
 			logger := zap.New()
 			config := &common.Config{}
 
 			controller := &Controller{
-				kubeClient: mockClient,
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
 				logger:     logger,
 				config:     config,
 			}
-			tt.mockSetup(mockClient)
 
-			got, err := controller.reflectLabels(context.Background(), tt.args.deployment)
+			plan := newReflectionPlan(podsAsTargets(&v1.PodList{Items: tt.args.pods}), nil)
+
+			err := controller.reflectLabels(context.Background(), tt.args.deployment, plan)
 			if tt.wantErr {
 				assert.Error(t, err)
-				return
+			} else {
+				assert.Nil(t, err)
 			}
 
-			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantChanged, targetNames(plan.changedTargets))
 		})
 	}
 }
@@ -462,13 +272,13 @@ func TestController_reflectLabels(t *testing.T) {
 func TestController_unsetReflectedLabels(t *testing.T) {
 	type args struct {
 		deployment *appsv1.Deployment
+		pods       []v1.Pod
 	}
 	tests := []struct {
-		name      string
-		args      args
-		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
-		want      ctrl.Result
-		wantErr   bool
+		name        string
+		args        args
+		wantChanged []string
+		wantErr     bool
 	}{
 		{
 			name: "Successfully unset labels",
@@ -480,191 +290,80 @@ func TestController_unsetReflectedLabels(t *testing.T) {
 						Annotations: map[string]string{},
 						Labels:      map[string]string{},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
 				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:        "pod1",
-									Namespace:   "default",
-									Labels:      map[string]string{"label1": "value1"},
-									Annotations: map[string]string{},
-								},
-							},
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:      "pod2",
-									Namespace: "default",
-									Labels:    map[string]string{"label1": "value1"},
-									Annotations: map[string]string{
-										fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "label1",
-									},
-								},
-							},
-						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(nil)
-			},
-			want:    ctrl.Result{},
-			wantErr: false,
-		},
-		{
-			name: "Cannot unset metadata",
-			args: args{
-				deployment: &appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-deployment",
-						Namespace: "default",
-						Annotations: map[string]string{
-							fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "",
+				pods: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "pod1",
+							Namespace:   "default",
+							Labels:      map[string]string{"label1": "value1"},
+							Annotations: map[string]string{},
 						},
-						Labels: map[string]string{},
 					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
-					},
-				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:      "pod1",
-									Namespace: "default",
-									Annotations: map[string]string{
-										fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "label1",
-									},
-									Labels: map[string]string{
-										"label1": "value1",
-									},
-								},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod2",
+							Namespace: "default",
+							Labels:    map[string]string{"label1": "value1"},
+							Annotations: map[string]string{
+								fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "label1",
 							},
 						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(errors.New("update failed"))
-			},
-			want:    ctrl.Result{},
-			wantErr: true,
-		},
-		{
-			name: "Error listing pods",
-			args: args{
-				deployment: &appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-deployment",
-						Namespace: "default",
-						Annotations: map[string]string{
-							fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "key1",
-						},
-						Labels: map[string]string{
-							"key1": "value1",
-						},
-					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
 					},
 				},
 			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{}, errors.New("failed to list pods"))
-			},
-			want:    ctrl.Result{},
-			wantErr: true,
+			wantChanged: []string{"pod2"},
+			wantErr:     false,
 		},
 		{
-			name: "Could not update all managed pods",
+			name: "Cannot unset metadata",
 			args: args{
 				deployment: &appsv1.Deployment{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "test-deployment",
 						Namespace: "default",
-						Annotations: map[string]string{
-							fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "key1",
-						},
-						Labels: map[string]string{
-							"key1": "value1",
-						},
-					},
-					Spec: appsv1.DeploymentSpec{
-						Selector: &metav1.LabelSelector{
-							MatchLabels: map[string]string{"app": "test"},
-						},
 					},
 				},
-			},
-			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
-				// Mock managed pods
-				mockClient.On("ListPods", mock.Anything, mock.Anything).
-					Return(&v1.PodList{
-						Items: []v1.Pod{
-							{
-								ObjectMeta: metav1.ObjectMeta{
-									Name:      "pod1",
-									Namespace: "default",
-									Annotations: map[string]string{
-										fmt.Sprintf("%s/reflected-list", ReflectorLabelsAnnotationDomain): "label1",
-									},
-									Labels: map[string]string{
-										"label1": "value1",
-									},
-								},
+				pods: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod1",
+							Namespace: "default",
+							Annotations: map[string]string{
+								ReflectorLabelsOwnershipAnnotation: "not a record",
+							},
+							Labels: map[string]string{
+								"label1": "value1",
 							},
 						},
-					}, nil)
-
-				// Mock pod updates
-				mockClient.On("UpdatePod", mock.Anything, mock.Anything).
-					Return(errors.New("update failed"))
+					},
+				},
 			},
-			want:    ctrl.Result{},
-			wantErr: true,
+			wantChanged: nil,
+			wantErr:     true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := new(mockKubernetesClient.MockKubernetesClient)
-
 			logger := zap.New()
 			config := &common.Config{}
 
 			controller := &Controller{
-				kubeClient: mockClient,
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
 				logger:     logger,
 				config:     config,
 			}
-			tt.mockSetup(mockClient)
 
-			got, err := controller.reflectLabels(context.Background(), tt.args.deployment)
+			plan := newReflectionPlan(podsAsTargets(&v1.PodList{Items: tt.args.pods}), nil)
+
+			err := controller.unsetReflectedLabels(context.Background(), tt.args.deployment, plan)
 			if tt.wantErr {
 				assert.Error(t, err)
-				return
+			} else {
+				assert.Nil(t, err)
 			}
 
-			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantChanged, targetNames(plan.changedTargets))
 		})
 	}
 }
@@ -799,7 +498,7 @@ func TestController_unsetLabelsFromPodWithoutAnyExistingOnes(t *testing.T) {
 	assert.False(t, anyLabelDeleted, "No labels should be updated")
 }
 
-func TestController_unsetExcessiveLabels(t *testing.T) {
+func TestController_restoreLabels(t *testing.T) {
 	mockClient := new(mockKubernetesClient.MockKubernetesClient)
 
 	logger := zap.New()
@@ -811,6 +510,8 @@ func TestController_unsetExcessiveLabels(t *testing.T) {
 		config:     config,
 	}
 
+	priorValue := "injected"
+
 	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-pod",
@@ -819,44 +520,37 @@ func TestController_unsetExcessiveLabels(t *testing.T) {
 				"label1": "value1",
 				"label2": "value2",
 				"label3": "value3",
-			},
-			Annotations: map[string]string{
-				ReflectorLabelsReflectedAnnotation: "label1,label2,label3",
+				"label4": "value4",
 			},
 		},
 	}
 
-	labelsToReflect := map[string]string{
-		"label1": "value1", // Expected label
-		"label2": "value2", // Expected label
-	}
-
-	anyLabelDeleted := controller.unsetExcessiveLabels(labelsToReflect, pod)
-
-	assert.True(t, anyLabelDeleted, "Some labels should be deleted.")
-	assert.NotContains(t, pod.Labels, "label3", "Label 'label3' should be unset.")
-	assert.Contains(t, pod.Labels, "label2", "Label 'label2' should remain.")
-}
-
-func TestController_getReflectorAnnForLabels(t *testing.T) {
-	mockClient := new(mockKubernetesClient.MockKubernetesClient)
-
-	logger := zap.New()
-	config := &common.Config{}
-
-	controller := &Controller{
-		kubeClient: mockClient,
-		logger:     logger,
-		config:     config,
-	}
+	otherClaim := KeyClaim{Source: "other-uid", Kind: SourceKindConfigMap, Value: "other"}
 
-	labelsToReflect := "key1=value1,key2=value2"
-	expectedAnnotations := map[string]string{
-		ReflectorLabelsReflectedAnnotation: labelsToReflect,
+	record := ownershipRecord{
+		"label1": {Source: "deployment-uid", Claims: []KeyClaim{{Source: "deployment-uid", Value: "value1"}}},
+		"label2": {
+			Source:     "deployment-uid",
+			PriorValue: &priorValue,
+			Claims:     []KeyClaim{{Source: "deployment-uid", Value: "value2"}},
+		},
+		"label3": {Source: "other-uid", Claims: []KeyClaim{{Source: "other-uid", Value: "value3"}}},
+		"label4": {
+			Source: "deployment-uid",
+			Claims: []KeyClaim{{Source: "deployment-uid", Kind: SourceKindDeployment, Value: "value4"}, otherClaim},
+		},
 	}
 
-	result := controller.getReflectorAnnForLabels(labelsToReflect)
-
-	assert.NotNil(t, result, "resulting annotations should not be nil")
-	assert.Equal(t, expectedAnnotations, result, "annotations should match the expected map")
+	anyLabelUpdated := controller.restoreLabels(
+		record, "deployment-uid", []string{"label1", "label2", "label3", "label4"}, nil, pod)
+
+	assert.True(t, anyLabelUpdated, "Some labels should be updated.")
+	assert.NotContains(t, pod.Labels, "label1", "Label 'label1' should be unset.")
+	assert.Equal(t, "injected", pod.Labels["label2"], "Label 'label2' should be restored.")
+	assert.Equal(t, "value3", pod.Labels["label3"], "Label 'label3' of another source should remain.")
+	assert.Equal(t, "other", pod.Labels["label4"], "Label 'label4' should take the value of another source.")
+	assert.Equal(t, ownershipRecord{
+		"label3": {Source: "other-uid", Claims: []KeyClaim{{Source: "other-uid", Value: "value3"}}},
+		"label4": {Source: "other-uid", Claims: []KeyClaim{otherClaim}},
+	}, record)
 }
@@ -0,0 +1,83 @@
+package reflector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// conflictsTotal a number of keys that were already present on a target with a different value.
+var conflictsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "metadata_reflector_conflicts_total",
+		Help: "Number of keys that were already present on a target with a different value",
+	},
+	[]string{"source_kind", "target_kind", "metadata", "policy"},
+)
+
+// blockedKeysTotal a number of keys that sources tried to reflect but are not allowed by the configuration.
+var blockedKeysTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "metadata_reflector_blocked_keys_total",
+		Help: "Number of keys that sources tried to reflect but are not allowed by the configuration",
+	},
+	[]string{"source_kind", "metadata"},
+)
+
+// invalidLabelsTotal a number of labels that sources tried to reflect with an invalid key or value.
+var invalidLabelsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "metadata_reflector_invalid_labels_total",
+		Help: "Number of labels that sources tried to reflect with an invalid key or value",
+	},
+	[]string{"source_kind", "action"},
+)
+
+// oversizedAnnotationsTotal a number of annotations that were skipped or truncated to fit the size limit of a target.
+var oversizedAnnotationsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "metadata_reflector_oversized_annotations_total",
+		Help: "Number of annotations that were skipped or truncated to fit the size limit of a target",
+	},
+	[]string{"source_kind", "target_kind", "action"},
+)
+
+// writeBudgetSpentTotal a number of reconciliations that spent their write budget and were requeued to continue.
+var writeBudgetSpentTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "metadata_reflector_write_budget_spent_total",
+		Help: "Number of reconciliations that spent their write budget and were requeued to continue",
+	},
+	[]string{"source_kind"},
+)
+
+// reconcileErrorsTotal a number of failed reconciliations by the class of their errors.
+var reconcileErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "metadata_reflector_reconcile_errors_total",
+		Help: "Number of failed reconciliations by the class of their errors, transient or permanent",
+	},
+	[]string{"source_kind", "class"},
+)
+
+// reconciliationsWithoutTargetsTotal a number of reconciliations of sources that had no targets.
+var reconciliationsWithoutTargetsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "metadata_reflector_reconciliations_without_targets_total",
+		Help: "Number of reconciliations of sources that had no targets, e.g. deployments scaled to zero",
+	},
+	[]string{"source_kind"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(conflictsTotal, blockedKeysTotal, invalidLabelsTotal, oversizedAnnotationsTotal,
+		writeBudgetSpentTotal, reconcileErrorsTotal, reconciliationsWithoutTargetsTotal)
+}
+
+// add observations to a counter, nothing is counted when a plan is only explained.
+func (r *Controller) addToCounter(counter prometheus.Counter, count int) {
+	if r.dryRun {
+		return
+	}
+
+	counter.Add(float64(count))
+}
@@ -1,6 +1,11 @@
 package reflector
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	v1 "k8s.io/api/core/v1"
+)
 
 var ReflectorAnnotationDomain = "metadata-reflector.spaceship.com"
 
@@ -10,15 +15,67 @@ var (
 	ReflectorOperationRegex = "regex"
 )
 
+// kinds of objects that metadata can be reflected from, configured with SOURCE_KINDS.
+const (
+	SourceKindDeployment = string(common.SourceKindDeployment)
+	SourceKindConfigMap  = string(common.SourceKindConfigMap)
+	SourceKindSecret     = string(common.SourceKindSecret)
+	SourceKindCronJob    = string(common.SourceKindCronJob)
+	SourceKindJob        = string(common.SourceKindJob)
+)
+
+// policies deciding what happens when a target already has a key with a different value that wasn't reflected.
+const (
+	ConflictPolicyOverwrite     = string(common.ConflictPolicyOverwrite)
+	ConflictPolicySkipIfPresent = string(common.ConflictPolicySkipIfPresent)
+	ConflictPolicyFail          = string(common.ConflictPolicyFail)
+)
+
+// policies deciding what happens with labels whose keys or values are not valid.
+const (
+	InvalidLabelPolicyReject   = string(common.InvalidLabelPolicyReject)
+	InvalidLabelPolicySanitize = string(common.InvalidLabelPolicySanitize)
+)
+
+// policies deciding what happens with annotations that don't fit the annotation size limit of a target.
+const (
+	AnnotationSizePolicySkip     = string(common.AnnotationSizePolicySkip)
+	AnnotationSizePolicyTruncate = string(common.AnnotationSizePolicyTruncate)
+)
+
 var (
 	ReflectorLabelsAnnotationDomain      = fmt.Sprintf("labels.%s", ReflectorAnnotationDomain)
 	ReflectorAnnotationsAnnotationDomain = fmt.Sprintf("annotations.%s", ReflectorAnnotationDomain)
 
-	// ReflectorLabelsReflectedAnnotation a list of annotations that were added to the object by the controller.
+	// ReflectorLabelsOwnershipAnnotation a JSON record of labels that were added to the object by the controller.
+	ReflectorLabelsOwnershipAnnotation = fmt.Sprintf("%s/%s", ReflectorLabelsAnnotationDomain, "ownership")
+
+	ReflectorAnnotationsOwnershipAnnotation = fmt.Sprintf(
+		"%s/%s", ReflectorAnnotationsAnnotationDomain, "ownership")
+
+	// ReflectorLabelsReflectedAnnotation a legacy list of labels that were added to the object by the controller.
+	// it's migrated to the ownership record on the next reconciliation.
 	ReflectorLabelsReflectedAnnotation = fmt.Sprintf("%s/%s", ReflectorLabelsAnnotationDomain, "reflected-list")
 
 	ReflectorAnnotationsReflectedAnnotation = fmt.Sprintf(
 		"%s/%s", ReflectorAnnotationsAnnotationDomain, "reflected-list")
+
+	// ReflectorConflictPolicyAnnotation a conflict policy of the source overriding the configured one.
+	ReflectorConflictPolicyAnnotation = fmt.Sprintf("%s/%s", ReflectorAnnotationDomain, "conflict-policy")
+
+	// ReflectorPriorityAnnotation a priority of the source, the value of the source with the highest priority
+	// is used when several sources reflect the same key to a target.
+	ReflectorPriorityAnnotation = fmt.Sprintf("%s/%s", ReflectorAnnotationDomain, "priority")
+
+	// ReflectorConflictConditionType a pod condition reporting keys that sources reflect with different values.
+	ReflectorConflictConditionType = v1.PodConditionType(
+		fmt.Sprintf("%s/%s", ReflectorAnnotationDomain, "MetadataConflict"))
+
+	// ReflectorStatusAnnotation a JSON status of the reflection of a source, written to the source.
+	ReflectorStatusAnnotation = fmt.Sprintf("%s/%s", ReflectorAnnotationDomain, "status")
+
+	// ReflectorTargetSelectorAnnotation a label selector narrowing the pods of a deployment metadata is reflected to.
+	ReflectorTargetSelectorAnnotation = fmt.Sprintf("%s/%s", ReflectorAnnotationDomain, "target-selector")
 )
 
 func supportedAnnotationDomains() []string {
@@ -28,3 +85,21 @@ func supportedAnnotationDomains() []string {
 func supportedOperations() []string {
 	return []string{ReflectorOperationList, ReflectorOperationRegex}
 }
+
+func supportedSourceKinds() []string {
+	return []string{
+		SourceKindDeployment, SourceKindConfigMap, SourceKindSecret, SourceKindCronJob, SourceKindJob,
+	}
+}
+
+// kinds of sources ordered by precedence when sources with the same priority reflect the same key,
+// sources closer to the target come first.
+func sourceKindPrecedence() []string {
+	return []string{
+		SourceKindDeployment, SourceKindJob, SourceKindCronJob, SourceKindConfigMap, SourceKindSecret,
+	}
+}
+
+func supportedConflictPolicies() []string {
+	return []string{ConflictPolicyOverwrite, ConflictPolicySkipIfPresent, ConflictPolicyFail}
+}
@@ -0,0 +1,395 @@
+package reflector
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KeyClaim a value of a key claimed by one of the sources reflecting to the target.
+type KeyClaim struct {
+	Source   types.UID `json:"source"`
+	Kind     string    `json:"kind,omitempty"`
+	Priority int       `json:"priority,omitempty"`
+	Value    string    `json:"value"`
+}
+
+/*
+keyOwnership the source whose value of a key is set on the target, claims of all sources reflecting the key
+and the value the key had before it was reflected, if any.
+*/
+type keyOwnership struct {
+	Source     types.UID  `json:"source"`
+	PriorValue *string    `json:"priorValue,omitempty"`
+	Claims     []KeyClaim `json:"claims,omitempty"`
+}
+
+// ownershipRecord keys reflected to a target, stored as JSON in an annotation of the target.
+type ownershipRecord map[string]keyOwnership
+
+/*
+compare claims by precedence, the claim that sorts first wins.
+a higher priority wins, then the kind of the source following sourceKindPrecedence,
+the UID of the source is the last resort to keep the order deterministic.
+*/
+func compareClaims(a KeyClaim, b KeyClaim) int {
+	if a.Priority != b.Priority {
+		return cmp.Compare(b.Priority, a.Priority)
+	}
+
+	if kindOrder := cmp.Compare(kindPrecedence(a.Kind), kindPrecedence(b.Kind)); kindOrder != 0 {
+		return kindOrder
+	}
+
+	return cmp.Compare(a.Source, b.Source)
+}
+
+// get the position of the kind in sourceKindPrecedence, unknown kinds go last.
+func kindPrecedence(kind string) int {
+	position := slices.Index(sourceKindPrecedence(), kind)
+	if position == -1 {
+		return len(sourceKindPrecedence())
+	}
+
+	return position
+}
+
+// get keys claimed by the source, sorted to keep the order stable.
+func (o ownershipRecord) keysClaimedBy(sourceUID types.UID) []string {
+	var claimedKeys []string
+
+	for key, ownership := range o {
+		if ownership.claimIndex(sourceUID) != -1 {
+			claimedKeys = append(claimedKeys, key)
+		}
+	}
+
+	slices.Sort(claimedKeys)
+
+	return claimedKeys
+}
+
+// check whether the key is claimed by any source, i.e. it's managed by the reflector.
+func (o ownershipRecord) isClaimed(key string) bool {
+	ownership, ok := o[key]
+
+	return ok && len(ownership.Claims) > 0
+}
+
+// get keys whose claims don't agree on the value, sorted to keep the order stable.
+func (o ownershipRecord) conflictingKeys() []string {
+	var conflictingKeys []string
+
+	for key, ownership := range o {
+		// a single claim can't conflict, records of older versions may have no claims at all
+		if len(ownership.Claims) < 2 {
+			continue
+		}
+
+		for _, otherClaim := range ownership.Claims[1:] {
+			if otherClaim.Value != ownership.Claims[0].Value {
+				conflictingKeys = append(conflictingKeys, key)
+
+				break
+			}
+		}
+	}
+
+	slices.Sort(conflictingKeys)
+
+	return conflictingKeys
+}
+
+/*
+claim keys that are about to be set on the target by the source, the claim carries the source, its kind and priority.
+the prior value is only recorded when a key is claimed for the first time.
+returns values of the claims with the highest precedence, which are the values to set on the target.
+*/
+func (o ownershipRecord) claim(
+	sourceClaim KeyClaim, keysToSet map[string]string, targetKeys map[string]string,
+) map[string]string {
+	valuesToSet := make(map[string]string)
+
+	for key, value := range keysToSet {
+		ownership, ok := o[key]
+		if !ok {
+			if priorValue, present := targetKeys[key]; present {
+				ownership.PriorValue = &priorValue
+			}
+		}
+
+		valueClaim := sourceClaim
+		valueClaim.Value = value
+
+		if claimIndex := ownership.claimIndex(sourceClaim.Source); claimIndex != -1 {
+			ownership.Claims[claimIndex] = valueClaim
+		} else {
+			ownership.Claims = append(ownership.Claims, valueClaim)
+		}
+
+		slices.SortStableFunc(ownership.Claims, compareClaims)
+		ownership.Source = ownership.Claims[0].Source
+		o[key] = ownership
+
+		valuesToSet[key] = ownership.Claims[0].Value
+	}
+
+	return valuesToSet
+}
+
+/*
+release keys claimed by the source. a key still claimed by other sources takes the value of the next claim,
+otherwise it's removed from the record and its prior value is restored.
+returns values that should be set and keys that should be unset.
+*/
+func (o ownershipRecord) release(sourceUID types.UID, keys []string) (map[string]string, []string) {
+	valuesToSet := make(map[string]string)
+
+	var keysToUnset []string
+
+	for _, key := range keys {
+		ownership := o[key]
+
+		claimIndex := ownership.claimIndex(sourceUID)
+		if claimIndex == -1 {
+			continue
+		}
+
+		ownership.Claims = slices.Delete(ownership.Claims, claimIndex, claimIndex+1)
+
+		switch {
+		case len(ownership.Claims) > 0:
+			ownership.Source = ownership.Claims[0].Source
+			o[key] = ownership
+
+			valuesToSet[key] = ownership.Claims[0].Value
+
+			continue
+		case ownership.PriorValue != nil:
+			valuesToSet[key] = *ownership.PriorValue
+		default:
+			keysToUnset = append(keysToUnset, key)
+		}
+
+		delete(o, key)
+	}
+
+	return valuesToSet, keysToUnset
+}
+
+// get the index of the claim of the source, -1 if the source doesn't claim the key.
+func (o keyOwnership) claimIndex(sourceUID types.UID) int {
+	return slices.IndexFunc(o.Claims, func(sourceClaim KeyClaim) bool {
+		return sourceClaim.Source == sourceUID
+	})
+}
+
+/*
+get the ownership record stored in the annotation of the target.
+a record written before multiple sources were supported has no claims, the owner is migrated
+to a single claim with the current value of the key.
+a target reflected to by an older version of the controller only has a comma-separated list of keys,
+such keys are migrated to the source being reconciled without a prior value as it was never recorded.
+*/
+func (r *Controller) getOwnershipRecord(
+	source client.Object, target client.Object, targetKeys map[string]string,
+	recordAnnotation string, legacyAnnotation string,
+) (ownershipRecord, error) {
+	record := make(ownershipRecord)
+	targetAnnotations := target.GetAnnotations()
+
+	if recordValue, ok := targetAnnotations[recordAnnotation]; ok {
+		parsedRecord, parseErr := parseOwnershipRecord(recordValue, targetKeys)
+		if parseErr != nil {
+			r.logger.Error(parseErr, "Failed to parse ownership record of target",
+				"kind", targetKind(target), "target", target.GetName(), "annotation", recordAnnotation)
+
+			return nil, ErrUnparsableOwnershipRecord
+		}
+
+		return parsedRecord, nil
+	}
+
+	legacyValue, ok := targetAnnotations[legacyAnnotation]
+	if !ok {
+		return record, nil
+	}
+
+	r.logger.Info("Migrating reflected list of target to an ownership record",
+		"kind", targetKind(target), "target", target.GetName(), "annotation", legacyAnnotation)
+
+	for key := range strings.SplitSeq(legacyValue, ",") {
+		if key != "" {
+			record[key] = keyOwnership{
+				Source: source.GetUID(),
+				Claims: []KeyClaim{{Source: source.GetUID(), Kind: sourceKind(source), Value: targetKeys[key]}},
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// parse the JSON of an ownership record, a record without claims is upgraded to a single claim of the owner.
+func parseOwnershipRecord(recordValue string, targetKeys map[string]string) (ownershipRecord, error) {
+	record := make(ownershipRecord)
+
+	if unmarshalErr := json.Unmarshal([]byte(recordValue), &record); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	for key, ownership := range record {
+		if len(ownership.Claims) == 0 {
+			ownership.Claims = []KeyClaim{{Source: ownership.Source, Value: targetKeys[key]}}
+			record[key] = ownership
+		}
+	}
+
+	return record, nil
+}
+
+// persist the ownership record in the annotation of the target, the annotation is removed when the record is empty.
+// returns whether any target annotation was updated.
+func (r *Controller) setOwnershipRecord(
+	record ownershipRecord, target client.Object, recordAnnotation string, legacyAnnotation string,
+) (bool, error) {
+	// the legacy annotation is always replaced by the record
+	targetUpdated := r.unsetAnnotations([]string{legacyAnnotation}, target)
+
+	if len(record) == 0 {
+		recordUnset := r.unsetAnnotations([]string{recordAnnotation}, target)
+
+		return targetUpdated || recordUnset, nil
+	}
+
+	recordValue, marshalErr := json.Marshal(record)
+	if marshalErr != nil {
+		return targetUpdated, marshalErr
+	}
+
+	recordSet := r.setAnnotations(map[string]string{recordAnnotation: string(recordValue)}, target)
+
+	return targetUpdated || recordSet, nil
+}
+
+// ReflectedKey a key reflected to a target, as recorded on the target.
+type ReflectedKey struct {
+	// labels or annotations
+	Metadata string `json:"metadata"`
+	Key      string `json:"key"`
+	// the value of the key on the target, there is none when the key is missing
+	Value *string `json:"value,omitempty"`
+	// the source whose value is set on the target, unknown for keys of legacy reflected lists
+	Owner      types.UID  `json:"owner,omitempty"`
+	PriorValue *string    `json:"priorValue,omitempty"`
+	Claims     []KeyClaim `json:"claims,omitempty"`
+	// the key is listed in a legacy reflected-list annotation and is migrated once its source is reconciled
+	Legacy bool `json:"legacy,omitempty"`
+}
+
+/*
+ReflectedKeysOf get keys reflected to the target from its ownership records and legacy reflected lists,
+ordered by metadata and key. only the target is read, so keys can be inspected offline.
+*/
+func ReflectedKeysOf(target metav1.Object) ([]ReflectedKey, error) {
+	var reflectedKeys []ReflectedKey
+
+	recordedMetadata := []struct {
+		metadata, recordAnnotation, legacyAnnotation string
+		targetKeys                                   map[string]string
+	}{
+		{"labels", ReflectorLabelsOwnershipAnnotation, ReflectorLabelsReflectedAnnotation, target.GetLabels()},
+		{
+			"annotations", ReflectorAnnotationsOwnershipAnnotation, ReflectorAnnotationsReflectedAnnotation,
+			target.GetAnnotations(),
+		},
+	}
+
+	for _, recorded := range recordedMetadata {
+		valueOf := func(key string) *string {
+			if value, ok := recorded.targetKeys[key]; ok {
+				return &value
+			}
+
+			return nil
+		}
+
+		if recordValue, ok := target.GetAnnotations()[recorded.recordAnnotation]; ok {
+			record, parseErr := parseOwnershipRecord(recordValue, recorded.targetKeys)
+			if parseErr != nil {
+				return nil, fmt.Errorf("%w: %s: %w", ErrUnparsableOwnershipRecord, recorded.recordAnnotation, parseErr)
+			}
+
+			for _, key := range slices.Sorted(maps.Keys(record)) {
+				reflectedKeys = append(reflectedKeys, ReflectedKey{
+					Metadata:   recorded.metadata,
+					Key:        key,
+					Value:      valueOf(key),
+					Owner:      record[key].Source,
+					PriorValue: record[key].PriorValue,
+					Claims:     record[key].Claims,
+				})
+			}
+
+			continue
+		}
+
+		legacyKeys := strings.Split(target.GetAnnotations()[recorded.legacyAnnotation], ",")
+		slices.Sort(legacyKeys)
+
+		for _, key := range slices.Compact(legacyKeys) {
+			if key != "" {
+				reflectedKeys = append(reflectedKeys,
+					ReflectedKey{Metadata: recorded.metadata, Key: key, Value: valueOf(key), Legacy: true})
+			}
+		}
+	}
+
+	return reflectedKeys, nil
+}
+
+// get annotations the reflector keeps its state in, keys reflected to a target and the status of a source.
+func bookkeepingAnnotations() []string {
+	return []string{
+		ReflectorLabelsOwnershipAnnotation, ReflectorAnnotationsOwnershipAnnotation,
+		ReflectorLabelsReflectedAnnotation, ReflectorAnnotationsReflectedAnnotation,
+		ReflectorStatusAnnotation,
+	}
+}
+
+// get annotations of the object without the ones the reflector keeps its state in.
+func withoutBookkeeping(annotations map[string]string) map[string]string {
+	annotations = maps.Clone(annotations)
+
+	for _, annotation := range bookkeepingAnnotations() {
+		delete(annotations, annotation)
+	}
+
+	return annotations
+}
+
+// get the priority of the source from its annotation, sources without the annotation have priority 0.
+func (r *Controller) getSourcePriority(source client.Object) (int, error) {
+	priorityValue, ok := source.GetAnnotations()[ReflectorPriorityAnnotation]
+	if !ok {
+		return 0, nil
+	}
+
+	priority, parseErr := strconv.Atoi(priorityValue)
+	if parseErr != nil {
+		r.logger.Error(parseErr, "Source has an invalid priority",
+			"kind", sourceKind(source), "source", source.GetName(), "priority", priorityValue)
+
+		return 0, ErrInvalidPriority
+	}
+
+	return priority, nil
+}
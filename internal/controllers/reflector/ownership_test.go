@@ -0,0 +1,505 @@
+package reflector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestOwnershipRecord_keysClaimedBy(t *testing.T) {
+	record := ownershipRecord{
+		"b": {Source: "deployment-uid", Claims: []KeyClaim{{Source: "deployment-uid"}}},
+		"a": {Source: "other-uid", Claims: []KeyClaim{{Source: "other-uid"}, {Source: "deployment-uid"}}},
+		"c": {Source: "other-uid", Claims: []KeyClaim{{Source: "other-uid"}}},
+	}
+
+	assert.Equal(t, []string{"a", "b"}, record.keysClaimedBy("deployment-uid"))
+	assert.Nil(t, record.keysClaimedBy("unknown-uid"))
+}
+
+func TestOwnershipRecord_conflictingKeys(t *testing.T) {
+	record := ownershipRecord{
+		"same": {Claims: []KeyClaim{{Source: "deployment-uid", Value: "a"}, {Source: "other-uid", Value: "a"}}},
+		"conflict": {
+			Claims: []KeyClaim{{Source: "deployment-uid", Value: "a"}, {Source: "other-uid", Value: "b"}},
+		},
+		"single":    {Claims: []KeyClaim{{Source: "deployment-uid", Value: "a"}}},
+		"unclaimed": {Source: "deployment-uid"},
+	}
+
+	assert.Equal(t, []string{"conflict"}, record.conflictingKeys())
+}
+
+func TestCompareClaims(t *testing.T) {
+	claims := []KeyClaim{
+		{Source: "unknown-uid", Kind: "Namespace"},
+		{Source: "configmap-uid", Kind: SourceKindConfigMap},
+		{Source: "deployment-b-uid", Kind: SourceKindDeployment},
+		{Source: "secret-uid", Kind: SourceKindSecret, Priority: 10},
+		{Source: "deployment-a-uid", Kind: SourceKindDeployment},
+		{Source: "cronjob-uid", Kind: SourceKindCronJob},
+		{Source: "job-uid", Kind: SourceKindJob, Priority: -1},
+	}
+
+	slices.SortStableFunc(claims, compareClaims)
+
+	// priority first, then the kind of the source and the UID of the source
+	assert.Equal(t, []KeyClaim{
+		{Source: "secret-uid", Kind: SourceKindSecret, Priority: 10},
+		{Source: "deployment-a-uid", Kind: SourceKindDeployment},
+		{Source: "deployment-b-uid", Kind: SourceKindDeployment},
+		{Source: "cronjob-uid", Kind: SourceKindCronJob},
+		{Source: "configmap-uid", Kind: SourceKindConfigMap},
+		{Source: "unknown-uid", Kind: "Namespace"},
+		{Source: "job-uid", Kind: SourceKindJob, Priority: -1},
+	}, claims)
+}
+
+func TestOwnershipRecord_claim(t *testing.T) {
+	configMapClaim := KeyClaim{Source: "configmap-uid", Kind: SourceKindConfigMap, Value: "shared"}
+	priorityClaim := KeyClaim{Source: "secret-uid", Kind: SourceKindSecret, Priority: 1, Value: "important"}
+
+	record := ownershipRecord{
+		"owned": {
+			Source: "deployment-uid",
+			Claims: []KeyClaim{{Source: "deployment-uid", Kind: SourceKindDeployment, Value: "old"}},
+		},
+		"shared":      {Source: "configmap-uid", PriorValue: ptr.To("original"), Claims: []KeyClaim{configMapClaim}},
+		"prioritized": {Source: "secret-uid", Claims: []KeyClaim{priorityClaim}},
+	}
+
+	valuesToSet := record.claim(KeyClaim{Source: "deployment-uid", Kind: SourceKindDeployment},
+		map[string]string{
+			"owned": "new", "shared": "new", "prioritized": "new", "pre-existing": "new", "absent": "new",
+		},
+		map[string]string{"owned": "old", "shared": "shared", "prioritized": "important", "pre-existing": "original"},
+	)
+
+	deploymentClaim := KeyClaim{Source: "deployment-uid", Kind: SourceKindDeployment, Value: "new"}
+
+	// the deployment takes precedence over the config map but not over a source with a higher priority
+	assert.Equal(t, map[string]string{
+		"owned": "new", "shared": "new", "prioritized": "important", "pre-existing": "new", "absent": "new",
+	}, valuesToSet)
+	assert.Equal(t, ownershipRecord{
+		"owned": {Source: "deployment-uid", Claims: []KeyClaim{deploymentClaim}},
+		"shared": {
+			Source:     "deployment-uid",
+			PriorValue: ptr.To("original"),
+			Claims:     []KeyClaim{deploymentClaim, configMapClaim},
+		},
+		"prioritized":  {Source: "secret-uid", Claims: []KeyClaim{priorityClaim, deploymentClaim}},
+		"pre-existing": {Source: "deployment-uid", PriorValue: ptr.To("original"), Claims: []KeyClaim{deploymentClaim}},
+		"absent":       {Source: "deployment-uid", Claims: []KeyClaim{deploymentClaim}},
+	}, record)
+}
+
+func TestOwnershipRecord_release(t *testing.T) {
+	otherClaim := KeyClaim{Source: "other-uid", Kind: SourceKindConfigMap, Value: "other"}
+	deploymentClaim := KeyClaim{Source: "deployment-uid", Kind: SourceKindDeployment, Value: "deployment"}
+
+	record := ownershipRecord{
+		"unset":   {Source: "deployment-uid", Claims: []KeyClaim{deploymentClaim}},
+		"restore": {Source: "deployment-uid", PriorValue: ptr.To("original"), Claims: []KeyClaim{deploymentClaim}},
+		"shared": {
+			Source:     "deployment-uid",
+			PriorValue: ptr.To("original"),
+			Claims:     []KeyClaim{deploymentClaim, otherClaim},
+		},
+		"other": {Source: "other-uid", Claims: []KeyClaim{otherClaim}},
+	}
+
+	valuesToSet, keysToUnset := record.release(
+		"deployment-uid", []string{"unset", "restore", "shared", "other", "unknown"})
+
+	// a key still claimed by another source takes its value and keeps the prior value for later
+	assert.Equal(t, map[string]string{"restore": "original", "shared": "other"}, valuesToSet)
+	assert.Equal(t, []string{"unset"}, keysToUnset)
+	assert.Equal(t, ownershipRecord{
+		"shared": {Source: "other-uid", PriorValue: ptr.To("original"), Claims: []KeyClaim{otherClaim}},
+		"other":  {Source: "other-uid", Claims: []KeyClaim{otherClaim}},
+	}, record)
+}
+
+func TestController_getOwnershipRecord(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", UID: "deployment-uid"}}
+	targetLabels := map[string]string{"team": "payments", "cost-center": "42"}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        ownershipRecord
+		wantErr     bool
+	}{
+		{
+			name:        "No record",
+			annotations: nil,
+			want:        ownershipRecord{},
+			wantErr:     false,
+		},
+		{
+			name: "Record",
+			annotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"other-uid","priorValue":"platform",` +
+					`"claims":[{"source":"other-uid","kind":"ConfigMap","value":"payments"}]}}`,
+			},
+			want: ownershipRecord{
+				"team": {
+					Source:     "other-uid",
+					PriorValue: ptr.To("platform"),
+					Claims:     []KeyClaim{{Source: "other-uid", Kind: SourceKindConfigMap, Value: "payments"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Record without claims is migrated to a claim with the current value",
+			annotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"other-uid","priorValue":"platform"}}`,
+			},
+			want: ownershipRecord{
+				"team": {
+					Source:     "other-uid",
+					PriorValue: ptr.To("platform"),
+					Claims:     []KeyClaim{{Source: "other-uid", Value: "payments"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Legacy reflected list is migrated to the source",
+			annotations: map[string]string{
+				ReflectorLabelsReflectedAnnotation: "team,cost-center",
+			},
+			want: ownershipRecord{
+				"team": {
+					Source: "deployment-uid",
+					Claims: []KeyClaim{{Source: "deployment-uid", Kind: SourceKindDeployment, Value: "payments"}},
+				},
+				"cost-center": {
+					Source: "deployment-uid",
+					Claims: []KeyClaim{{Source: "deployment-uid", Kind: SourceKindDeployment, Value: "42"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Record takes precedence over the legacy reflected list",
+			annotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"deployment-uid"}}`,
+				ReflectorLabelsReflectedAnnotation: "team,cost-center",
+			},
+			want: ownershipRecord{
+				"team": {Source: "deployment-uid", Claims: []KeyClaim{{Source: "deployment-uid", Value: "payments"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Unparsable record",
+			annotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: "team",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Annotations: tt.annotations}}
+
+			got, err := controller.getOwnershipRecord(source, target, targetLabels,
+				ReflectorLabelsOwnershipAnnotation, ReflectorLabelsReflectedAnnotation)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnparsableOwnershipRecord)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReflectedKeysOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        []ReflectedKey
+		wantErr     bool
+	}{
+		{
+			name:        "No keys are reflected",
+			labels:      map[string]string{"team": "payments"},
+			annotations: nil,
+			want:        nil,
+			wantErr:     false,
+		},
+		{
+			name:   "Records and legacy reflected lists",
+			labels: map[string]string{"team": "payments"},
+			annotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"deployment-uid",` +
+					`"claims":[{"source":"deployment-uid","kind":"Deployment","value":"payments"}]}}`,
+				ReflectorAnnotationsReflectedAnnotation: "owner,contact,owner",
+				"owner":                                 "payments@example.com",
+			},
+			want: []ReflectedKey{
+				{
+					Metadata: "labels",
+					Key:      "team",
+					Value:    ptr.To("payments"),
+					Owner:    "deployment-uid",
+					Claims:   []KeyClaim{{Source: "deployment-uid", Kind: SourceKindDeployment, Value: "payments"}},
+				},
+				{Metadata: "annotations", Key: "contact", Legacy: true},
+				{Metadata: "annotations", Key: "owner", Value: ptr.To("payments@example.com"), Legacy: true},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "Unparsable record",
+			labels: nil,
+			annotations: map[string]string{
+				ReflectorAnnotationsOwnershipAnnotation: "owner",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name: "pod1", Labels: tt.labels, Annotations: tt.annotations,
+			}}
+
+			got, err := ReflectedKeysOf(target)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnparsableOwnershipRecord)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestController_setOwnershipRecord(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	tests := []struct {
+		name            string
+		record          ownershipRecord
+		annotations     map[string]string
+		wantUpdated     bool
+		wantAnnotations map[string]string
+	}{
+		{
+			name:        "Record is set and the legacy reflected list is removed",
+			record:      ownershipRecord{"team": {Source: "deployment-uid", PriorValue: ptr.To("platform")}},
+			annotations: map[string]string{ReflectorLabelsReflectedAnnotation: "team"},
+			wantUpdated: true,
+			wantAnnotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"deployment-uid","priorValue":"platform"}}`,
+			},
+		},
+		{
+			name:   "Record is not changed",
+			record: ownershipRecord{"team": {Source: "deployment-uid"}},
+			annotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"deployment-uid"}}`,
+			},
+			wantUpdated: false,
+			wantAnnotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"deployment-uid"}}`,
+			},
+		},
+		{
+			name:   "Empty record is removed",
+			record: ownershipRecord{},
+			annotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation: `{"team":{"source":"deployment-uid"}}`,
+				"unrelated":                        "value",
+			},
+			wantUpdated:     true,
+			wantAnnotations: map[string]string{"unrelated": "value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Annotations: tt.annotations}}
+
+			gotUpdated, err := controller.setOwnershipRecord(
+				tt.record, target, ReflectorLabelsOwnershipAnnotation, ReflectorLabelsReflectedAnnotation)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantUpdated, gotUpdated)
+			assert.Equal(t, tt.wantAnnotations, target.Annotations)
+		})
+	}
+}
+
+func TestController_reflectAndUnsetRestoresPriorValues(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config:     &common.Config{ConflictPolicy: ConflictPolicyOverwrite},
+		recorder:   events.NewFakeRecorder(10),
+	}
+
+	source := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-deployment",
+			UID:  "deployment-uid",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team,tier",
+			},
+			Labels: map[string]string{"team": "payments", "tier": "backend"},
+		},
+	}
+	target := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pod1",
+			Labels: map[string]string{"team": "platform", "app": "test"},
+		},
+	}
+
+	_, reflectErr := controller.reflectLabelsToTarget(context.Background(),
+		source, target, map[string]string{"team": "payments", "tier": "backend"}, ConflictPolicyOverwrite, 0)
+
+	assert.Nil(t, reflectErr)
+	assert.Equal(t, map[string]string{"team": "payments", "tier": "backend", "app": "test"}, target.Labels)
+
+	unsetUpdated, unsetErr := controller.unsetReflectedLabelsFromTarget(context.Background(), source, target)
+
+	// the pre-existing value is restored and the label that wasn't present is deleted
+	assert.Nil(t, unsetErr)
+	assert.True(t, unsetUpdated)
+	assert.Equal(t, map[string]string{"team": "platform", "app": "test"}, target.Labels)
+	assert.Empty(t, target.Annotations)
+}
+
+func TestController_reflectFromMultipleSources(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config:     &common.Config{ConflictPolicy: ConflictPolicyOverwrite},
+		recorder:   events.NewFakeRecorder(10),
+	}
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", UID: "deployment-uid"}}
+	configMap := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: SourceKindConfigMap},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-configmap", UID: "configmap-uid"},
+	}
+	target := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pod1",
+			Labels: map[string]string{"team": "platform"},
+		},
+	}
+
+	_, deploymentErr := controller.reflectLabelsToTarget(context.Background(),
+		deployment, target, map[string]string{"team": "payments", "tier": "backend"}, ConflictPolicyOverwrite, 0)
+	_, configMapErr := controller.reflectLabelsToTarget(context.Background(),
+		configMap, target, map[string]string{"team": "billing", "tier": "backend"}, ConflictPolicyOverwrite, 1)
+
+	// the config map has a higher priority, so its value wins
+	assert.Nil(t, deploymentErr)
+	assert.Nil(t, configMapErr)
+	assert.Equal(t, map[string]string{"team": "billing", "tier": "backend"}, target.Labels)
+
+	_, configMapUnsetErr := controller.unsetReflectedLabelsFromTarget(context.Background(), configMap, target)
+
+	// labels still claimed by the deployment are kept with its value
+	assert.Nil(t, configMapUnsetErr)
+	assert.Equal(t, map[string]string{"team": "payments", "tier": "backend"}, target.Labels)
+
+	_, deploymentUnsetErr := controller.unsetReflectedLabelsFromTarget(context.Background(), deployment, target)
+
+	assert.Nil(t, deploymentUnsetErr)
+	assert.Equal(t, map[string]string{"team": "platform"}, target.Labels)
+	assert.Empty(t, target.Annotations)
+}
+
+func TestController_getSourcePriority(t *testing.T) {
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        int
+		wantErr     bool
+	}{
+		{
+			name:        "No priority",
+			annotations: nil,
+			want:        0,
+			wantErr:     false,
+		},
+		{
+			name:        "Priority",
+			annotations: map[string]string{ReflectorPriorityAnnotation: "10"},
+			want:        10,
+			wantErr:     false,
+		},
+		{
+			name:        "Invalid priority",
+			annotations: map[string]string{ReflectorPriorityAnnotation: "high"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Annotations: tt.annotations}}
+
+			got, err := controller.getSourcePriority(source)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidPriority)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
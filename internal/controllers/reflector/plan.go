@@ -0,0 +1,134 @@
+package reflector
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-multierror"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+reflectionPlan the desired metadata of targets of a source across all reflection kinds.
+targets are listed once and every reflection kind changes the same objects in memory,
+so a changed target is written with a single patch once the plan is applied.
+*/
+type reflectionPlan struct {
+	// targets of the source, including endpoint slices when they are enabled
+	targets []client.Object
+	// pods the target selector of the source excludes, reflected metadata is only removed from them
+	excludedTargets []client.Object
+	// targets to write, in the order they were first changed
+	changedTargets []client.Object
+	changed        map[client.Object]struct{}
+	// changed targets that were written once the plan was applied
+	written map[client.Object]struct{}
+}
+
+func newReflectionPlan(targets []client.Object, excludedTargets []client.Object) *reflectionPlan {
+	return &reflectionPlan{
+		targets:         targets,
+		excludedTargets: excludedTargets,
+		changed:         make(map[client.Object]struct{}),
+		written:         make(map[client.Object]struct{}),
+	}
+}
+
+// mark the target to be written when the plan is applied, a target is written once however many times it changed.
+func (p *reflectionPlan) markChanged(target client.Object) {
+	if _, ok := p.changed[target]; ok {
+		return
+	}
+
+	p.changed[target] = struct{}{}
+	p.changedTargets = append(p.changedTargets, target)
+}
+
+// count targets that have their desired metadata, unchanged targets and changed targets that were written.
+func (p *reflectionPlan) targetsInSync() int {
+	inSync := 0
+
+	for _, target := range p.targets {
+		_, changed := p.changed[target]
+		_, written := p.written[target]
+
+		if !changed || written {
+			inSync++
+		}
+	}
+
+	return inSync
+}
+
+// get targets labels are reflected to.
+// EndpointSlice labels are kept in sync with the Service by the EndpointSlice controller.
+func (p *reflectionPlan) labelTargets() []client.Object {
+	labelTargets := make([]client.Object, 0, len(p.targets))
+
+	for _, target := range p.targets {
+		if _, isEndpointSlice := target.(*discoveryv1.EndpointSlice); isEndpointSlice {
+			continue
+		}
+
+		labelTargets = append(labelTargets, target)
+	}
+
+	return labelTargets
+}
+
+/*
+plan the desired metadata of all targets of the source, nothing is written yet.
+labels, annotations and cleanup of excluded pods all change the same listed targets.
+the plan is empty when the targets cannot be listed.
+*/
+func (r *Controller) planReflection(ctx context.Context, source client.Object) (*reflectionPlan, error) {
+	plan, listErr := r.listPlanTargets(ctx, source)
+	if plan == nil {
+		return newReflectionPlan(nil, nil), listErr
+	}
+
+	return plan, multierror.Append(nil, listErr, r.planTargets(ctx, source, plan)).ErrorOrNil()
+}
+
+// list targets and excluded targets of the source into an empty plan.
+// no plan is returned when the targets cannot be listed, excluded targets are left out when they cannot be listed.
+func (r *Controller) listPlanTargets(ctx context.Context, source client.Object) (*reflectionPlan, error) {
+	targets, targetListError := r.getTargets(ctx, source, true)
+	if targetListError != nil {
+		r.logger.Error(targetListError,
+			"Error listing targets for source",
+			"kind", sourceKind(source), "source", source.GetName(),
+		)
+
+		return nil, targetListError
+	}
+
+	// a source without targets is still planned, so excluded targets are cleaned up
+	if len(targets) == 0 {
+		r.addToCounter(reconciliationsWithoutTargetsTotal.WithLabelValues(sourceKind(source)), 1)
+	}
+
+	excludedTargets, excludedListError := r.getExcludedTargets(ctx, source)
+
+	return newReflectionPlan(targets, excludedTargets), excludedListError
+}
+
+// change targets of the plan in memory to their desired metadata.
+func (r *Controller) planTargets(ctx context.Context, source client.Object, plan *reflectionPlan) error {
+	labelsErr := r.reconcileLabels(ctx, source, plan)
+	annotationsErr := r.reconcileAnnotations(source, plan)
+	excludedTargetsErr := r.cleanExcludedTargets(ctx, source, plan)
+
+	return multierror.Append(nil, labelsErr, annotationsErr, excludedTargetsErr).ErrorOrNil()
+}
+
+// write every changed target of the plan with a single patch.
+func (r *Controller) applyPlan(ctx context.Context, plan *reflectionPlan) error {
+	writtenTargets, updateErr := r.updateTargets(ctx, plan.changedTargets, "Failed to update target metadata")
+
+	for _, target := range writtenTargets {
+		plan.written[target] = struct{}{}
+	}
+
+	return updateErr
+}
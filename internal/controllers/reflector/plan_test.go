@@ -0,0 +1,252 @@
+package reflector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_reflectionPlan_markChanged(t *testing.T) {
+	pod1 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}
+	pod2 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2"}}
+
+	plan := newReflectionPlan([]client.Object{pod1, pod2}, nil)
+
+	plan.markChanged(pod2)
+	plan.markChanged(pod1)
+	plan.markChanged(pod2)
+
+	assert.Equal(t, []string{"pod2", "pod1"}, targetNames(plan.changedTargets))
+}
+
+func Test_reflectionPlan_targetsInSync(t *testing.T) {
+	pod1 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}
+	pod2 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2"}}
+	pod3 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod3"}}
+
+	plan := newReflectionPlan([]client.Object{pod1, pod2, pod3}, nil)
+
+	plan.markChanged(pod2)
+	plan.markChanged(pod3)
+
+	assert.Equal(t, 1, plan.targetsInSync(), "Changed targets are out of sync until they are written.")
+
+	plan.written[pod3] = struct{}{}
+
+	assert.Equal(t, 2, plan.targetsInSync())
+}
+
+func Test_reflectionPlan_labelTargets(t *testing.T) {
+	plan := newReflectionPlan([]client.Object{
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}},
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "service1"}},
+		&discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Name: "service1-abcde"}},
+	}, nil)
+
+	assert.Equal(t, []string{"pod1", "service1"}, targetNames(plan.labelTargets()))
+}
+
+func TestController_planReflection(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain):      "team",
+				fmt.Sprintf("%s/list", ReflectorAnnotationsAnnotationDomain): "owner",
+				"owner": "payments@example.com",
+			},
+			Labels: map[string]string{"team": "payments"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		mockSetup   func(mockClient *mockKubernetesClient.MockKubernetesClient)
+		wantChanged []string
+		wantErr     bool
+	}{
+		{
+			name: "Labels and annotations change the same targets",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+					Return(&v1.PodList{Items: []v1.Pod{
+						{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}},
+						{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "default"}},
+					}}, nil)
+			},
+			wantChanged: []string{"pod1", "pod2"},
+			wantErr:     false,
+		},
+		{
+			name: "Targets cannot be listed",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, errors.New("failed to list pods"))
+			},
+			wantChanged: nil,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     zap.New(),
+				config:     &common.Config{},
+			}
+			tt.mockSetup(mockClient)
+
+			plan, err := controller.planReflection(context.Background(), deployment)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			assert.Equal(t, tt.wantChanged, targetNames(plan.changedTargets))
+			mockClient.AssertNotCalled(t, "PatchMetadata", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestController_reconcileSourceWritesTargetsOnce(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config:     &common.Config{BackgroundReflectionInterval: 5 * time.Minute},
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain):      "team",
+				fmt.Sprintf("%s/list", ReflectorAnnotationsAnnotationDomain): "owner",
+				"owner": "payments@example.com",
+			},
+			Labels: map[string]string{"team": "payments"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	}
+
+	mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+		Return(&v1.PodList{Items: []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}}}, nil)
+	// the label and the annotation are written with the same patch
+	mockClient.On("PatchMetadata", mock.Anything, mock.MatchedBy(func(pod *metav1.PartialObjectMetadata) bool {
+		return pod.Name == "pod1" &&
+			pod.Labels["team"] == "payments" &&
+			pod.Annotations["owner"] == "payments@example.com"
+	})).Return(nil).Once()
+
+	got, err := controller.reconcileSource(context.Background(), deployment)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: 5 * time.Minute}, got)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNumberOfCalls(t, "PatchMetadata", 1)
+}
+
+func TestController_reconcileSourceWithoutTargets(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config: &common.Config{
+			BackgroundReflectionInterval: 5 * time.Minute,
+			EnableSourceStatus:           true,
+			SourceStatusInterval:         5 * time.Minute,
+		},
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				ReflectorTargetSelectorAnnotation:                       "track=canary",
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team",
+			},
+			Labels: map[string]string{"team": "payments"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	}
+
+	// the canary track is scaled to zero, while a stable pod still has the reflected label
+	mockClient.On("ListPods", mock.Anything, mock.Anything, mock.MatchedBy(func(selector labels.Selector) bool {
+		return selector.String() == "app=test,track=canary"
+	})).Return(&v1.PodList{}, nil)
+	mockClient.On("ListPods", mock.Anything, mock.Anything, mock.MatchedBy(func(selector labels.Selector) bool {
+		return selector.String() == "app=test"
+	})).Return(&v1.PodList{Items: []v1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "stable-pod",
+			Namespace:   "default",
+			Labels:      map[string]string{"app": "test", "track": "stable", "team": "payments"},
+			Annotations: map[string]string{ReflectorLabelsReflectedAnnotation: "team"},
+		},
+	}}}, nil)
+
+	var status SourceStatus
+
+	mockClient.On("PatchMetadata", mock.Anything, mock.MatchedBy(func(pod *metav1.PartialObjectMetadata) bool {
+		_, hasLabel := pod.Labels["team"]
+
+		return pod.Name == "stable-pod" && !hasLabel
+	})).Return(nil).Once()
+	mockClient.On("PatchMetadata", mock.Anything, mock.MatchedBy(func(source *metav1.PartialObjectMetadata) bool {
+		return source.Name == "test-deployment"
+	})).Run(func(args mock.Arguments) {
+		if source, ok := args.Get(1).(*metav1.PartialObjectMetadata); ok {
+			assert.Nil(t, json.Unmarshal([]byte(source.Annotations[ReflectorStatusAnnotation]), &status))
+		}
+	}).Return(nil).Once()
+
+	got, err := controller.reconcileSource(context.Background(), deployment)
+
+	// excluded pods are cleaned up and the source without targets is reported in its status
+	assert.Nil(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: 5 * time.Minute}, got)
+	assert.Equal(t, 0, status.Targets)
+	assert.Empty(t, status.LastError)
+	mockClient.AssertExpectations(t)
+}
+
+func targetNames(targets []client.Object) []string {
+	var names []string
+
+	for _, target := range targets {
+		names = append(names, target.GetName())
+	}
+
+	return names
+}
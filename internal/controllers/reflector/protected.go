@@ -0,0 +1,136 @@
+package reflector
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// labels that controllers add to their pods to select them, changing them orphans the pod from its controller.
+func controllerManagedLabels() []string {
+	return []string{
+		appsv1.DefaultDeploymentUniqueLabelKey,
+		appsv1.ControllerRevisionHashLabelKey,
+		appsv1.StatefulSetPodNameLabel,
+		appsv1.PodIndexLabel,
+		batchv1.ControllerUidLabel,
+		batchv1.JobNameLabel,
+		batchv1.JobCompletionIndexAnnotation,
+		// labels set on pods of jobs by older versions of Kubernetes
+		"controller-uid",
+		"job-name",
+	}
+}
+
+/*
+get labels of the target that the reflector must never change: labels managed by controllers
+and keys of the selector of the controller owning the pod. a reflected label colliding with such a key
+would orphan the pod, so the controller would create a replacement and the pods would multiply.
+*/
+func (r *Controller) getProtectedLabels(
+	ctx context.Context, source client.Object, target client.Object,
+) ([]string, error) {
+	protectedLabels := controllerManagedLabels()
+
+	if _, ok := target.(*v1.Pod); !ok {
+		return protectedLabels, nil
+	}
+
+	if sourceSelector, selectsPods := sourcePodSelector(source); selectsPods {
+		return append(protectedLabels, selectorKeys(sourceSelector)...), nil
+	}
+
+	controller := metav1.GetControllerOf(target)
+	if controller == nil {
+		return protectedLabels, nil
+	}
+
+	controllerSelector, selectorErr := r.kubeClient.GetControllerSelector(ctx, target.GetNamespace(), *controller)
+	if selectorErr != nil {
+		r.logger.Error(selectorErr, "Failed to get selector of the controller of target",
+			"kind", targetKind(target), "target", target.GetName(),
+			"controllerKind", controller.Kind, "controller", controller.Name,
+		)
+
+		return nil, selectorErr
+	}
+
+	return append(protectedLabels, selectorKeys(controllerSelector)...), nil
+}
+
+/*
+drop protected labels from labels to reflect to the target.
+dropped labels are reported on the source and returned as an error, other labels are still reflected.
+*/
+func (r *Controller) dropProtectedLabels(
+	source client.Object, target client.Object, labelsToReflect map[string]string, protectedLabels []string,
+) (map[string]string, error) {
+	allowedLabels := maps.Clone(labelsToReflect)
+
+	maps.DeleteFunc(allowedLabels, func(label string, _ string) bool {
+		return slices.Contains(protectedLabels, label)
+	})
+
+	if len(allowedLabels) == len(labelsToReflect) {
+		return allowedLabels, nil
+	}
+
+	droppedLabels := common.ExcessiveElements(
+		common.MapKeysAsSlice(allowedLabels), common.MapKeysAsSlice(labelsToReflect))
+	slices.Sort(droppedLabels)
+
+	r.logger.Error(ErrProtectedLabel, "Source reflects labels used to select the target by its controller",
+		"kind", sourceKind(source), "source", source.GetName(),
+		"targetKind", targetKind(target), "target", target.GetName(), "labels", droppedLabels,
+	)
+
+	r.recorder.Eventf(source, target, v1.EventTypeWarning, "ProtectedLabel", "Reflect",
+		"labels %s are used by the controller of %s %s to select it and are never reflected",
+		strings.Join(droppedLabels, ","), targetKind(target), target.GetName())
+
+	// labels selected by the source itself fail the same way until the source changes
+	sourceSelector, _ := sourcePodSelector(source)
+	if slices.ContainsFunc(droppedLabels, func(label string) bool {
+		return slices.Contains(selectorKeys(sourceSelector), label)
+	}) {
+		return allowedLabels, ErrSourceSelectorLabel
+	}
+
+	return allowedLabels, ErrProtectedLabel
+}
+
+// get the selector of the pods of sources that select their pods themselves, i.e. deployments and jobs.
+// pods of deployments are owned by their replica sets, whose selector only adds pod-template-hash.
+func sourcePodSelector(source client.Object) (*metav1.LabelSelector, bool) {
+	switch typedSource := source.(type) {
+	case *appsv1.Deployment:
+		return typedSource.Spec.Selector, true
+	case *batchv1.Job:
+		return typedSource.Spec.Selector, true
+	default:
+		return nil, false
+	}
+}
+
+// get keys a label selector matches on.
+func selectorKeys(selector *metav1.LabelSelector) []string {
+	if selector == nil {
+		return nil
+	}
+
+	keys := slices.Collect(maps.Keys(selector.MatchLabels))
+
+	for _, requirement := range selector.MatchExpressions {
+		keys = append(keys, requirement.Key)
+	}
+
+	return keys
+}
@@ -0,0 +1,239 @@
+package reflector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_getProtectedLabels(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "test"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"backend"}},
+				},
+			},
+		},
+	}
+	configMap := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: SourceKindConfigMap},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-configmap"},
+	}
+	replicaSetReference := metav1.OwnerReference{Kind: "ReplicaSet", Name: "test-replicaset", Controller: ptr.To(true)}
+	ownedPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod1",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{replicaSetReference},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		source    client.Object
+		target    client.Object
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "Pod of a deployment",
+			source:    deployment,
+			target:    ownedPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      append(controllerManagedLabels(), "app", "tier"),
+		},
+		{
+			name:   "Pod of a replica set referencing a config map",
+			source: configMap,
+			target: ownedPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetControllerSelector", mock.Anything, "default", replicaSetReference).
+					Return(&metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}}, nil)
+			},
+			want: append(controllerManagedLabels(), "app"),
+		},
+		{
+			name:   "Failed to get the controller of the pod",
+			source: configMap,
+			target: ownedPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetControllerSelector", mock.Anything, "default", replicaSetReference).
+					Return(nil, errors.New("not found"))
+			},
+			wantErr: true,
+		},
+		{
+			name:      "Pod without a controller",
+			source:    configMap,
+			target:    &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      controllerManagedLabels(),
+		},
+		{
+			name:      "Service",
+			source:    deployment,
+			target:    &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test-service"}},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      controllerManagedLabels(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     zap.New(),
+				config:     &common.Config{},
+			}
+			tt.mockSetup(mockClient)
+
+			got, err := controller.getProtectedLabels(context.Background(), tt.source, tt.target)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.ElementsMatch(t, tt.want, got)
+			}
+
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_reflectLabelsToTargetWithProtectedLabels(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   recorder,
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", UID: "deployment-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	}
+	target := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pod1",
+			Labels: map[string]string{"app": "test", "pod-template-hash": "abcde"},
+		},
+	}
+
+	labelsToReflect := map[string]string{"app": "other", "pod-template-hash": "other", "team": "payments"}
+
+	gotUpdate, err := controller.reflectLabelsToTarget(context.Background(),
+		deployment, target, labelsToReflect, ConflictPolicyOverwrite, 0)
+
+	// selector labels are left untouched while other labels are still reflected
+	assert.ErrorIs(t, err, ErrProtectedLabel)
+	assert.ErrorIs(t, err, ErrSourceSelectorLabel, "The deployment selects its pods by the label itself.")
+	assert.True(t, gotUpdate)
+	assert.Equal(t, map[string]string{"app": "test", "pod-template-hash": "abcde", "team": "payments"}, target.Labels)
+	assert.Equal(t, "Warning ProtectedLabel labels app,pod-template-hash are used by the controller "+
+		"of Pod pod1 to select it and are never reflected", <-recorder.Events)
+	assert.Len(t, labelsToReflect, 3, "Labels to reflect to other targets are not changed.")
+}
+
+func TestController_reflectLabelsToTargetWithProtectedLabelsAndConflict(t *testing.T) {
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   events.NewFakeRecorder(10),
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", UID: "deployment-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	}
+	target := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "test", "team": "billing"}},
+	}
+
+	gotUpdate, err := controller.reflectLabelsToTarget(context.Background(),
+		deployment, target, map[string]string{"app": "other", "team": "payments"}, ConflictPolicyFail, 0)
+
+	// the protected label is still reported when the conflict leaves the target untouched
+	assert.ErrorIs(t, err, ErrKeyConflict)
+	assert.ErrorIs(t, err, ErrSourceSelectorLabel)
+	assert.False(t, gotUpdate)
+}
+
+func TestController_dropProtectedLabelsOfTargetController(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   recorder,
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	}
+	target := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}
+
+	got, err := controller.dropProtectedLabels(deployment, target,
+		map[string]string{"pod-template-hash": "other", "team": "payments"}, []string{"app", "pod-template-hash"})
+
+	// a label protected by the controller of the target depends on the target rather than the source
+	assert.ErrorIs(t, err, ErrProtectedLabel)
+	assert.NotErrorIs(t, err, ErrSourceSelectorLabel)
+	assert.Equal(t, map[string]string{"team": "payments"}, got)
+	assert.Len(t, recorder.Events, 1)
+}
+
+func TestController_restoreLabelsWithProtectedLabels(t *testing.T) {
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pod1",
+			Labels: map[string]string{"app": "test", "team": "payments"},
+		},
+	}
+
+	// the selector label was claimed before it was protected
+	record := ownershipRecord{
+		"app":  {Source: "deployment-uid", Claims: []KeyClaim{{Source: "deployment-uid", Value: "test"}}},
+		"team": {Source: "deployment-uid", Claims: []KeyClaim{{Source: "deployment-uid", Value: "payments"}}},
+	}
+
+	anyLabelUpdated := controller.restoreLabels(
+		record, "deployment-uid", []string{"app", "team"}, []string{"app"}, pod)
+
+	assert.True(t, anyLabelUpdated)
+	assert.Equal(t, map[string]string{"app": "test"}, pod.Labels)
+	assert.Empty(t, record)
+}
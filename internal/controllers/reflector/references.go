@@ -0,0 +1,123 @@
+package reflector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PodReferencedObjectsIndex a pod cache index of config maps and secrets referenced by a pod.
+var PodReferencedObjectsIndex = fmt.Sprintf("%s/referenced-objects", ReflectorAnnotationDomain)
+
+// get pods in the source namespace that reference the config map or secret, the list can be empty.
+func (r *Controller) getReferencingPods(
+	ctx context.Context, source client.Object,
+) (*v1.PodList, error) {
+	sourceName := source.GetName()
+	reference := podReferenceIndexValue(sourceKind(source), sourceName)
+
+	pods, podListError := r.kubeClient.ListPodsByIndex(
+		ctx, source.GetNamespace(), PodReferencedObjectsIndex, reference)
+	if podListError != nil {
+		return nil, podListError
+	}
+
+	r.logger.V(1).Info("Found referencing pods",
+		"kind", sourceKind(source), "source", sourceName, "count", len(pods.Items), "reference", reference)
+
+	return pods, nil
+}
+
+/*
+IndexPodReferencedObjects returns config maps and secrets referenced by the pod
+through volumes, projected volumes, `envFrom` and `valueFrom` of any container.
+*/
+func IndexPodReferencedObjects(object client.Object) []string {
+	pod, ok := object.(*v1.Pod)
+	if !ok {
+		return nil
+	}
+
+	var references []string
+
+	addReference := func(kind string, name string) {
+		if name == "" {
+			return
+		}
+
+		reference := podReferenceIndexValue(kind, name)
+		if !slices.Contains(references, reference) {
+			references = append(references, reference)
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			addReference(SourceKindConfigMap, volume.ConfigMap.Name)
+		}
+
+		if volume.Secret != nil {
+			addReference(SourceKindSecret, volume.Secret.SecretName)
+		}
+
+		if volume.Projected == nil {
+			continue
+		}
+
+		for _, projection := range volume.Projected.Sources {
+			if projection.ConfigMap != nil {
+				addReference(SourceKindConfigMap, projection.ConfigMap.Name)
+			}
+
+			if projection.Secret != nil {
+				addReference(SourceKindSecret, projection.Secret.Name)
+			}
+		}
+	}
+
+	for _, container := range podContainers(pod) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				addReference(SourceKindConfigMap, envFrom.ConfigMapRef.Name)
+			}
+
+			if envFrom.SecretRef != nil {
+				addReference(SourceKindSecret, envFrom.SecretRef.Name)
+			}
+		}
+
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				addReference(SourceKindConfigMap, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+
+			if env.ValueFrom.SecretKeyRef != nil {
+				addReference(SourceKindSecret, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	return references
+}
+
+// get init, regular and ephemeral containers of the pod as they can all reference objects.
+func podContainers(pod *v1.Pod) []v1.Container {
+	containers := slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers)
+
+	for _, ephemeralContainer := range pod.Spec.EphemeralContainers {
+		containers = append(containers, v1.Container(ephemeralContainer.EphemeralContainerCommon))
+	}
+
+	return containers
+}
+
+func podReferenceIndexValue(kind string, name string) string {
+	return fmt.Sprintf("%s/%s", kind, name)
+}
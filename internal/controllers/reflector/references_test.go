@@ -0,0 +1,240 @@
+package reflector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestIndexPodReferencedObjects(t *testing.T) {
+	tests := []struct {
+		name   string
+		object client.Object
+		want   []string
+	}{
+		{
+			name:   "Object is not a pod",
+			object: &v1.ConfigMap{},
+			want:   nil,
+		},
+		{
+			name:   "Pod without references",
+			object: &v1.Pod{},
+			want:   nil,
+		},
+		{
+			name: "Volumes and projected volumes",
+			object: &v1.Pod{
+				Spec: v1.PodSpec{
+					Volumes: []v1.Volume{
+						{
+							VolumeSource: v1.VolumeSource{
+								ConfigMap: &v1.ConfigMapVolumeSource{
+									LocalObjectReference: v1.LocalObjectReference{Name: "config"},
+								},
+							},
+						},
+						{
+							VolumeSource: v1.VolumeSource{
+								Secret: &v1.SecretVolumeSource{SecretName: "credentials"},
+							},
+						},
+						{
+							VolumeSource: v1.VolumeSource{
+								Projected: &v1.ProjectedVolumeSource{
+									Sources: []v1.VolumeProjection{
+										{
+											ConfigMap: &v1.ConfigMapProjection{
+												LocalObjectReference: v1.LocalObjectReference{Name: "projected-config"},
+											},
+										},
+										{
+											Secret: &v1.SecretProjection{
+												LocalObjectReference: v1.LocalObjectReference{Name: "projected-secret"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: []string{
+				"ConfigMap/config",
+				"Secret/credentials",
+				"ConfigMap/projected-config",
+				"Secret/projected-secret",
+			},
+		},
+		{
+			name: "EnvFrom and valueFrom of all container types",
+			object: &v1.Pod{
+				Spec: v1.PodSpec{
+					InitContainers: []v1.Container{
+						{
+							EnvFrom: []v1.EnvFromSource{
+								{
+									SecretRef: &v1.SecretEnvSource{
+										LocalObjectReference: v1.LocalObjectReference{Name: "init-secret"},
+									},
+								},
+							},
+						},
+					},
+					Containers: []v1.Container{
+						{
+							EnvFrom: []v1.EnvFromSource{
+								{
+									ConfigMapRef: &v1.ConfigMapEnvSource{
+										LocalObjectReference: v1.LocalObjectReference{Name: "config"},
+									},
+								},
+							},
+							Env: []v1.EnvVar{
+								{
+									Name:  "PLAIN",
+									Value: "value",
+								},
+								{
+									Name: "FROM_CONFIG",
+									ValueFrom: &v1.EnvVarSource{
+										ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+											LocalObjectReference: v1.LocalObjectReference{Name: "config"},
+											Key:                  "key",
+										},
+									},
+								},
+								{
+									Name: "FROM_SECRET",
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: &v1.SecretKeySelector{
+											LocalObjectReference: v1.LocalObjectReference{Name: "credentials"},
+											Key:                  "password",
+										},
+									},
+								},
+							},
+						},
+					},
+					EphemeralContainers: []v1.EphemeralContainer{
+						{
+							EphemeralContainerCommon: v1.EphemeralContainerCommon{
+								EnvFrom: []v1.EnvFromSource{
+									{
+										ConfigMapRef: &v1.ConfigMapEnvSource{
+											LocalObjectReference: v1.LocalObjectReference{Name: "debug-config"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: []string{
+				"Secret/init-secret",
+				"ConfigMap/config",
+				"Secret/credentials",
+				"ConfigMap/debug-config",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IndexPodReferencedObjects(tt.object)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestController_getReferencingPods(t *testing.T) {
+	configMap := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       SourceKindConfigMap,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-config",
+			Namespace: "default",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		want      *v1.PodList
+		wantErr   bool
+	}{
+		{
+			name: "Successfully get referencing pods",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListPodsByIndex", mock.Anything, "default",
+					PodReferencedObjectsIndex, "ConfigMap/test-config").
+					Return(&v1.PodList{
+						Items: []v1.Pod{
+							{
+								ObjectMeta: metav1.ObjectMeta{
+									Name:      "pod1",
+									Namespace: "default",
+								},
+							},
+						},
+					}, nil)
+			},
+			want: &v1.PodList{
+				Items: []v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod1",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Found no pods",
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListPodsByIndex", mock.Anything, "default",
+					PodReferencedObjectsIndex, "ConfigMap/test-config").
+					Return(&v1.PodList{}, nil)
+			},
+			want:    &v1.PodList{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+			config := &common.Config{}
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     config,
+			}
+			tt.mockSetup(mockClient)
+
+			got, err := controller.getReferencingPods(context.Background(), configMap)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
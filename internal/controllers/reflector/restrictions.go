@@ -0,0 +1,77 @@
+package reflector
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+drop keys the reflector may not write according to the configured allowed and denied keys.
+a key is blocked when it doesn't match any allowed pattern, if there are any, or matches a denied pattern.
+blocked keys are reported, so a source owner can find out why they are not reflected.
+*/
+func (r *Controller) dropBlockedKeys(
+	source client.Object, metadata string, keysToReflect map[string]string,
+) map[string]string {
+	config := r.config.Current()
+
+	// patterns are validated with the configuration, so they always compile
+	allowedKeys := compileKeyPatterns(config.AllowedKeys)
+	deniedKeys := compileKeyPatterns(config.DeniedKeys)
+
+	var blockedKeys []string
+
+	for key := range keysToReflect {
+		allowed := len(allowedKeys) == 0 || matchesAnyPattern(key, allowedKeys)
+		if allowed && !matchesAnyPattern(key, deniedKeys) {
+			continue
+		}
+
+		blockedKeys = append(blockedKeys, key)
+
+		delete(keysToReflect, key)
+	}
+
+	if len(blockedKeys) > 0 {
+		slices.Sort(blockedKeys)
+		r.reportBlockedKeys(source, metadata, blockedKeys)
+	}
+
+	return keysToReflect
+}
+
+// report keys that the source can't reflect through logs, metrics and an event on the source.
+func (r *Controller) reportBlockedKeys(source client.Object, metadata string, blockedKeys []string) {
+	r.logger.Info("Source reflects keys that are not allowed",
+		"kind", sourceKind(source), "source", source.GetName(), "metadata", metadata, "keys", blockedKeys)
+
+	r.addToCounter(blockedKeysTotal.WithLabelValues(sourceKind(source), metadata), len(blockedKeys))
+
+	r.recorder.Eventf(source, nil, v1.EventTypeWarning, "MetadataBlocked", "Reflect",
+		"%s %s are not allowed to be reflected", metadata, strings.Join(blockedKeys, ","))
+}
+
+func compileKeyPatterns(patterns []string) []*regexp.Regexp {
+	regexes := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+
+		regexes = append(regexes, regexp.MustCompile(common.ExactMatchRegex(pattern)))
+	}
+
+	return regexes
+}
+
+func matchesAnyPattern(key string, regexes []*regexp.Regexp) bool {
+	return slices.ContainsFunc(regexes, func(regex *regexp.Regexp) bool {
+		return regex.MatchString(key)
+	})
+}
@@ -0,0 +1,136 @@
+package reflector
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_dropBlockedKeys(t *testing.T) {
+	keysToReflect := map[string]string{
+		"team":                               "payments",
+		"istio.io/rev":                       "canary",
+		"pod-security.kubernetes.io/enforce": "privileged",
+		"example.com/tier":                   "backend",
+	}
+
+	tests := []struct {
+		name        string
+		config      *common.Config
+		want        map[string]string
+		wantBlocked string
+	}{
+		{
+			name:   "Nothing configured",
+			config: &common.Config{},
+			want:   keysToReflect,
+		},
+		{
+			name: "Denied keys",
+			config: &common.Config{
+				DeniedKeys: []string{`pod-security\.kubernetes\.io/.*`, `istio\.io/rev`},
+			},
+			want: map[string]string{"team": "payments", "example.com/tier": "backend"},
+			wantBlocked: "Warning MetadataBlocked labels istio.io/rev,pod-security.kubernetes.io/enforce " +
+				"are not allowed to be reflected",
+		},
+		{
+			name: "Allowed keys",
+			config: &common.Config{
+				AllowedKeys: []string{`example\.com/.*`, "team"},
+			},
+			want: map[string]string{"team": "payments", "example.com/tier": "backend"},
+			wantBlocked: "Warning MetadataBlocked labels istio.io/rev,pod-security.kubernetes.io/enforce " +
+				"are not allowed to be reflected",
+		},
+		{
+			name: "Denied keys take precedence over allowed keys",
+			config: &common.Config{
+				AllowedKeys: []string{`example\.com/.*`, "team"},
+				DeniedKeys:  []string{"team"},
+			},
+			want: map[string]string{"example.com/tier": "backend"},
+			wantBlocked: "Warning MetadataBlocked labels istio.io/rev,pod-security.kubernetes.io/enforce,team " +
+				"are not allowed to be reflected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := events.NewFakeRecorder(10)
+
+			controller := &Controller{
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+				logger:     zap.New(),
+				config:     tt.config,
+				recorder:   recorder,
+			}
+
+			source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"}}
+
+			counter := blockedKeysTotal.WithLabelValues(SourceKindDeployment, "labels")
+			countBefore := testutil.ToFloat64(counter)
+
+			got := controller.dropBlockedKeys(source, "labels", maps.Clone(keysToReflect))
+
+			assert.Equal(t, tt.want, got)
+
+			if tt.wantBlocked == "" {
+				assert.Empty(t, recorder.Events)
+				assert.Equal(t, countBefore, testutil.ToFloat64(counter))
+
+				return
+			}
+
+			assert.Equal(t, tt.wantBlocked, <-recorder.Events)
+			assert.Equal(t, countBefore+float64(len(keysToReflect)-len(tt.want)), testutil.ToFloat64(counter))
+		})
+	}
+}
+
+func TestController_reflectAnnotationsWithDeniedKeys(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{DeniedKeys: []string{`sidecar\.istio\.io/.*`}},
+		recorder:   recorder,
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/regex", ReflectorAnnotationsAnnotationDomain): "sidecar.istio.io/.*",
+				"sidecar.istio.io/inject":                                     "false",
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "test"},
+			},
+		},
+	}
+
+	// all annotations are blocked, so there is nothing to reflect and no pod is changed
+	plan := newReflectionPlan([]client.Object{&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}}, nil)
+
+	err := controller.reflectAnnotations(deployment, plan)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Warning MetadataBlocked annotations sidecar.istio.io/inject are not allowed to be reflected",
+		<-recorder.Events)
+	assert.Empty(t, plan.changedTargets)
+}
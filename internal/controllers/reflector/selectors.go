@@ -0,0 +1,117 @@
+package reflector
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-multierror"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+get the target selector of the deployment that narrows the pods metadata is reflected to.
+the selector should be provided in this format https://pkg.go.dev/k8s.io/apimachinery/pkg/labels#Parse
+a deployment without the annotation gets a selector that matches everything.
+*/
+func (r *Controller) getTargetSelector(deployment *appsv1.Deployment) (labels.Selector, error) {
+	selectorValue, ok := deployment.Annotations[ReflectorTargetSelectorAnnotation]
+	if !ok {
+		return labels.Everything(), nil
+	}
+
+	targetSelector, parseErr := labels.Parse(selectorValue)
+	if parseErr != nil {
+		r.logger.Error(parseErr, "Failed to parse target selector",
+			"deployment", deployment.Name, "annotation", ReflectorTargetSelectorAnnotation, "value", selectorValue)
+
+		return nil, ErrInvalidTargetSelector
+	}
+
+	return targetSelector, nil
+}
+
+// get pods managed by deployment that don't match its target selector.
+func (r *Controller) getExcludedPods(
+	ctx context.Context, deployment *appsv1.Deployment,
+) (*v1.PodList, error) {
+	excludedPods := &v1.PodList{}
+
+	targetSelector, targetSelectorErr := r.getTargetSelector(deployment)
+	if targetSelectorErr != nil {
+		return nil, targetSelectorErr
+	}
+
+	// every managed pod is a target
+	if targetSelector.Empty() {
+		return excludedPods, nil
+	}
+
+	podSelector, selectorErr := r.getDeploymentPodSelector(deployment)
+	if selectorErr != nil {
+		return nil, selectorErr
+	}
+
+	pods, podListError := r.kubeClient.ListPods(ctx, deployment.Namespace, podSelector)
+	if podListError != nil {
+		return nil, podListError
+	}
+
+	for _, pod := range pods.Items {
+		if !targetSelector.Matches(labels.Set(pod.Labels)) {
+			excludedPods.Items = append(excludedPods.Items, pod)
+		}
+	}
+
+	r.logger.V(1).Info("Found pods excluded by target selector",
+		"count", len(excludedPods.Items), "deployment", deployment.Name, "targetSelector", targetSelector.String())
+
+	return excludedPods, nil
+}
+
+// get pods of the source excluded by its target selector, only deployments have a target selector.
+func (r *Controller) getExcludedTargets(ctx context.Context, source client.Object) ([]client.Object, error) {
+	deployment, ok := source.(*appsv1.Deployment)
+	if !ok {
+		return nil, nil
+	}
+
+	pods, podListError := r.getExcludedPods(ctx, deployment)
+	if podListError != nil {
+		r.logger.Error(podListError,
+			"Error listing pods excluded by target selector",
+			"kind", sourceKind(source), "source", source.GetName(),
+		)
+
+		return nil, podListError
+	}
+
+	return podsAsTargets(pods), nil
+}
+
+/*
+unset reflected labels and annotations from pods the source doesn't target anymore.
+a pod can stop being a target when the target selector of a deployment is changed
+or when the pod labels are changed so the pod doesn't match the selector anymore.
+*/
+func (r *Controller) cleanExcludedTargets(ctx context.Context, source client.Object, plan *reflectionPlan) error {
+	var recordErrors *multierror.Error
+
+	for _, target := range plan.excludedTargets {
+		labelsUnset, labelRecordErr := r.unsetReflectedLabelsFromTarget(ctx, source, target)
+		annotationsUnset, annotationRecordErr := r.unsetReflectedAnnotationsFromTarget(source, target)
+
+		if labelRecordErr != nil || annotationRecordErr != nil {
+			recordErrors = multierror.Append(recordErrors, labelRecordErr, annotationRecordErr)
+
+			continue
+		}
+
+		if labelsUnset || annotationsUnset {
+			plan.markChanged(target)
+		}
+	}
+
+	return recordErrors.ErrorOrNil()
+}
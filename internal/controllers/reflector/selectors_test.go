@@ -0,0 +1,225 @@
+package reflector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_getTargetSelector(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "No target selector",
+			annotations: nil,
+			want:        "",
+			wantErr:     false,
+		},
+		{
+			name:        "Equality based target selector",
+			annotations: map[string]string{ReflectorTargetSelectorAnnotation: "track=canary"},
+			want:        "track=canary",
+			wantErr:     false,
+		},
+		{
+			name:        "Set based target selector",
+			annotations: map[string]string{ReflectorTargetSelectorAnnotation: "revision in (v1,v2)"},
+			want:        "revision in (v1,v2)",
+			wantErr:     false,
+		},
+		{
+			name:        "Invalid target selector",
+			annotations: map[string]string{ReflectorTargetSelectorAnnotation: "track==="},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-deployment",
+					Annotations: tt.annotations,
+				},
+			}
+
+			got, err := controller.getTargetSelector(deployment)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTargetSelector)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestController_getExcludedPods(t *testing.T) {
+	canaryPod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "canary-pod",
+			Labels: map[string]string{"app": "test", "track": "canary"},
+		},
+	}
+	stablePod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "stable-pod",
+			Labels: map[string]string{"app": "test", "track": "stable"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		mockSetup   func(*mockKubernetesClient.MockKubernetesClient)
+		want        *v1.PodList
+		wantErr     bool
+	}{
+		{
+			name:        "No target selector",
+			annotations: nil,
+			mockSetup:   func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:        &v1.PodList{},
+			wantErr:     false,
+		},
+		{
+			name:        "Pods not matching the target selector",
+			annotations: map[string]string{ReflectorTargetSelectorAnnotation: "track=canary"},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				// excluded pods are looked up using the deployment selector only
+				mockClient.On("ListPods", mock.Anything, "default", mock.MatchedBy(func(selector labels.Selector) bool {
+					return selector.String() == "app=test"
+				})).Return(&v1.PodList{Items: []v1.Pod{canaryPod, stablePod}}, nil)
+			},
+			want:    &v1.PodList{Items: []v1.Pod{stablePod}},
+			wantErr: false,
+		},
+		{
+			name:        "Invalid target selector",
+			annotations: map[string]string{ReflectorTargetSelectorAnnotation: "track in canary"},
+			mockSetup:   func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:        nil,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+			config := &common.Config{}
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     config,
+			}
+			tt.mockSetup(mockClient)
+
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-deployment",
+					Namespace:   "default",
+					Annotations: tt.annotations,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "test"},
+					},
+				},
+			}
+
+			got, err := controller.getExcludedPods(context.Background(), deployment)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_cleanExcludedTargets(t *testing.T) {
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     logger,
+		config:     config,
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				ReflectorTargetSelectorAnnotation:                       "track=canary",
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team",
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "test"},
+			},
+		},
+	}
+
+	stablePod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "stable-pod",
+			Labels: map[string]string{"app": "test", "track": "stable", "team": "payments"},
+			Annotations: map[string]string{
+				ReflectorLabelsReflectedAnnotation:      "team",
+				ReflectorAnnotationsReflectedAnnotation: "owner",
+				"owner":                                 "payments@example.com",
+				"unrelated":                             "value",
+			},
+		},
+	}
+	cleanPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "clean-pod",
+			Labels: map[string]string{"app": "test", "track": "stable"},
+		},
+	}
+
+	plan := newReflectionPlan(nil, []client.Object{stablePod, cleanPod})
+
+	err := controller.cleanExcludedTargets(context.Background(), deployment, plan)
+
+	// only the stable pod carrying reflected keys is changed
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"stable-pod"}, targetNames(plan.changedTargets))
+	assert.Equal(t, map[string]string{"app": "test", "track": "stable"}, stablePod.Labels)
+	assert.Equal(t, map[string]string{"unrelated": "value"}, stablePod.Annotations)
+}
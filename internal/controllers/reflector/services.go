@@ -0,0 +1,68 @@
+package reflector
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// get a list of services in the deployment namespace that select pods created by the deployment.
+func (r *Controller) getSelectingServices(
+	ctx context.Context, deployment *appsv1.Deployment,
+) ([]v1.Service, error) {
+	services, serviceListError := r.kubeClient.ListServices(ctx, deployment.Namespace)
+	if serviceListError != nil {
+		return nil, serviceListError
+	}
+
+	var selectingServices []v1.Service
+
+	for _, service := range services.Items {
+		if !r.serviceSelectsPodTemplate(service.Spec.Selector, deployment.Spec.Template.Labels) {
+			continue
+		}
+
+		selectingServices = append(selectingServices, service)
+	}
+
+	r.logger.V(1).Info("Found selecting services",
+		"count", len(selectingServices), "deployment", deployment.Name)
+
+	return selectingServices, nil
+}
+
+/*
+check whether a service selector matches pods created from a template with given labels.
+the selector has to be a subset of the template labels, i.e. every key must be present
+in the template with exactly the same value. a service without a selector never matches
+as its endpoints are not managed by kubernetes.
+*/
+func (r *Controller) serviceSelectsPodTemplate(serviceSelector map[string]string, templateLabels map[string]string,
+) bool {
+	if len(serviceSelector) == 0 {
+		return false
+	}
+
+	for key, value := range serviceSelector {
+		templateValue, ok := templateLabels[key]
+		if !ok || templateValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// get a list of endpoint slices that belong to the service.
+func (r *Controller) getServiceEndpointSlices(
+	ctx context.Context, service *v1.Service,
+) (*discoveryv1.EndpointSliceList, error) {
+	endpointSliceSelector := labels.SelectorFromSet(labels.Set{
+		discoveryv1.LabelServiceName: service.Name,
+	})
+
+	return r.kubeClient.ListEndpointSlices(ctx, service.Namespace, endpointSliceSelector)
+}
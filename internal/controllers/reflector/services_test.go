@@ -0,0 +1,241 @@
+package reflector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_serviceSelectsPodTemplate(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	type args struct {
+		serviceSelector map[string]string
+		templateLabels  map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "Selector equals template labels",
+			args: args{
+				serviceSelector: map[string]string{"app": "test"},
+				templateLabels:  map[string]string{"app": "test"},
+			},
+			want: true,
+		},
+		{
+			name: "Selector is a subset of template labels",
+			args: args{
+				serviceSelector: map[string]string{"app": "test"},
+				templateLabels:  map[string]string{"app": "test", "track": "stable"},
+			},
+			want: true,
+		},
+		{
+			name: "Selector has a key missing from template labels",
+			args: args{
+				serviceSelector: map[string]string{"app": "test", "track": "canary"},
+				templateLabels:  map[string]string{"app": "test"},
+			},
+			want: false,
+		},
+		{
+			name: "Selector value differs from template label",
+			args: args{
+				serviceSelector: map[string]string{"app": "other"},
+				templateLabels:  map[string]string{"app": "test"},
+			},
+			want: false,
+		},
+		{
+			name: "Service without a selector",
+			args: args{
+				serviceSelector: map[string]string{},
+				templateLabels:  map[string]string{"app": "test"},
+			},
+			want: false,
+		},
+		{
+			name: "Template without labels",
+			args: args{
+				serviceSelector: map[string]string{"app": "test"},
+				templateLabels:  nil,
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := controller.serviceSelectsPodTemplate(tt.args.serviceSelector, tt.args.templateLabels)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestController_getSelectingServices(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "test", "track": "stable"},
+				},
+			},
+		},
+	}
+
+	type args struct {
+		deployment *appsv1.Deployment
+	}
+	tests := []struct {
+		name      string
+		args      args
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		want      []v1.Service
+		wantErr   bool
+	}{
+		{
+			name: "Only selecting services are returned",
+			args: args{
+				deployment: deployment,
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListServices", mock.Anything, "default").
+					Return(&v1.ServiceList{
+						Items: []v1.Service{
+							{
+								ObjectMeta: metav1.ObjectMeta{Name: "selecting-service"},
+								Spec: v1.ServiceSpec{
+									Selector: map[string]string{"app": "test"},
+								},
+							},
+							{
+								ObjectMeta: metav1.ObjectMeta{Name: "other-service"},
+								Spec: v1.ServiceSpec{
+									Selector: map[string]string{"app": "other"},
+								},
+							},
+							{
+								ObjectMeta: metav1.ObjectMeta{Name: "headless-service"},
+							},
+						},
+					}, nil)
+			},
+			want: []v1.Service{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "selecting-service"},
+					Spec: v1.ServiceSpec{
+						Selector: map[string]string{"app": "test"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Failed to list services",
+			args: args{
+				deployment: deployment,
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("ListServices", mock.Anything, "default").
+					Return(nil, errors.New("failed to list services"))
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+			config := &common.Config{}
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     config,
+			}
+			tt.mockSetup(mockClient)
+
+			got, err := controller.getSelectingServices(context.Background(), tt.args.deployment)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestController_getServiceEndpointSlices(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "default",
+		},
+	}
+
+	expectedEndpointSlices := &discoveryv1.EndpointSliceList{
+		Items: []discoveryv1.EndpointSlice{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-service-abcde",
+					Namespace: "default",
+				},
+			},
+		},
+	}
+
+	mockClient.On("ListEndpointSlices", mock.Anything, "default",
+		mock.MatchedBy(func(selector labels.Selector) bool {
+			return selector.String() == "kubernetes.io/service-name=test-service"
+		})).
+		Return(expectedEndpointSlices, nil)
+
+	got, err := controller.getServiceEndpointSlices(context.Background(), service)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedEndpointSlices, got)
+
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,181 @@
+package reflector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// the maximum length of the error kept in the status, errors of many targets can be long.
+const maxStatusErrorLength = 1024
+
+// the length of the hash of reflector annotations in the status.
+const configHashLength = 16
+
+// SourceStatus a status of the reflection of a source, written to the source as JSON in its status annotation.
+type SourceStatus struct {
+	// the time of the reconciliation the status was computed by
+	LastReconcileTime metav1.Time `json:"lastReconcileTime"`
+	// the generation of the source that was reconciled
+	ObservedGeneration int64 `json:"observedGeneration"`
+	// a hash of the reflector annotations that were reconciled, it changes with the configuration of the source
+	ObservedConfig string `json:"observedConfig"`
+	// the number of targets that have the metadata of the source
+	TargetsInSync int `json:"targetsInSync"`
+	// the number of targets of the source
+	Targets int `json:"targets"`
+	// the error of the reconciliation, empty when it succeeded
+	LastError string `json:"lastError,omitempty"`
+}
+
+/*
+write the status of the reconciliation to the source when ENABLE_SOURCE_STATUS is set, otherwise remove it.
+a status that only refreshes the reconcile time is written at most once per SOURCE_STATUS_INTERVAL
+and every write waits for the write rate limiter, update events of the status itself are filtered out.
+failing to write the status doesn't fail the reconciliation, it's written again by the next one.
+*/
+func (r *Controller) writeSourceStatus(
+	ctx context.Context, source client.Object, plan *reflectionPlan, reconcileErr error,
+) {
+	config := r.config.Current()
+	currentValue, hasStatus := source.GetAnnotations()[ReflectorStatusAnnotation]
+
+	observedConfig := reflectorConfigHash(source)
+
+	if !config.EnableSourceStatus || observedConfig == "" {
+		if hasStatus {
+			r.patchSourceStatus(ctx, source, "")
+		}
+
+		return
+	}
+
+	status := SourceStatus{
+		LastReconcileTime:  metav1.Now(),
+		ObservedGeneration: source.GetGeneration(),
+		ObservedConfig:     observedConfig,
+		TargetsInSync:      plan.targetsInSync(),
+		Targets:            len(plan.targets),
+	}
+
+	if reconcileErr != nil {
+		status.LastError = reconcileErr.Error()
+		if len(status.LastError) > maxStatusErrorLength {
+			status.LastError = truncateAnnotationValue(status.LastError, maxStatusErrorLength)
+		}
+	}
+
+	if !statusNeedsWrite(currentValue, status, config) {
+		return
+	}
+
+	statusValue, marshalErr := json.Marshal(status)
+	if marshalErr != nil {
+		r.logger.Error(marshalErr, "Failed to encode status of source",
+			"kind", sourceKind(source), "source", source.GetName())
+
+		return
+	}
+
+	r.patchSourceStatus(ctx, source, string(statusValue))
+}
+
+// check whether the status changed, or only its reconcile time changed and the last write is old enough.
+func statusNeedsWrite(currentValue string, status SourceStatus, config *common.Config) bool {
+	var currentStatus SourceStatus
+
+	if unmarshalErr := json.Unmarshal([]byte(currentValue), &currentStatus); unmarshalErr != nil {
+		return true
+	}
+
+	refreshedStatus := status
+	refreshedStatus.LastReconcileTime = currentStatus.LastReconcileTime
+
+	if refreshedStatus != currentStatus {
+		return true
+	}
+
+	return status.LastReconcileTime.Sub(currentStatus.LastReconcileTime.Time) >= config.SourceStatusInterval
+}
+
+// set the status annotation of the source, an empty status removes it.
+func (r *Controller) patchSourceStatus(ctx context.Context, source client.Object, statusValue string) {
+	gvk, gvkErr := sourceGroupVersionKind(source)
+	if gvkErr != nil {
+		r.logger.Error(gvkErr, "Failed to write status of source", "source", source.GetName())
+
+		return
+	}
+
+	annotations := maps.Clone(source.GetAnnotations())
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	if statusValue == "" {
+		delete(annotations, ReflectorStatusAnnotation)
+	} else {
+		annotations[ReflectorStatusAnnotation] = statusValue
+	}
+
+	metadata := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            source.GetName(),
+			Namespace:       source.GetNamespace(),
+			ResourceVersion: source.GetResourceVersion(),
+			Labels:          source.GetLabels(),
+			Annotations:     annotations,
+		},
+	}
+
+	if patchErr := r.kubeClient.PatchMetadata(ctx, metadata); patchErr != nil {
+		r.logger.Error(patchErr, "Failed to write status of source",
+			"kind", gvk.Kind, "source", source.GetName())
+	}
+}
+
+// get a hash of the reflector annotations of the source, empty when the source has none.
+func reflectorConfigHash(source client.Object) string {
+	reflectorConfig := common.FindPartialKeys(ReflectorAnnotationDomain, source.GetAnnotations())
+	for _, annotation := range bookkeepingAnnotations() {
+		delete(reflectorConfig, annotation)
+	}
+
+	if len(reflectorConfig) == 0 {
+		return ""
+	}
+
+	var configLines []string
+
+	for _, annotation := range slices.Sorted(maps.Keys(reflectorConfig)) {
+		configLines = append(configLines, annotation+"="+reflectorConfig[annotation])
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(configLines, "\n")))
+
+	return hex.EncodeToString(hash[:])[:configHashLength]
+}
+
+// get the kind of the source, typed sources don't have TypeMeta set when read from the cache.
+func sourceGroupVersionKind(source client.Object) (schema.GroupVersionKind, error) {
+	switch source.(type) {
+	case *appsv1.Deployment:
+		return appsv1.SchemeGroupVersion.WithKind(SourceKindDeployment), nil
+	case *batchv1.CronJob:
+		return batchv1.SchemeGroupVersion.WithKind(SourceKindCronJob), nil
+	default:
+		return targetGroupVersionKind(source)
+	}
+}
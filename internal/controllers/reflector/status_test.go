@@ -0,0 +1,196 @@
+package reflector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_writeSourceStatus(t *testing.T) {
+	reflectorAnnotation := fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain)
+	configHash := reflectorConfigHash(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{reflectorAnnotation: "team"},
+	}})
+
+	statusValue := func(lastReconcileTime time.Time, targetsInSync int) string {
+		status, _ := json.Marshal(SourceStatus{
+			LastReconcileTime: metav1.NewTime(lastReconcileTime),
+			ObservedConfig:    configHash,
+			TargetsInSync:     targetsInSync,
+			Targets:           2,
+		})
+
+		return string(status)
+	}
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		annotations  map[string]string
+		reconcileErr error
+		wantWrite    bool
+		wantStatus   *SourceStatus
+	}{
+		{
+			name:        "Status is disabled",
+			enabled:     false,
+			annotations: map[string]string{reflectorAnnotation: "team"},
+			wantWrite:   false,
+		},
+		{
+			name:    "Status is disabled and removed",
+			enabled: false,
+			annotations: map[string]string{
+				reflectorAnnotation:       "team",
+				ReflectorStatusAnnotation: statusValue(time.Now(), 1),
+			},
+			wantWrite:  true,
+			wantStatus: nil,
+		},
+		{
+			name:        "Status is written",
+			enabled:     true,
+			annotations: map[string]string{reflectorAnnotation: "team"},
+			wantWrite:   true,
+			wantStatus:  &SourceStatus{ObservedConfig: configHash, TargetsInSync: 1, Targets: 2},
+		},
+		{
+			name:         "Status is written with the error",
+			enabled:      true,
+			annotations:  map[string]string{reflectorAnnotation: "team"},
+			reconcileErr: errors.New("conflict"),
+			wantWrite:    true,
+			wantStatus: &SourceStatus{
+				ObservedConfig: configHash, TargetsInSync: 1, Targets: 2, LastError: "conflict",
+			},
+		},
+		{
+			name:    "Unchanged status is not refreshed within the interval",
+			enabled: true,
+			annotations: map[string]string{
+				reflectorAnnotation:       "team",
+				ReflectorStatusAnnotation: statusValue(time.Now(), 1),
+			},
+			wantWrite: false,
+		},
+		{
+			name:    "Unchanged status is refreshed after the interval",
+			enabled: true,
+			annotations: map[string]string{
+				reflectorAnnotation:       "team",
+				ReflectorStatusAnnotation: statusValue(time.Now().Add(-time.Hour), 1),
+			},
+			wantWrite:  true,
+			wantStatus: &SourceStatus{ObservedConfig: configHash, TargetsInSync: 1, Targets: 2},
+		},
+		{
+			name:    "Changed status is written within the interval",
+			enabled: true,
+			annotations: map[string]string{
+				reflectorAnnotation:       "team",
+				ReflectorStatusAnnotation: statusValue(time.Now(), 2),
+			},
+			wantWrite:  true,
+			wantStatus: &SourceStatus{ObservedConfig: configHash, TargetsInSync: 1, Targets: 2},
+		},
+		{
+			name:    "Status of a source without reflector annotations is removed",
+			enabled: true,
+			annotations: map[string]string{
+				ReflectorStatusAnnotation: statusValue(time.Now(), 1),
+			},
+			wantWrite:  true,
+			wantStatus: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     zap.New(),
+				config:     &common.Config{EnableSourceStatus: tt.enabled, SourceStatusInterval: 5 * time.Minute},
+			}
+
+			var written *metav1.PartialObjectMetadata
+
+			mockClient.On("PatchMetadata", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					if metadata, ok := args.Get(1).(*metav1.PartialObjectMetadata); ok {
+						written = metadata
+					}
+				}).Return(nil)
+
+			source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Name: "test-deployment", Namespace: "default", Annotations: tt.annotations,
+			}}
+
+			pod1 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}
+			pod2 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2"}}
+			plan := newReflectionPlan([]client.Object{pod1, pod2}, nil)
+			plan.markChanged(pod2)
+
+			controller.writeSourceStatus(context.Background(), source, plan, tt.reconcileErr)
+
+			if !tt.wantWrite {
+				mockClient.AssertNotCalled(t, "PatchMetadata", mock.Anything, mock.Anything)
+
+				return
+			}
+
+			if !assert.NotNil(t, written) {
+				return
+			}
+
+			assert.Equal(t, "Deployment", written.Kind)
+			assert.Equal(t, tt.annotations[reflectorAnnotation], written.Annotations[reflectorAnnotation])
+
+			writtenValue, hasStatus := written.Annotations[ReflectorStatusAnnotation]
+			if tt.wantStatus == nil {
+				assert.False(t, hasStatus)
+
+				return
+			}
+
+			var status SourceStatus
+
+			assert.Nil(t, json.Unmarshal([]byte(writtenValue), &status))
+			assert.WithinDuration(t, time.Now(), status.LastReconcileTime.Time, time.Minute)
+
+			status.LastReconcileTime = metav1.Time{}
+			assert.Equal(t, *tt.wantStatus, status)
+		})
+	}
+}
+
+func Test_reflectorConfigHash(t *testing.T) {
+	source := func(annotations map[string]string) client.Object {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	}
+
+	listAnnotation := fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain)
+
+	assert.Empty(t, reflectorConfigHash(source(map[string]string{"owner": "payments"})))
+	assert.Empty(t, reflectorConfigHash(source(map[string]string{ReflectorStatusAnnotation: "{}"})))
+	assert.Equal(t,
+		reflectorConfigHash(source(map[string]string{listAnnotation: "team"})),
+		reflectorConfigHash(source(map[string]string{listAnnotation: "team", ReflectorStatusAnnotation: "{}"})),
+		"The status is not part of the configuration of the source.")
+	assert.NotEqual(t,
+		reflectorConfigHash(source(map[string]string{listAnnotation: "team"})),
+		reflectorConfigHash(source(map[string]string{listAnnotation: "team,owner"})))
+}
@@ -0,0 +1,196 @@
+package reflector
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// get a list of objects the source metadata should be reflected to.
+func (r *Controller) getTargets(
+	ctx context.Context, source client.Object, includeEndpointSlices bool,
+) ([]client.Object, error) {
+	switch sourceKind(source) {
+	case SourceKindDeployment:
+		deployment, ok := source.(*appsv1.Deployment)
+		if !ok {
+			return nil, ErrUnsupportedSource
+		}
+
+		return r.getDeploymentTargets(ctx, deployment, includeEndpointSlices)
+	case SourceKindCronJob:
+		cronJob, ok := source.(*batchv1.CronJob)
+		if !ok {
+			return nil, ErrUnsupportedSource
+		}
+
+		return r.getCronJobTargets(ctx, cronJob)
+	case SourceKindJob:
+		job, ok := source.(*batchv1.Job)
+		if !ok {
+			return nil, ErrUnsupportedSource
+		}
+
+		return r.getJobTargets(ctx, job)
+	case SourceKindConfigMap, SourceKindSecret:
+		pods, podListError := r.getReferencingPods(ctx, source)
+		if podListError != nil {
+			return nil, podListError
+		}
+
+		return podsAsTargets(pods), nil
+	default:
+		return nil, ErrUnsupportedSource
+	}
+}
+
+// get a list of objects the deployment metadata should be reflected to.
+// pods are always included, services and endpoint slices only when enabled in the configuration.
+func (r *Controller) getDeploymentTargets(
+	ctx context.Context, deployment *appsv1.Deployment, includeEndpointSlices bool,
+) ([]client.Object, error) {
+	pods, podListError := r.getManagedPods(ctx, deployment)
+	if podListError != nil {
+		return nil, podListError
+	}
+
+	targets := podsAsTargets(pods)
+
+	// the configuration can be reloaded in the meantime, so it's read once
+	config := r.config.Current()
+	includeEndpointSlices = includeEndpointSlices && config.EnableEndpointSliceReflection
+
+	if !config.EnableServiceReflection && !includeEndpointSlices {
+		return targets, nil
+	}
+
+	services, serviceListError := r.getSelectingServices(ctx, deployment)
+	if serviceListError != nil {
+		return nil, serviceListError
+	}
+
+	for i := range services {
+		service := &services[i]
+
+		if config.EnableServiceReflection {
+			targets = append(targets, service)
+		}
+
+		if !includeEndpointSlices {
+			continue
+		}
+
+		endpointSlices, endpointSliceListError := r.getServiceEndpointSlices(ctx, service)
+		if endpointSliceListError != nil {
+			return nil, endpointSliceListError
+		}
+
+		for j := range endpointSlices.Items {
+			targets = append(targets, &endpointSlices.Items[j])
+		}
+	}
+
+	return targets, nil
+}
+
+func podsAsTargets(pods *v1.PodList) []client.Object {
+	targets := make([]client.Object, 0, len(pods.Items))
+
+	for i := range pods.Items {
+		targets = append(targets, &pods.Items[i])
+	}
+
+	return targets
+}
+
+/*
+persist the labels and annotations of a target of any kind, only the metadata is patched.
+every update spends a write of the budget of the reconciliation.
+conflicting values of sources are surfaced as a condition of pods once their metadata is updated,
+setting the condition spends another write.
+*/
+func (r *Controller) updateTarget(ctx context.Context, target client.Object) error {
+	gvk, gvkErr := targetGroupVersionKind(target)
+	if gvkErr != nil {
+		return gvkErr
+	}
+
+	if budgetErr := spendWrite(ctx); budgetErr != nil {
+		return budgetErr
+	}
+
+	metadata := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            target.GetName(),
+			Namespace:       target.GetNamespace(),
+			ResourceVersion: target.GetResourceVersion(),
+			Labels:          target.GetLabels(),
+			Annotations:     target.GetAnnotations(),
+		},
+	}
+
+	if patchErr := r.kubeClient.PatchMetadata(ctx, metadata); patchErr != nil {
+		return patchErr
+	}
+
+	if pod, isPod := target.(*v1.Pod); isPod {
+		return r.syncConflictCondition(ctx, pod)
+	}
+
+	return nil
+}
+
+// get the kind of the target, typed targets don't have TypeMeta set when read from the cache
+// while metadata-only targets always carry their GroupVersionKind.
+func targetGroupVersionKind(target client.Object) (schema.GroupVersionKind, error) {
+	switch typedTarget := target.(type) {
+	case *v1.Pod:
+		return v1.SchemeGroupVersion.WithKind("Pod"), nil
+	case *v1.Service:
+		return v1.SchemeGroupVersion.WithKind("Service"), nil
+	case *discoveryv1.EndpointSlice:
+		return discoveryv1.SchemeGroupVersion.WithKind("EndpointSlice"), nil
+	case *batchv1.Job:
+		return batchv1.SchemeGroupVersion.WithKind(SourceKindJob), nil
+	case *metav1.PartialObjectMetadata:
+		if typedTarget.GroupVersionKind().Kind == "" {
+			return schema.GroupVersionKind{}, ErrUnsupportedTarget
+		}
+
+		return typedTarget.GroupVersionKind(), nil
+	default:
+		return schema.GroupVersionKind{}, ErrUnsupportedTarget
+	}
+}
+
+// get a human-readable kind of the target.
+func targetKind(target client.Object) string {
+	gvk, gvkErr := targetGroupVersionKind(target)
+	if gvkErr != nil {
+		return "Unknown"
+	}
+
+	return gvk.Kind
+}
+
+// get the kind of the source, typed sources don't have TypeMeta set when read from the cache
+// while metadata-only sources always carry their GroupVersionKind.
+func sourceKind(source client.Object) string {
+	switch source.(type) {
+	case *appsv1.Deployment:
+		return SourceKindDeployment
+	case *batchv1.CronJob:
+		return SourceKindCronJob
+	case *batchv1.Job:
+		return SourceKindJob
+	default:
+		return source.GetObjectKind().GroupVersionKind().Kind
+	}
+}
@@ -0,0 +1,292 @@
+package reflector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_getTargets(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "test"},
+			},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "test"},
+				},
+			},
+		},
+	}
+
+	pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}
+	service := v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "service1", Namespace: "default"},
+		Spec:       v1.ServiceSpec{Selector: map[string]string{"app": "test"}},
+	}
+	endpointSlice := discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: "service1-abcde", Namespace: "default"},
+	}
+
+	mockPods := func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+		mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).
+			Return(&v1.PodList{Items: []v1.Pod{pod}}, nil)
+	}
+	mockServices := func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+		mockClient.On("ListServices", mock.Anything, "default").
+			Return(&v1.ServiceList{Items: []v1.Service{service}}, nil)
+	}
+	mockEndpointSlices := func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+		mockClient.On("ListEndpointSlices", mock.Anything, "default", mock.Anything).
+			Return(&discoveryv1.EndpointSliceList{Items: []discoveryv1.EndpointSlice{endpointSlice}}, nil)
+	}
+
+	type args struct {
+		includeEndpointSlices bool
+	}
+	tests := []struct {
+		name      string
+		config    *common.Config
+		args      args
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		want      []client.Object
+	}{
+		{
+			name:   "Only pods by default",
+			config: &common.Config{},
+			args: args{
+				includeEndpointSlices: true,
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockPods(mockClient)
+			},
+			want: []client.Object{&pod},
+		},
+		{
+			name:   "Pods and services",
+			config: &common.Config{EnableServiceReflection: true},
+			args: args{
+				includeEndpointSlices: true,
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockPods(mockClient)
+				mockServices(mockClient)
+			},
+			want: []client.Object{&pod, &service},
+		},
+		{
+			name:   "Pods, services and endpoint slices",
+			config: &common.Config{EnableServiceReflection: true, EnableEndpointSliceReflection: true},
+			args: args{
+				includeEndpointSlices: true,
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockPods(mockClient)
+				mockServices(mockClient)
+				mockEndpointSlices(mockClient)
+			},
+			want: []client.Object{&pod, &service, &endpointSlice},
+		},
+		{
+			name:   "Endpoint slices are not included when not requested",
+			config: &common.Config{EnableEndpointSliceReflection: true},
+			args: args{
+				includeEndpointSlices: false,
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockPods(mockClient)
+			},
+			want: []client.Object{&pod},
+		},
+		{
+			name:   "Endpoint slices without services",
+			config: &common.Config{EnableEndpointSliceReflection: true},
+			args: args{
+				includeEndpointSlices: true,
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockPods(mockClient)
+				mockServices(mockClient)
+				mockEndpointSlices(mockClient)
+			},
+			want: []client.Object{&pod, &endpointSlice},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     tt.config,
+			}
+			tt.mockSetup(mockClient)
+
+			got, err := controller.getTargets(context.Background(), deployment, tt.args.includeEndpointSlices)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_updateTarget(t *testing.T) {
+	errConflict := errors.New("conflict")
+
+	tests := []struct {
+		name      string
+		target    client.Object
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		wantErr   error
+	}{
+		{
+			name:   "Update pod",
+			target: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("PatchMetadata", mock.Anything, metadataOfKind("v1", "Pod")).Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "Update service",
+			target: &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "service1"}},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("PatchMetadata", mock.Anything, metadataOfKind("v1", "Service")).Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "Update endpoint slice",
+			target: &discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Name: "service1-abcde"}},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("PatchMetadata", mock.Anything, metadataOfKind("discovery.k8s.io/v1", "EndpointSlice")).
+					Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name: "Update metadata-only target",
+			target: &metav1.PartialObjectMetadata{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+				ObjectMeta: metav1.ObjectMeta{Name: "statefulset1"},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("PatchMetadata", mock.Anything, metadataOfKind("apps/v1", "StatefulSet")).Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "Update failed",
+			target: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("PatchMetadata", mock.Anything, metadataOfKind("v1", "Pod")).Return(errConflict)
+			},
+			wantErr: errConflict,
+		},
+		{
+			name:      "Unsupported target",
+			target:    &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "configmap1"}},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			wantErr:   ErrUnsupportedTarget,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			logger := zap.New()
+			config := &common.Config{}
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     logger,
+				config:     config,
+			}
+			tt.mockSetup(mockClient)
+
+			err := controller.updateTarget(context.Background(), tt.target)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.Nil(t, err)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_getTargetsForReferencedSource(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{EnableServiceReflection: true}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	secret := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: SourceKindSecret},
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+	}
+	pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}
+
+	// services are only resolved for deployments
+	mockClient.On("ListPodsByIndex", mock.Anything, "default", PodReferencedObjectsIndex, "Secret/credentials").
+		Return(&v1.PodList{Items: []v1.Pod{pod}}, nil)
+
+	got, err := controller.getTargets(context.Background(), secret, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []client.Object{&pod}, got)
+	mockClient.AssertExpectations(t)
+}
+
+func TestController_getTargetsForUnsupportedSource(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	got, err := controller.getTargets(context.Background(), &v1.Pod{}, false)
+
+	assert.ErrorIs(t, err, ErrUnsupportedSource)
+	assert.Nil(t, got)
+}
+
+// match metadata of an object of the given kind patched instead of the whole object.
+func metadataOfKind(apiVersion string, kind string) any {
+	return mock.MatchedBy(func(metadata *metav1.PartialObjectMetadata) bool {
+		return metadata.APIVersion == apiVersion && metadata.Kind == kind
+	})
+}
@@ -2,6 +2,7 @@ package reflector
 
 import (
 	"context"
+	"fmt"
 	"regexp"
 	"slices"
 	"strings"
@@ -10,14 +11,47 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// get a list of pods managed by deployment.
+// get a list of pods managed by deployment, the list is empty when the deployment is scaled to zero.
+// when the deployment has a target selector, only pods matching both selectors are returned.
 func (r *Controller) getManagedPods(
 	ctx context.Context, deployment *appsv1.Deployment,
 ) (*v1.PodList, error) {
 	deploymentName := deployment.Name
 
+	podSelector, selectorErr := r.getDeploymentPodSelector(deployment)
+	if selectorErr != nil {
+		return nil, selectorErr
+	}
+
+	targetSelector, targetSelectorErr := r.getTargetSelector(deployment)
+	if targetSelectorErr != nil {
+		return nil, targetSelectorErr
+	}
+
+	if requirements, selectable := targetSelector.Requirements(); selectable {
+		podSelector = podSelector.Add(requirements...)
+	}
+
+	pods, podListError := r.kubeClient.ListPods(ctx, deployment.Namespace, podSelector)
+	if podListError != nil {
+		return nil, podListError
+	}
+
+	// a deployment scaled to zero has no pods, which is a normal state rather than an error
+	r.logger.V(1).Info("Found Managed pods",
+		"deployment", deploymentName, "count", len(pods.Items), "selector", podSelector.String())
+
+	return pods, nil
+}
+
+// get the selector of pods managed by deployment, a selector that would match everything is an error.
+func (r *Controller) getDeploymentPodSelector(deployment *appsv1.Deployment) (labels.Selector, error) {
+	deploymentName := deployment.Name
+
 	podSelector, selectorErr := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
 	if selectorErr != nil {
 		r.logger.Error(selectorErr,
@@ -34,57 +68,53 @@ func (r *Controller) getManagedPods(
 		return nil, ErrEmptyPodSelector
 	}
 
-	pods, podListError := r.kubeClient.ListPods(ctx, podSelector)
-	if podListError != nil {
-		return nil, podListError
-	}
+	return podSelector, nil
+}
 
-	if len(pods.Items) == 0 {
-		r.logger.Error(ErrPodNotFound, "Could not find pods for deployment",
-			"deployment", deploymentName, "selector", podSelector.String())
+// find reflector annotations of the given domain on the source.
+func (r *Controller) findReflectorAnnotations(domain string, source client.Object) map[string]string {
+	return FindReflectorAnnotations(domain, source)
+}
 
-		return nil, ErrPodNotFound
-	}
+/*
+FindReflectorAnnotations find reflector annotations of the given domain on the object.
+bookkeeping annotations are skipped as an object can be both a target and a source, e.g. a job of a cron job.
+*/
+func FindReflectorAnnotations(domain string, object metav1.Object) map[string]string {
+	reflectorAnnotations := common.FindPartialKeys(domain, object.GetAnnotations())
 
-	r.logger.V(1).Info("Found Managed pods",
-		"count", len(pods.Items), "selector", podSelector.String())
+	for _, annotation := range bookkeepingAnnotations() {
+		delete(reflectorAnnotations, annotation)
+	}
 
-	return pods, nil
+	return reflectorAnnotations
 }
 
 func (r *Controller) validateAnnotation(annotation string) error {
+	validationErr := ValidateAnnotation(annotation)
+	if validationErr != nil {
+		r.logger.Error(validationErr, "Invalid reflector annotation", "annotation", annotation,
+			"supportedDomains", supportedAnnotationDomains(), "supportedOperations", supportedOperations())
+	}
+
+	return validationErr
+}
+
+// ValidateAnnotation check that the reflector annotation has a supported domain and operation.
+func ValidateAnnotation(annotation string) error {
 	expectedAnnotationParts := 2
 	annotationKeyParts := strings.Split(annotation, "/")
 
 	if len(annotationKeyParts) != expectedAnnotationParts {
-		r.logger.Error(ErrUnparsableAnnotation,
-			"Annotation should consist of exactly 2 parts",
-			"annotation", annotation, "parts", len(annotationKeyParts),
-		)
-
-		return ErrUnparsableAnnotation
+		return fmt.Errorf("%w: %s should consist of exactly 2 parts", ErrUnparsableAnnotation, annotation)
 	}
 
-	annDomains := supportedAnnotationDomains()
-
-	if !slices.Contains(annDomains, annotationKeyParts[0]) {
-		r.logger.Error(ErrUnparsableAnnotation,
-			"Annotation does not contain a valid annotation domain",
-			"annotation", annotation, "supportedOperations", annDomains,
-		)
-
-		return ErrUnparsableAnnotation
+	if !slices.Contains(supportedAnnotationDomains(), annotationKeyParts[0]) {
+		return fmt.Errorf("%w: %s does not contain a valid annotation domain", ErrUnparsableAnnotation, annotation)
 	}
 
-	operations := supportedOperations()
-
-	if !slices.Contains(operations, annotationKeyParts[1]) {
-		r.logger.Error(ErrUnparsableOperation,
-			"Annotation does not contain a valid operation",
-			"annotation", annotation, "supportedOperations", operations,
-		)
-
-		return ErrUnparsableOperation
+	if !slices.Contains(supportedOperations(), annotationKeyParts[1]) {
+		return fmt.Errorf("%w: %s does not contain a valid operation", ErrUnparsableOperation, annotation)
 	}
 
 	return nil
@@ -97,11 +127,24 @@ find key-value paris that need to be reflected.
 func (r *Controller) keysToReflect(
 	reflectorAnnotations map[string]string, data map[string]string,
 ) (map[string]string, error) {
+	keysToReflect, matchErr := KeysToReflect(reflectorAnnotations, data)
+	if matchErr != nil {
+		r.logger.Error(matchErr, "Reflector annotations cannot be matched against keys of the source")
+	}
+
+	return keysToReflect, matchErr
+}
+
+/*
+KeysToReflect find key-value pairs of the data matched by reflector annotations, e.g. labels of a deployment
+matched by its labels.metadata-reflector.spaceship.com/list annotation.
+the matching doesn't depend on the configuration of the controller, so it can be done offline.
+*/
+func KeysToReflect(reflectorAnnotations map[string]string, data map[string]string) (map[string]string, error) {
 	keysToReflect := make(map[string]string)
 
 	for annKey, annValue := range reflectorAnnotations {
-		annValidationErr := r.validateAnnotation(annKey)
-		if annValidationErr != nil {
+		if annValidationErr := ValidateAnnotation(annKey); annValidationErr != nil {
 			return nil, annValidationErr
 		}
 
@@ -120,8 +163,11 @@ func (r *Controller) keysToReflect(
 			}
 
 		case ReflectorOperationRegex:
-			pattern := common.ExactMatchRegex(annValue)
-			regex := regexp.MustCompile(pattern)
+			regex, regexErr := regexp.Compile(common.ExactMatchRegex(annValue))
+			if regexErr != nil {
+				return nil, fmt.Errorf("%w: %s has an invalid regular expression: %w",
+					ErrUnparsableOperation, annKey, regexErr)
+			}
 
 			for key, value := range data {
 				if !regex.MatchString(key) {
@@ -132,10 +178,7 @@ func (r *Controller) keysToReflect(
 			}
 
 		default:
-			r.logger.Error(ErrUnparsableOperation,
-				"Annotation doesn't have a valid operation to parse", "annotation", annKey)
-
-			return nil, ErrUnparsableOperation
+			return nil, fmt.Errorf("%w: %s", ErrUnparsableOperation, annKey)
 		}
 	}
 
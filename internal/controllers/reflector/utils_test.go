@@ -7,6 +7,8 @@ import (
 	"github.com/NCCloud/metadata-reflector/internal/common"
 	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
 	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
@@ -137,6 +139,19 @@ func TestController_keysToReflect(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "Invalid regex",
+			args: args{
+				reflectorAnnotations: map[string]string{
+					fmt.Sprintf("%s/regex", ReflectorLabelsAnnotationDomain): "key[",
+				},
+				labels: map[string]string{
+					"key1": "value1",
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name: "Unsupported operation",
 			args: args{
@@ -164,3 +179,32 @@ func TestController_keysToReflect(t *testing.T) {
 		})
 	}
 }
+
+func TestController_findReflectorAnnotations(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	logger := zap.New()
+	config := &common.Config{}
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     logger,
+		config:     config,
+	}
+
+	// a job spawned by a cron job is a target and can also be a source
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain):      "team",
+				ReflectorLabelsOwnershipAnnotation:                           `{"team":{"source":"cronjob-uid"}}`,
+				ReflectorLabelsReflectedAnnotation:                           "team",
+				fmt.Sprintf("%s/list", ReflectorAnnotationsAnnotationDomain): "owner",
+			},
+		},
+	}
+
+	got := controller.findReflectorAnnotations(ReflectorLabelsAnnotationDomain, job)
+
+	assert.Equal(t, map[string]string{fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team"}, got)
+}
@@ -0,0 +1,116 @@
+package reflector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// sanitizedValueHashLength a length of the hash suffix making sanitized values of different values distinct.
+	sanitizedValueHashLength = 8
+)
+
+// illegalLabelValueCharacters characters that can't be part of a label value.
+var illegalLabelValueCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+/*
+drop or sanitize labels the API server would reject, so a single invalid label doesn't fail the whole target update.
+labels with invalid keys are always dropped, invalid values are dropped or sanitized according to the configuration.
+dropped labels are reported and returned as an error while valid labels are still reflected.
+*/
+func (r *Controller) validateLabels(
+	source client.Object, labelsToReflect map[string]string,
+) (map[string]string, error) {
+	sanitize := r.config.Current().InvalidLabelPolicy == InvalidLabelPolicySanitize
+
+	validLabels := make(map[string]string, len(labelsToReflect))
+
+	var rejectedLabels, sanitizedLabels []string
+
+	for key, value := range labelsToReflect {
+		if keyErrs := validation.IsQualifiedName(key); len(keyErrs) > 0 {
+			rejectedLabels = append(rejectedLabels, fmt.Sprintf("key %q: %s", key, strings.Join(keyErrs, ", ")))
+
+			continue
+		}
+
+		valueErrs := validation.IsValidLabelValue(value)
+
+		switch {
+		case len(valueErrs) == 0:
+			validLabels[key] = value
+		case sanitize:
+			validLabels[key] = sanitizeLabelValue(value)
+
+			sanitizedLabels = append(sanitizedLabels, key)
+		default:
+			rejectedLabels = append(rejectedLabels,
+				fmt.Sprintf("value %q of %q: %s", value, key, strings.Join(valueErrs, ", ")))
+		}
+	}
+
+	if len(sanitizedLabels) > 0 {
+		slices.Sort(sanitizedLabels)
+
+		r.logger.Info("Sanitized invalid label values of source",
+			"kind", sourceKind(source), "source", source.GetName(), "labels", sanitizedLabels)
+
+		r.addToCounter(invalidLabelsTotal.WithLabelValues(sourceKind(source), InvalidLabelPolicySanitize),
+			len(sanitizedLabels))
+	}
+
+	if len(rejectedLabels) == 0 {
+		return validLabels, nil
+	}
+
+	slices.Sort(rejectedLabels)
+	r.reportInvalidLabels(source, rejectedLabels)
+
+	return validLabels, ErrInvalidLabel
+}
+
+// report labels that the source can't reflect through logs, metrics and an event on the source.
+func (r *Controller) reportInvalidLabels(source client.Object, rejectedLabels []string) {
+	r.logger.Info("Source reflects invalid labels",
+		"kind", sourceKind(source), "source", source.GetName(), "labels", rejectedLabels)
+
+	r.addToCounter(invalidLabelsTotal.WithLabelValues(sourceKind(source), InvalidLabelPolicyReject),
+		len(rejectedLabels))
+
+	r.recorder.Eventf(source, nil, v1.EventTypeWarning, "InvalidLabel", "Reflect",
+		"labels cannot be reflected: %s", strings.Join(rejectedLabels, "; "))
+}
+
+/*
+make a label value valid: illegal characters are replaced with dashes, the value is truncated
+and a hash of the original value is appended, so different values stay distinct after sanitization.
+the same value is always sanitized the same way, so targets aren't updated on every reconciliation.
+*/
+func sanitizeLabelValue(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	hashSuffix := hex.EncodeToString(hash[:])[:sanitizedValueHashLength]
+
+	sanitizedValue := illegalLabelValueCharacters.ReplaceAllString(value, "-")
+
+	// the value and the hash are separated by a dash
+	maxValueLength := validation.LabelValueMaxLength - sanitizedValueHashLength - 1
+	if len(sanitizedValue) > maxValueLength {
+		sanitizedValue = sanitizedValue[:maxValueLength]
+	}
+
+	// label values must begin and end with an alphanumeric character
+	sanitizedValue = strings.Trim(sanitizedValue, "._-")
+	if sanitizedValue == "" {
+		return hashSuffix
+	}
+
+	return sanitizedValue + "-" + hashSuffix
+}
@@ -0,0 +1,193 @@
+package reflector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestController_validateLabels(t *testing.T) {
+	labelsToReflect := map[string]string{
+		"team":        "payments",
+		"owner":       "john doe",
+		"invalid key": "value",
+	}
+
+	tests := []struct {
+		name         string
+		policy       string
+		want         map[string]string
+		wantErr      error
+		wantRejected int
+	}{
+		{
+			name:         "Reject",
+			policy:       InvalidLabelPolicyReject,
+			want:         map[string]string{"team": "payments"},
+			wantErr:      ErrInvalidLabel,
+			wantRejected: 2,
+		},
+		{
+			name:         "Sanitize",
+			policy:       InvalidLabelPolicySanitize,
+			want:         map[string]string{"team": "payments", "owner": sanitizeLabelValue("john doe")},
+			wantErr:      ErrInvalidLabel,
+			wantRejected: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := events.NewFakeRecorder(10)
+
+			controller := &Controller{
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+				logger:     zap.New(),
+				config:     &common.Config{InvalidLabelPolicy: tt.policy},
+				recorder:   recorder,
+			}
+
+			source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"}}
+
+			counter := invalidLabelsTotal.WithLabelValues(SourceKindDeployment, InvalidLabelPolicyReject)
+			countBefore := testutil.ToFloat64(counter)
+
+			got, err := controller.validateLabels(source, labelsToReflect)
+
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Len(t, labelsToReflect, 3, "Labels to reflect are not mutated.")
+			assert.Equal(t, countBefore+float64(tt.wantRejected), testutil.ToFloat64(counter))
+
+			event := <-recorder.Events
+			assert.True(t, strings.HasPrefix(event, "Warning InvalidLabel labels cannot be reflected: "))
+			assert.Contains(t, event, `key "invalid key"`)
+		})
+	}
+}
+
+func TestController_validateLabelsWithValidLabels(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   recorder,
+	}
+
+	source := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"}}
+	labelsToReflect := map[string]string{"team": "payments", "example.com/tier": "backend", "empty": ""}
+
+	got, err := controller.validateLabels(source, labelsToReflect)
+
+	assert.Nil(t, err)
+	assert.Equal(t, labelsToReflect, got)
+	assert.Empty(t, recorder.Events)
+}
+
+func Test_sanitizeLabelValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "Illegal characters", value: "john doe <john@example.com>"},
+		{name: "Too long", value: strings.Repeat("a", 100)},
+		{name: "Illegal first and last characters", value: "-value-"},
+		{name: "Only illegal characters", value: "@@@"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeLabelValue(tt.value)
+
+			assert.Empty(t, validation.IsValidLabelValue(got))
+			assert.Equal(t, got, sanitizeLabelValue(tt.value), "The same value is sanitized the same way.")
+		})
+	}
+
+	// illegal characters are replaced and a hash of the original value is appended
+	assert.Regexp(t, `^john-doe--john-example\.com-[0-9a-f]{8}$`, sanitizeLabelValue("john doe <john@example.com>"))
+	assert.Regexp(t, `^value-[0-9a-f]{8}$`, sanitizeLabelValue("-value-"))
+	assert.NotEqual(t, sanitizeLabelValue(strings.Repeat("a", 100)), sanitizeLabelValue(strings.Repeat("a", 101)))
+}
+
+func TestController_reflectLabelsWithInvalidLabels(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   recorder,
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team,owner",
+			},
+			Labels: map[string]string{"team": "payments", "owner": "john doe"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "test"},
+			},
+		},
+	}
+
+	// the valid label is still reflected
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}
+	plan := newReflectionPlan([]client.Object{pod}, nil)
+
+	err := controller.reflectLabels(context.Background(), deployment, plan)
+
+	assert.ErrorIs(t, err, ErrInvalidLabel)
+	assert.Contains(t, <-recorder.Events, `value "john doe" of "owner"`)
+	assert.Equal(t, []string{"pod1"}, targetNames(plan.changedTargets))
+	assert.Equal(t, "payments", pod.Labels["team"])
+	assert.NotContains(t, pod.Labels, "owner")
+}
+
+func TestController_reflectLabelsWithInvalidLabelsAndPriority(t *testing.T) {
+	controller := &Controller{
+		kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+		logger:     zap.New(),
+		config:     &common.Config{},
+		recorder:   events.NewFakeRecorder(10),
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team,owner",
+				ReflectorPriorityAnnotation:                             "high",
+			},
+			Labels: map[string]string{"team": "payments", "owner": "john doe"},
+		},
+	}
+
+	plan := newReflectionPlan([]client.Object{&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}}}, nil)
+
+	err := controller.reflectLabels(context.Background(), deployment, plan)
+
+	// invalid labels are still reported when the source can't be reflected at all
+	assert.ErrorIs(t, err, ErrInvalidPriority)
+	assert.ErrorIs(t, err, ErrInvalidLabel)
+	assert.Empty(t, plan.changedTargets)
+}
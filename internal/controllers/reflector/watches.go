@@ -0,0 +1,275 @@
+package reflector
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+/*
+FilterPodUpdateEvents check whether a pod changed in a way that requires its sources to be reconciled,
+so drift is corrected immediately rather than on the next background reflection.
+changes of owners and labels that weren't reflected are relevant, e.g. a pod relabeled to match a target selector
+or a pod adopted by a controller, as well as reflected keys that no longer match their ownership records,
+e.g. a reflected label removed by hand. writes of the reflector keep reflected keys and records in line,
+so they don't enqueue sources again.
+*/
+func (r *Controller) FilterPodUpdateEvents(e event.UpdateEvent) bool {
+	oldPod, oldIsPod := e.ObjectOld.(*v1.Pod)
+	newPod, newIsPod := e.ObjectNew.(*v1.Pod)
+
+	if !oldIsPod || !newIsPod {
+		return false
+	}
+
+	if !reflect.DeepEqual(oldPod.GetOwnerReferences(), newPod.GetOwnerReferences()) {
+		return true
+	}
+
+	oldLabelsRecord := recordOf(oldPod, ReflectorLabelsOwnershipAnnotation, oldPod.GetLabels())
+	newLabelsRecord := recordOf(newPod, ReflectorLabelsOwnershipAnnotation, newPod.GetLabels())
+
+	if !maps.Equal(
+		unreflectedKeys(oldPod.GetLabels(), oldLabelsRecord, newLabelsRecord),
+		unreflectedKeys(newPod.GetLabels(), oldLabelsRecord, newLabelsRecord),
+	) {
+		return true
+	}
+
+	oldAnnotationsRecord := recordOf(oldPod, ReflectorAnnotationsOwnershipAnnotation, oldPod.GetAnnotations())
+	newAnnotationsRecord := recordOf(newPod, ReflectorAnnotationsOwnershipAnnotation, newPod.GetAnnotations())
+
+	return hasNewDrift(oldLabelsRecord, newLabelsRecord, oldPod.GetLabels(), newPod.GetLabels()) ||
+		hasNewDrift(oldAnnotationsRecord, newAnnotationsRecord, oldPod.GetAnnotations(), newPod.GetAnnotations())
+}
+
+/*
+FilterPodCreateEvents check whether the created object is a pod, every new pod is reflected to right away,
+regardless of whether it ever becomes ready, e.g. when pods are replaced one by one during a rolling update
+or crash loop, so reflection doesn't depend on the status of the source.
+*/
+func (r *Controller) FilterPodCreateEvents(e event.CreateEvent) bool {
+	_, isPod := e.Object.(*v1.Pod)
+
+	return isPod
+}
+
+// get predicates of pod events that require sources of the pod to be reconciled.
+// deleted pods don't need any reflection, sources of the pods are reconciled when replacements are created.
+func (r *Controller) podPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return r.FilterPodCreateEvents(e)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return r.FilterPodUpdateEvents(e)
+		},
+		DeleteFunc: func(_ event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(_ event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+// get an event handler enqueuing sources of the given kind that reflect metadata to the pod.
+func (r *Controller) podSourcesHandler(kind string) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, object client.Object) []reconcile.Request {
+		pod, ok := object.(*v1.Pod)
+		if !ok {
+			return nil
+		}
+
+		return r.mapPodToSources(ctx, kind, pod)
+	})
+}
+
+/*
+find sources of the given kind that reflect metadata to the pod.
+deployments and jobs are found through the owner chain of the pod,
+config maps and secrets through the objects referenced by the pod.
+sources are not required to exist or to have reflector annotations, missing sources are skipped by the reconciliation
+and sources without reflector annotations only have their keys released, without being reflected in the background.
+*/
+func (r *Controller) mapPodToSources(ctx context.Context, kind string, pod *v1.Pod) []reconcile.Request {
+	var names []string
+
+	switch kind {
+	case SourceKindDeployment:
+		names = append(names, podDeploymentName(pod))
+	case SourceKindJob:
+		names = append(names, podJobName(pod))
+	case SourceKindCronJob:
+		names = append(names, r.podCronJobName(ctx, pod))
+	case SourceKindConfigMap, SourceKindSecret:
+		for _, reference := range IndexPodReferencedObjects(pod) {
+			if name, ok := strings.CutPrefix(reference, podReferenceIndexValue(kind, "")); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	requests := make([]reconcile.Request, 0, len(names))
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: pod.GetNamespace(), Name: name},
+		})
+	}
+
+	return requests
+}
+
+// get the name of the deployment of the pod from the name of its replica set,
+// which is the name of the deployment followed by the pod template hash.
+func podDeploymentName(pod *v1.Pod) string {
+	controller := metav1.GetControllerOf(pod)
+	if controller == nil || controller.Kind != "ReplicaSet" {
+		return ""
+	}
+
+	podTemplateHash, ok := pod.GetLabels()[appsv1.DefaultDeploymentUniqueLabelKey]
+	if !ok {
+		return ""
+	}
+
+	deploymentName, ok := strings.CutSuffix(controller.Name, "-"+podTemplateHash)
+	if !ok {
+		return ""
+	}
+
+	return deploymentName
+}
+
+func podJobName(pod *v1.Pod) string {
+	controller := metav1.GetControllerOf(pod)
+	if controller == nil || controller.Kind != SourceKindJob {
+		return ""
+	}
+
+	return controller.Name
+}
+
+// get the name of the cron job that spawned the job of the pod.
+func (r *Controller) podCronJobName(ctx context.Context, pod *v1.Pod) string {
+	jobName := podJobName(pod)
+	if jobName == "" {
+		return ""
+	}
+
+	job, getJobErr := r.kubeClient.GetJob(ctx, types.NamespacedName{Namespace: pod.GetNamespace(), Name: jobName})
+	if getJobErr != nil {
+		r.logger.V(1).Info("Failed to get job of pod", "pod", pod.GetName(), "job", jobName, "error", getJobErr)
+
+		return ""
+	}
+
+	controller := metav1.GetControllerOf(job)
+	if controller == nil || controller.Kind != SourceKindCronJob {
+		return ""
+	}
+
+	return controller.Name
+}
+
+// get the ownership record of the pod, a missing or unparsable record is empty.
+func recordOf(pod *v1.Pod, recordAnnotation string, targetKeys map[string]string) ownershipRecord {
+	recordValue, ok := pod.GetAnnotations()[recordAnnotation]
+	if !ok {
+		return nil
+	}
+
+	record, parseErr := parseOwnershipRecord(recordValue, targetKeys)
+	if parseErr != nil {
+		return nil
+	}
+
+	return record
+}
+
+// get keys that aren't recorded in any of the ownership records, i.e. keys the reflector doesn't manage.
+func unreflectedKeys(keys map[string]string, records ...ownershipRecord) map[string]string {
+	unreflected := maps.Clone(keys)
+
+	maps.DeleteFunc(unreflected, func(key string, _ string) bool {
+		return slices.ContainsFunc(records, func(record ownershipRecord) bool {
+			_, recorded := record[key]
+
+			return recorded
+		})
+	})
+
+	return unreflected
+}
+
+// get recorded keys whose value on the pod differs from the winning claim, along with the value on the pod, if any.
+func driftedKeys(record ownershipRecord, keys map[string]string) map[string]*string {
+	drifted := make(map[string]*string)
+
+	for key, ownership := range record {
+		if len(ownership.Claims) == 0 {
+			continue
+		}
+
+		value, present := keys[key]
+		if !present {
+			drifted[key] = nil
+		} else if value != ownership.Claims[0].Value {
+			drifted[key] = &value
+		}
+	}
+
+	return drifted
+}
+
+/*
+check whether keys of the pod drifted from the ownership record in a way they didn't before the update.
+drift that was already there has been seen, e.g. keys of a source that keeps failing to reflect.
+*/
+func hasNewDrift(
+	oldRecord ownershipRecord, newRecord ownershipRecord, oldKeys map[string]string, newKeys map[string]string,
+) bool {
+	newDrift := driftedKeys(newRecord, newKeys)
+	if len(newDrift) > 0 && !maps.EqualFunc(driftedKeys(oldRecord, oldKeys), newDrift, ptr.Equal[string]) {
+		return true
+	}
+
+	return hasOrphanedKeys(oldRecord, newRecord, newKeys)
+}
+
+/*
+check whether keys left the ownership record while keeping their reflected value, e.g. a record removed by hand.
+the reflector removes keys it releases or restores their prior value.
+*/
+func hasOrphanedKeys(oldRecord ownershipRecord, newRecord ownershipRecord, keys map[string]string) bool {
+	for key, ownership := range oldRecord {
+		if _, recorded := newRecord[key]; recorded || len(ownership.Claims) == 0 {
+			continue
+		}
+
+		value, present := keys[key]
+		if present && value == ownership.Claims[0].Value && !ptr.Equal(ownership.PriorValue, &value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,390 @@
+package reflector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestController_FilterPodUpdateEvents(t *testing.T) {
+	labelsRecord := `{"team":{"source":"deployment-uid","claims":[{"source":"deployment-uid","value":"payments"}]}}`
+	annotationsRecord := `{"example.com/config":{"source":"deployment-uid",` +
+		`"claims":[{"source":"deployment-uid","value":"value"}]}}`
+
+	reflectedPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pod1",
+			Labels: map[string]string{"app": "test", "team": "payments"},
+			Annotations: map[string]string{
+				ReflectorLabelsOwnershipAnnotation:      labelsRecord,
+				ReflectorAnnotationsOwnershipAnnotation: annotationsRecord,
+				"example.com/config":                    "value",
+				"example.com/other":                     "value",
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		update func(pod *v1.Pod)
+		want   bool
+	}{
+		{
+			name:   "Nothing changed",
+			update: func(_ *v1.Pod) {},
+			want:   false,
+		},
+		{
+			name:   "Reflected label removed",
+			update: func(pod *v1.Pod) { delete(pod.Labels, "team") },
+			want:   true,
+		},
+		{
+			name:   "Reflected label changed",
+			update: func(pod *v1.Pod) { pod.Labels["team"] = "billing" },
+			want:   true,
+		},
+		{
+			name:   "Label added",
+			update: func(pod *v1.Pod) { pod.Labels["track"] = "canary" },
+			want:   true,
+		},
+		{
+			name:   "Ownership record removed",
+			update: func(pod *v1.Pod) { delete(pod.Annotations, ReflectorLabelsOwnershipAnnotation) },
+			want:   true,
+		},
+		{
+			name: "Label reflected by the reflector",
+			update: func(pod *v1.Pod) {
+				pod.Labels["track"] = "canary"
+				pod.Annotations[ReflectorLabelsOwnershipAnnotation] = `{` +
+					`"team":{"source":"deployment-uid","claims":[{"source":"deployment-uid","value":"payments"}]},` +
+					`"track":{"source":"deployment-uid","claims":[{"source":"deployment-uid","value":"canary"}]}}`
+			},
+			want: false,
+		},
+		{
+			name: "Label released by the reflector",
+			update: func(pod *v1.Pod) {
+				delete(pod.Labels, "team")
+				delete(pod.Annotations, ReflectorLabelsOwnershipAnnotation)
+			},
+			want: false,
+		},
+		{
+			name: "Annotation released by the reflector",
+			update: func(pod *v1.Pod) {
+				delete(pod.Annotations, "example.com/config")
+				pod.Annotations[ReflectorAnnotationsOwnershipAnnotation] = `{}`
+			},
+			want: false,
+		},
+		{
+			name:   "Reflected annotation changed",
+			update: func(pod *v1.Pod) { pod.Annotations["example.com/config"] = "other" },
+			want:   true,
+		},
+		{
+			name:   "Annotation that wasn't reflected changed",
+			update: func(pod *v1.Pod) { pod.Annotations["example.com/other"] = "other" },
+			want:   false,
+		},
+		{
+			name: "Owner changed",
+			update: func(pod *v1.Pod) {
+				pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "test-replicaset"}}
+			},
+			want: true,
+		},
+		{
+			name:   "Status changed",
+			update: func(pod *v1.Pod) { pod.Status.Phase = v1.PodRunning },
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := &Controller{logger: zap.New(), config: &common.Config{}}
+
+			newPod := reflectedPod.DeepCopy()
+			tt.update(newPod)
+
+			got := controller.FilterPodUpdateEvents(event.UpdateEvent{ObjectOld: reflectedPod, ObjectNew: newPod})
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	controller := &Controller{logger: zap.New(), config: &common.Config{}}
+
+	driftedPod := reflectedPod.DeepCopy()
+	delete(driftedPod.Labels, "team")
+
+	updatedPod := driftedPod.DeepCopy()
+	updatedPod.Status.Phase = v1.PodRunning
+
+	assert.False(t, controller.FilterPodUpdateEvents(event.UpdateEvent{ObjectOld: driftedPod, ObjectNew: updatedPod}),
+		"Drift that was already there doesn't enqueue sources again.")
+	assert.False(t, controller.FilterPodUpdateEvents(event.UpdateEvent{
+		ObjectOld: &appsv1.Deployment{}, ObjectNew: &appsv1.Deployment{},
+	}), "Only pods are filtered.")
+}
+
+func TestController_mapPodToSources(t *testing.T) {
+	replicaSetPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment-abcde-12345",
+			Namespace: "default",
+			Labels:    map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "abcde"},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "test-deployment-abcde", Controller: ptr.To(true)},
+			},
+		},
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{
+				{VolumeSource: v1.VolumeSource{
+					ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "config"}},
+				}},
+				{VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "credentials"}}},
+			},
+		},
+	}
+	jobPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-job-12345",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: SourceKindJob, Name: "test-job", Controller: ptr.To(true)},
+			},
+		},
+	}
+	jobNamespacedName := types.NamespacedName{Namespace: "default", Name: "test-job"}
+
+	request := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+	}
+
+	tests := []struct {
+		name      string
+		kind      string
+		pod       *v1.Pod
+		mockSetup func(*mockKubernetesClient.MockKubernetesClient)
+		want      []reconcile.Request
+	}{
+		{
+			name:      "Deployment",
+			kind:      SourceKindDeployment,
+			pod:       replicaSetPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      []reconcile.Request{request("test-deployment")},
+		},
+		{
+			name: "Replica set without a deployment",
+			kind: SourceKindDeployment,
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-replicaset-12345",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "ReplicaSet", Name: "test-replicaset", Controller: ptr.To(true)},
+					},
+				},
+			},
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      []reconcile.Request{},
+		},
+		{
+			name:      "Job",
+			kind:      SourceKindJob,
+			pod:       jobPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      []reconcile.Request{request("test-job")},
+		},
+		{
+			name: "Cron job",
+			kind: SourceKindCronJob,
+			pod:  jobPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetJob", mock.Anything, jobNamespacedName).Return(&batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-job",
+						OwnerReferences: []metav1.OwnerReference{
+							{Kind: SourceKindCronJob, Name: "test-cronjob", Controller: ptr.To(true)},
+						},
+					},
+				}, nil)
+			},
+			want: []reconcile.Request{request("test-cronjob")},
+		},
+		{
+			name: "Job of the pod not found",
+			kind: SourceKindCronJob,
+			pod:  jobPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {
+				mockClient.On("GetJob", mock.Anything, jobNamespacedName).Return(nil, errors.New("not found"))
+			},
+			want: []reconcile.Request{},
+		},
+		{
+			name:      "Config map",
+			kind:      SourceKindConfigMap,
+			pod:       replicaSetPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      []reconcile.Request{request("config")},
+		},
+		{
+			name:      "Secret",
+			kind:      SourceKindSecret,
+			pod:       replicaSetPod,
+			mockSetup: func(mockClient *mockKubernetesClient.MockKubernetesClient) {},
+			want:      []reconcile.Request{request("credentials")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+			controller := &Controller{
+				kubeClient: mockClient,
+				logger:     zap.New(),
+				config:     &common.Config{},
+			}
+			tt.mockSetup(mockClient)
+
+			got := controller.mapPodToSources(context.Background(), tt.kind, tt.pod)
+
+			assert.Equal(t, tt.want, got)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_podEvents(t *testing.T) {
+	annotatedDeployment := func(readyReplicas int32, podTemplateHash string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-deployment",
+				Namespace: "default",
+				Annotations: map[string]string{
+					fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team",
+				},
+				Labels: map[string]string{"team": "payments"},
+			},
+			Status: appsv1.DeploymentStatus{
+				ReadyReplicas: readyReplicas,
+				Conditions: []appsv1.DeploymentCondition{
+					{Type: appsv1.DeploymentProgressing, Message: podTemplateHash},
+				},
+			},
+		}
+	}
+	deploymentPod := func(podTemplateHash string, ready bool, restarts int32) *v1.Pod {
+		readyStatus := v1.ConditionFalse
+		if ready {
+			readyStatus = v1.ConditionTrue
+		}
+
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-deployment-" + podTemplateHash + "-12345",
+				Namespace: "default",
+				Labels:    map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: podTemplateHash},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ReplicaSet", Name: "test-deployment-" + podTemplateHash, Controller: ptr.To(true)},
+				},
+			},
+			Status: v1.PodStatus{
+				Conditions:        []v1.PodCondition{{Type: v1.PodReady, Status: readyStatus}},
+				ContainerStatuses: []v1.ContainerStatus{{Name: "app", RestartCount: restarts}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		sourceUpdate      *event.UpdateEvent
+		podCreate         *v1.Pod
+		podUpdate         *event.UpdateEvent
+		podDelete         *v1.Pod
+		wantSourceTrigger bool
+		wantPodTrigger    bool
+	}{
+		{
+			name: "Rolling update replacing pods at a constant ready count",
+			sourceUpdate: &event.UpdateEvent{
+				ObjectOld: annotatedDeployment(3, "abcde"),
+				ObjectNew: annotatedDeployment(3, "fghij"),
+			},
+			podCreate:         deploymentPod("fghij", false, 0),
+			wantSourceTrigger: false,
+			wantPodTrigger:    true,
+		},
+		{
+			name: "Crash-looping pod that never becomes ready",
+			podUpdate: &event.UpdateEvent{
+				ObjectOld: deploymentPod("abcde", false, 3),
+				ObjectNew: deploymentPod("abcde", false, 4),
+			},
+			podCreate:         deploymentPod("abcde", false, 0),
+			wantSourceTrigger: false,
+			wantPodTrigger:    true,
+		},
+		{
+			name: "Scale to zero",
+			sourceUpdate: &event.UpdateEvent{
+				ObjectOld: annotatedDeployment(3, "abcde"),
+				ObjectNew: annotatedDeployment(0, "abcde"),
+			},
+			podDelete:         deploymentPod("abcde", true, 0),
+			wantSourceTrigger: false,
+			wantPodTrigger:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := &Controller{
+				kubeClient: new(mockKubernetesClient.MockKubernetesClient),
+				logger:     zap.New(),
+				config:     &common.Config{},
+			}
+			podPredicate := controller.podPredicate()
+
+			if tt.sourceUpdate != nil {
+				assert.Equal(t, tt.wantSourceTrigger, controller.FilterUpdateEvents(*tt.sourceUpdate))
+			}
+
+			if tt.podUpdate != nil {
+				assert.False(t, podPredicate.Update(*tt.podUpdate), "Status changes of pods are ignored.")
+			}
+
+			if tt.podDelete != nil {
+				assert.False(t, podPredicate.Delete(event.DeleteEvent{Object: tt.podDelete}))
+			}
+
+			if tt.podCreate == nil {
+				return
+			}
+
+			assert.Equal(t, tt.wantPodTrigger, podPredicate.Create(event.CreateEvent{Object: tt.podCreate}))
+			assert.Equal(t,
+				[]reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "test-deployment"}}},
+				controller.mapPodToSources(context.Background(), SourceKindDeployment, tt.podCreate))
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package reflector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// writeBudgetRequeueAfter a delay before a reconciliation that spent its write budget continues,
+// so other sources get their turn in the meantime.
+const writeBudgetRequeueAfter = time.Second
+
+type writeBudgetKey struct{}
+
+// get a context carrying a budget of writes to targets shared by all phases of a reconciliation.
+// a budget of 0 writes is unlimited.
+func withWriteBudget(ctx context.Context, maxWrites int) context.Context {
+	if maxWrites == 0 {
+		return ctx
+	}
+
+	remainingWrites := &atomic.Int64{}
+	remainingWrites.Store(int64(maxWrites))
+
+	return context.WithValue(ctx, writeBudgetKey{}, remainingWrites)
+}
+
+// spend a write of the budget of the reconciliation, fails once the budget is spent.
+func spendWrite(ctx context.Context) error {
+	remainingWrites, ok := ctx.Value(writeBudgetKey{}).(*atomic.Int64)
+	if !ok {
+		return nil
+	}
+
+	if remainingWrites.Add(-1) < 0 {
+		return ErrWriteBudgetSpent
+	}
+
+	return nil
+}
+
+/*
+requeue the source once its write budget is spent, remaining targets are written by the next reconciliation.
+targets that are already up to date don't need any writes, so every reconciliation makes progress.
+returns whether the budget was spent along with the remaining errors of the reconciliation.
+*/
+func (r *Controller) requeueOnSpentWriteBudget(
+	source client.Object, reflectorErrors *multierror.Error,
+) (ctrl.Result, bool, error) {
+	var remainingErrors *multierror.Error
+
+	budgetSpent := false
+
+	for _, reflectorErr := range reflectorErrors.WrappedErrors() {
+		if errors.Is(reflectorErr, ErrWriteBudgetSpent) {
+			budgetSpent = true
+
+			continue
+		}
+
+		remainingErrors = multierror.Append(remainingErrors, reflectorErr)
+	}
+
+	if !budgetSpent {
+		return ctrl.Result{}, false, reflectorErrors.ErrorOrNil()
+	}
+
+	r.logger.Info("Write budget of the reconciliation is spent, remaining targets are reflected to later",
+		"kind", sourceKind(source), "source", source.GetName(),
+		"maxWritesPerReconcile", r.config.Current().MaxWritesPerReconcile)
+
+	writeBudgetSpentTotal.WithLabelValues(sourceKind(source)).Inc()
+
+	return ctrl.Result{RequeueAfter: writeBudgetRequeueAfter}, true, remainingErrors.ErrorOrNil()
+}
+
+/*
+persist targets with a bounded number of concurrent writes, configured with MAX_CONCURRENT_TARGET_WRITES.
+errors of all targets are aggregated and logged with the given message, targets that were written are returned.
+once the write budget of the reconciliation is spent, remaining targets are not written.
+*/
+func (r *Controller) updateTargets(
+	ctx context.Context, targets []client.Object, failureMessage string,
+) ([]client.Object, error) {
+	var (
+		targetUpdateErrors *multierror.Error
+		writtenTargets     []client.Object
+		resultsLock        sync.Mutex
+		writers            sync.WaitGroup
+		budgetSpent        atomic.Bool
+	)
+
+	// a slot is taken for every write in progress, so writes wait until a slot is released
+	writerSlots := make(chan struct{}, max(r.config.Current().MaxConcurrentTargetWrites, 1))
+
+	for _, target := range targets {
+		writerSlots <- struct{}{}
+
+		if budgetSpent.Load() {
+			break
+		}
+
+		writers.Go(func() {
+			defer func() { <-writerSlots }()
+
+			updateErr := r.updateTarget(ctx, target)
+			if updateErr == nil {
+				resultsLock.Lock()
+				defer resultsLock.Unlock()
+
+				writtenTargets = append(writtenTargets, target)
+
+				return
+			}
+
+			if errors.Is(updateErr, ErrWriteBudgetSpent) {
+				budgetSpent.Store(true)
+			} else {
+				r.logger.Error(updateErr, failureMessage, "kind", targetKind(target), "target", target.GetName())
+			}
+
+			resultsLock.Lock()
+			defer resultsLock.Unlock()
+
+			targetUpdateErrors = multierror.Append(targetUpdateErrors, updateErr)
+		})
+	}
+
+	writers.Wait()
+
+	return writtenTargets, targetUpdateErrors.ErrorOrNil()
+}
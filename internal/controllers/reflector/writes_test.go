@@ -0,0 +1,194 @@
+package reflector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	mockKubernetesClient "github.com/NCCloud/metadata-reflector/mocks/github.com/NCCloud/metadata-reflector/internal_/clients"
+	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func podTargets(count int) []client.Object {
+	targets := make([]client.Object, 0, count)
+
+	for i := range count {
+		targets = append(targets, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod%d", i)}})
+	}
+
+	return targets
+}
+
+func Test_spendWrite(t *testing.T) {
+	// without a budget writes are unlimited
+	assert.Nil(t, spendWrite(context.Background()))
+	assert.Equal(t, context.Background(), withWriteBudget(context.Background(), 0))
+
+	ctx := withWriteBudget(context.Background(), 2)
+
+	assert.Nil(t, spendWrite(ctx))
+	assert.Nil(t, spendWrite(ctx))
+	assert.ErrorIs(t, spendWrite(ctx), ErrWriteBudgetSpent)
+}
+
+func TestController_reconcileSourceWithWriteBudget(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config: &common.Config{
+			MaxWritesPerReconcile:        2,
+			BackgroundReflectionInterval: 5 * time.Minute,
+		},
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-deployment",
+			Namespace:   "default",
+			Annotations: map[string]string{fmt.Sprintf("%s/list", ReflectorLabelsAnnotationDomain): "team"},
+			Labels:      map[string]string{"team": "payments"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	}
+
+	// pods as they are stored, patches are applied to them and every list returns copies like the cache does
+	storedPods := map[string]*metav1.PartialObjectMetadata{}
+
+	mockClient.On("ListPods", mock.Anything, mock.Anything, mock.Anything).Return(
+		func(context.Context, string, labels.Selector) (*v1.PodList, error) {
+			pods := &v1.PodList{}
+
+			for _, name := range []string{"pod1", "pod2", "pod3"} {
+				pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+				if storedPod, ok := storedPods[name]; ok {
+					pod.ObjectMeta = *storedPod.ObjectMeta.DeepCopy()
+				}
+
+				pods.Items = append(pods.Items, pod)
+			}
+
+			return pods, nil
+		})
+	mockClient.On("PatchMetadata", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			if pod, ok := args.Get(1).(*metav1.PartialObjectMetadata); ok {
+				storedPods[pod.GetName()] = pod.DeepCopy()
+			}
+		}).
+		Return(nil)
+
+	counter := writeBudgetSpentTotal.WithLabelValues(SourceKindDeployment)
+	countBefore := testutil.ToFloat64(counter)
+
+	got, err := controller.reconcileSource(context.Background(), deployment)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: writeBudgetRequeueAfter}, got)
+	assert.Equal(t, countBefore+1, testutil.ToFloat64(counter))
+	mockClient.AssertNumberOfCalls(t, "PatchMetadata", 2)
+	assert.NotContains(t, storedPods, "pod3")
+
+	// the next reconciliation continues with the remaining pod
+	got, err = controller.reconcileSource(context.Background(), deployment)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: 5 * time.Minute}, got)
+	mockClient.AssertNumberOfCalls(t, "PatchMetadata", 3)
+	assert.Equal(t, "payments", storedPods["pod3"].Labels["team"])
+}
+
+func TestController_updateTargets(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config:     &common.Config{MaxConcurrentTargetWrites: 4},
+	}
+
+	var (
+		inFlight, maxInFlight atomic.Int32
+		allWritersBusy        = make(chan struct{})
+		closeOnce             sync.Once
+	)
+
+	mockClient.On("PatchMetadata", mock.Anything, mock.Anything).
+		Run(func(_ mock.Arguments) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			for observed := maxInFlight.Load(); current > observed; observed = maxInFlight.Load() {
+				if maxInFlight.CompareAndSwap(observed, current) {
+					break
+				}
+			}
+
+			// the first writes wait for each other, which only succeeds if they run concurrently
+			if current == 4 {
+				closeOnce.Do(func() { close(allWritersBusy) })
+			}
+
+			select {
+			case <-allWritersBusy:
+			case <-time.After(5 * time.Second):
+				t.Error("Writes did not run concurrently")
+			}
+		}).
+		Return(func(_ context.Context, object *metav1.PartialObjectMetadata) error {
+			if object.GetName() == "pod3" || object.GetName() == "pod7" {
+				return errors.New("conflict")
+			}
+
+			return nil
+		})
+
+	writtenTargets, err := controller.updateTargets(
+		context.Background(), podTargets(20), "Failed to update target metadata")
+
+	mockClient.AssertNumberOfCalls(t, "PatchMetadata", 20)
+	assert.Equal(t, int32(4), maxInFlight.Load())
+	assert.Len(t, writtenTargets, 18)
+
+	var targetUpdateErrors *multierror.Error
+
+	assert.ErrorAs(t, err, &targetUpdateErrors)
+	assert.Len(t, targetUpdateErrors.Errors, 2, "Errors of all targets are aggregated.")
+}
+
+func TestController_updateTargetsWithWriteBudget(t *testing.T) {
+	mockClient := new(mockKubernetesClient.MockKubernetesClient)
+
+	controller := &Controller{
+		kubeClient: mockClient,
+		logger:     zap.New(),
+		config:     &common.Config{MaxConcurrentTargetWrites: 3},
+	}
+
+	mockClient.On("PatchMetadata", mock.Anything, mock.Anything).Return(nil)
+
+	writtenTargets, err := controller.updateTargets(
+		withWriteBudget(context.Background(), 5), podTargets(20), "Failed to update target metadata")
+
+	assert.ErrorIs(t, err, ErrWriteBudgetSpent)
+	assert.Len(t, writtenTargets, 5)
+	mockClient.AssertNumberOfCalls(t, "PatchMetadata", 5)
+}
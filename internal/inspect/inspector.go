@@ -0,0 +1,395 @@
+package inspect
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/NCCloud/metadata-reflector/internal/clients"
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	"github.com/NCCloud/metadata-reflector/internal/controllers/reflector"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// types of findings of an audit.
+var (
+	// FindingOutOfSync a target the plan of its source would change
+	FindingOutOfSync = "OutOfSync"
+	// FindingOrphanedReflectedList a legacy reflected-list annotation of an object no source targets anymore,
+	// so it's never migrated nor cleaned up
+	FindingOrphanedReflectedList = "OrphanedReflectedList"
+	// FindingOrphanedClaim keys claimed by a source that doesn't exist anymore
+	FindingOrphanedClaim = "OrphanedClaim"
+	// FindingError a source whose reconciliation would fail or a target whose ownership record cannot be parsed
+	FindingError = "Error"
+)
+
+// ObjectReference a kind, namespace and name of an inspected object.
+type ObjectReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+func (o ObjectReference) String() string {
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// KeyReport a key reflected to a target and the sources claiming it.
+type KeyReport struct {
+	reflector.ReflectedKey
+
+	// the source whose value is set on the target, there is none when the source doesn't exist anymore
+	OwnerSource *ObjectReference `json:"ownerSource,omitempty"`
+	// sources of the claims in the order of the claims, there is none when the source doesn't exist anymore
+	ClaimSources []*ObjectReference `json:"claimSources,omitempty"`
+}
+
+// PendingChange changes the plan of a source makes to a target that is out of sync.
+type PendingChange struct {
+	Source ObjectReference             `json:"source"`
+	Target reflector.TargetExplanation `json:"target"`
+}
+
+// TargetReport keys reflected to a target, where they come from and changes that are still pending.
+type TargetReport struct {
+	ObjectReference
+
+	Keys    []KeyReport     `json:"keys"`
+	Pending []PendingChange `json:"pending,omitempty"`
+	Errors  []string        `json:"errors,omitempty"`
+}
+
+// Finding a problem found by an audit.
+type Finding struct {
+	Type   string          `json:"type"`
+	Object ObjectReference `json:"object"`
+	// the source the finding is about, if any
+	Source  *ObjectReference `json:"source,omitempty"`
+	Keys    []string         `json:"keys,omitempty"`
+	Message string           `json:"message"`
+}
+
+/*
+Inspector inspect reflection of objects fetched from a cluster or read from files.
+the objects are reconciled by the reflector controller against an offline client, so keys are matched,
+targets are resolved and conflicts are resolved the same way the controller does, without writing anything.
+*/
+type Inspector struct {
+	objects    []client.Object
+	controller reflector.Controller
+}
+
+// NewInspector inspect the objects with the configuration of the controller, sources of all kinds are inspected.
+func NewInspector(objects []client.Object, config *common.Config) *Inspector {
+	inspectedConfig := *config
+	inspectedConfig.SourceKinds = []string{
+		reflector.SourceKindDeployment, reflector.SourceKindConfigMap, reflector.SourceKindSecret,
+		reflector.SourceKindCronJob, reflector.SourceKindJob,
+	}
+
+	// the same object can be given more than once, e.g. in several files, the last one is kept
+	uniqueObjects := make(map[ObjectReference]client.Object)
+	for _, object := range objects {
+		uniqueObjects[referenceOf(object)] = object
+	}
+
+	objects = slices.SortedFunc(maps.Values(uniqueObjects), func(a client.Object, b client.Object) int {
+		return compareReferences(referenceOf(a), referenceOf(b))
+	})
+
+	kubeClient := clients.NewOfflineKubernetesClient(objects, map[string]client.IndexerFunc{
+		reflector.PodReferencedObjectsIndex: reflector.IndexPodReferencedObjects,
+	}, &inspectedConfig)
+
+	return &Inspector{
+		objects:    objects,
+		controller: reflector.NewController(kubeClient, logr.Discard(), &inspectedConfig, nil),
+	}
+}
+
+// Status explain the plan of a source, targets the plan changes are out of sync.
+func (i *Inspector) Status(
+	ctx context.Context, kind string, namespacedName types.NamespacedName,
+) (*reflector.Explanation, error) {
+	if _, findErr := i.find(kind, namespacedName); findErr != nil {
+		return nil, findErr
+	}
+
+	return i.controller.ExplainSource(ctx, kind, namespacedName)
+}
+
+// ExplainTarget get keys reflected to a target, the sources they come from and changes of sources still pending.
+func (i *Inspector) ExplainTarget(
+	ctx context.Context, kind string, namespacedName types.NamespacedName,
+) (*TargetReport, error) {
+	target, findErr := i.find(kind, namespacedName)
+	if findErr != nil {
+		return nil, findErr
+	}
+
+	reflectedKeys, recordErr := reflector.ReflectedKeysOf(target)
+	if recordErr != nil {
+		return nil, recordErr
+	}
+
+	report := &TargetReport{ObjectReference: referenceOf(target), Keys: []KeyReport{}}
+
+	sourcesByUID := i.sourcesByUID()
+
+	for _, reflectedKey := range reflectedKeys {
+		keyReport := KeyReport{ReflectedKey: reflectedKey, OwnerSource: sourcesByUID[reflectedKey.Owner]}
+
+		for _, claim := range reflectedKey.Claims {
+			keyReport.ClaimSources = append(keyReport.ClaimSources, sourcesByUID[claim.Source])
+		}
+
+		report.Keys = append(report.Keys, keyReport)
+	}
+
+	for _, source := range i.reflectingSources() {
+		explanation, explainErr := i.controller.Explain(ctx, source)
+		if explainErr != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", referenceOf(source), explainErr))
+
+			continue
+		}
+
+		for _, targetExplanation := range explanation.Targets {
+			if targetExplanation.Changed && referenceOfExplanation(targetExplanation) == report.ObjectReference {
+				report.Pending = append(report.Pending,
+					PendingChange{Source: referenceOf(source), Target: targetExplanation})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+/*
+Audit find targets that are out of sync with their sources, sources whose reconciliation would fail,
+legacy reflected-list annotations no source would ever migrate and keys claimed by sources that don't exist.
+*/
+func (i *Inspector) Audit(ctx context.Context) []Finding {
+	findings := []Finding{}
+	targeted := make(map[ObjectReference]bool)
+
+	for _, source := range i.reflectingSources() {
+		sourceReference := referenceOf(source)
+
+		explanation, explainErr := i.controller.Explain(ctx, source)
+		if explainErr != nil {
+			findings = append(findings, Finding{
+				Type: FindingError, Object: sourceReference, Message: explainErr.Error(),
+			})
+
+			continue
+		}
+
+		for _, explainedErr := range explanation.Errors {
+			findings = append(findings, Finding{Type: FindingError, Object: sourceReference, Message: explainedErr})
+		}
+
+		for _, targetExplanation := range explanation.Targets {
+			targetReference := referenceOfExplanation(targetExplanation)
+			targeted[targetReference] = true
+
+			if !targetExplanation.Changed {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Type:    FindingOutOfSync,
+				Object:  targetReference,
+				Source:  &sourceReference,
+				Keys:    changedKeys(targetExplanation),
+				Message: "the target doesn't have the metadata its source reflects",
+			})
+		}
+	}
+
+	sourcesByUID := i.sourcesByUID()
+
+	for _, object := range i.objects {
+		findings = append(findings, auditRecords(object, targeted[referenceOf(object)], sourcesByUID)...)
+	}
+
+	return findings
+}
+
+// find legacy reflected lists and claims of sources that don't exist anymore on the object.
+func auditRecords(object client.Object, targeted bool, sourcesByUID map[types.UID]*ObjectReference) []Finding {
+	var findings []Finding
+
+	reference := referenceOf(object)
+
+	reflectedKeys, recordErr := reflector.ReflectedKeysOf(object)
+	if recordErr != nil {
+		return []Finding{{Type: FindingError, Object: reference, Message: recordErr.Error()}}
+	}
+
+	var legacyKeys []string
+
+	orphanedClaims := make(map[types.UID][]string)
+
+	for _, reflectedKey := range reflectedKeys {
+		if reflectedKey.Legacy {
+			legacyKeys = append(legacyKeys, reflectedKey.Metadata+"/"+reflectedKey.Key)
+		}
+
+		for _, claim := range reflectedKey.Claims {
+			if sourcesByUID[claim.Source] == nil {
+				orphanedClaims[claim.Source] = append(orphanedClaims[claim.Source],
+					reflectedKey.Metadata+"/"+reflectedKey.Key)
+			}
+		}
+	}
+
+	if len(legacyKeys) > 0 && !targeted {
+		findings = append(findings, Finding{
+			Type:    FindingOrphanedReflectedList,
+			Object:  reference,
+			Keys:    legacyKeys,
+			Message: "no source targets the object, so its reflected-list annotation is never migrated nor cleaned up",
+		})
+	}
+
+	for _, sourceUID := range slices.Sorted(maps.Keys(orphanedClaims)) {
+		findings = append(findings, Finding{
+			Type:    FindingOrphanedClaim,
+			Object:  reference,
+			Keys:    orphanedClaims[sourceUID],
+			Message: fmt.Sprintf("keys are claimed by source %s that doesn't exist anymore", sourceUID),
+		})
+	}
+
+	return findings
+}
+
+// get sources with reflector annotations and sources claiming keys of any object, so their keys can be cleaned up.
+func (i *Inspector) reflectingSources() []client.Object {
+	claimedSources := make(map[types.UID]bool)
+
+	for _, object := range i.objects {
+		reflectedKeys, _ := reflector.ReflectedKeysOf(object)
+
+		for _, reflectedKey := range reflectedKeys {
+			for _, claim := range reflectedKey.Claims {
+				claimedSources[claim.Source] = true
+			}
+		}
+	}
+
+	var sources []client.Object
+
+	for _, object := range i.objects {
+		if !isSourceKind(referenceOf(object).Kind) {
+			continue
+		}
+
+		hasReflectorAnnotations := len(reflector.FindReflectorAnnotations(
+			reflector.ReflectorLabelsAnnotationDomain, object)) > 0 ||
+			len(reflector.FindReflectorAnnotations(reflector.ReflectorAnnotationsAnnotationDomain, object)) > 0
+
+		if hasReflectorAnnotations || claimedSources[object.GetUID()] {
+			sources = append(sources, sourceObject(object))
+		}
+	}
+
+	return sources
+}
+
+func (i *Inspector) sourcesByUID() map[types.UID]*ObjectReference {
+	sources := make(map[types.UID]*ObjectReference)
+
+	for _, object := range i.objects {
+		if reference := referenceOf(object); isSourceKind(reference.Kind) && object.GetUID() != "" {
+			sources[object.GetUID()] = &reference
+		}
+	}
+
+	return sources
+}
+
+func (i *Inspector) find(kind string, namespacedName types.NamespacedName) (client.Object, error) {
+	reference := ObjectReference{Kind: kind, Namespace: namespacedName.Namespace, Name: namespacedName.Name}
+
+	for _, object := range i.objects {
+		if referenceOf(object) == reference {
+			return object, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, reference)
+}
+
+// get the object the way the controller reads the source, config maps and secrets are read as metadata.
+func sourceObject(object client.Object) client.Object {
+	metadata := &metav1.PartialObjectMetadata{}
+
+	switch typedObject := object.(type) {
+	case *v1.ConfigMap:
+		metadata.ObjectMeta = typedObject.ObjectMeta
+	case *v1.Secret:
+		metadata.ObjectMeta = typedObject.ObjectMeta
+	default:
+		return object
+	}
+
+	metadata.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind(referenceOf(object).Kind))
+
+	return metadata
+}
+
+func isSourceKind(kind string) bool {
+	return slices.Contains([]string{
+		reflector.SourceKindDeployment, reflector.SourceKindConfigMap, reflector.SourceKindSecret,
+		reflector.SourceKindCronJob, reflector.SourceKindJob,
+	}, kind)
+}
+
+// get keys of labels and annotations the plan changes, bookkeeping annotations included.
+func changedKeys(targetExplanation reflector.TargetExplanation) []string {
+	var keys []string
+
+	for _, change := range targetExplanation.Labels {
+		keys = append(keys, "labels/"+change.Key)
+	}
+
+	for _, change := range targetExplanation.Annotations {
+		keys = append(keys, "annotations/"+change.Key)
+	}
+
+	return keys
+}
+
+func referenceOf(object client.Object) ObjectReference {
+	kind := object.GetObjectKind().GroupVersionKind().Kind
+	if gvk, gvkErr := apiutil.GVKForObject(object, clientgoscheme.Scheme); gvkErr == nil {
+		kind = gvk.Kind
+	}
+
+	return ObjectReference{Kind: kind, Namespace: object.GetNamespace(), Name: object.GetName()}
+}
+
+func referenceOfExplanation(targetExplanation reflector.TargetExplanation) ObjectReference {
+	return ObjectReference{
+		Kind: targetExplanation.Kind, Namespace: targetExplanation.Namespace, Name: targetExplanation.Name,
+	}
+}
+
+func compareReferences(a ObjectReference, b ObjectReference) int {
+	return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Name, b.Name))
+}
@@ -0,0 +1,136 @@
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	"github.com/NCCloud/metadata-reflector/internal/controllers/reflector"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// a deployment reflecting its team label to pods, app-2 is in sync, app-1 is not and orphan keeps stale records.
+func inspectedObjects() []client.Object {
+	syncedRecord := `{"team":{"source":"deployment-uid",` +
+		`"claims":[{"source":"deployment-uid","kind":"Deployment","value":"payments"}]}}`
+
+	return []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   "default",
+				UID:         "deployment-uid",
+				Labels:      map[string]string{"team": "payments"},
+				Annotations: map[string]string{fmt.Sprintf("%s/list", reflector.ReflectorLabelsAnnotationDomain): "team"},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "app"}},
+			},
+		},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: "app-1", Namespace: "default", Labels: map[string]string{"app": "app"},
+		}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        "app-2",
+			Namespace:   "default",
+			Labels:      map[string]string{"app": "app", "team": "payments"},
+			Annotations: map[string]string{reflector.ReflectorLabelsOwnershipAnnotation: syncedRecord},
+		}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      "orphan",
+			Namespace: "default",
+			Labels:    map[string]string{"tier": "web", "team": "checkout"},
+			Annotations: map[string]string{
+				reflector.ReflectorLabelsReflectedAnnotation: "tier",
+				reflector.ReflectorAnnotationsOwnershipAnnotation: `{"owner":{"source":"deleted-uid",` +
+					`"claims":[{"source":"deleted-uid","kind":"ConfigMap","value":"payments@example.com"}]}}`,
+				"owner": "payments@example.com",
+			},
+		}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}},
+	}
+}
+
+func TestInspector_Status(t *testing.T) {
+	inspector := NewInspector(inspectedObjects(), &common.Config{})
+
+	explanation, err := inspector.Status(context.Background(), reflector.SourceKindDeployment,
+		types.NamespacedName{Namespace: "default", Name: "app"})
+
+	assert.Nil(t, err)
+	assert.Len(t, explanation.Targets, 2)
+
+	changed := make(map[string]bool)
+	for _, target := range explanation.Targets {
+		changed[target.Name] = target.Changed
+	}
+
+	assert.Equal(t, map[string]bool{"app-1": true, "app-2": false}, changed)
+
+	_, notFoundErr := inspector.Status(context.Background(), reflector.SourceKindDeployment,
+		types.NamespacedName{Namespace: "default", Name: "missing"})
+
+	assert.ErrorIs(t, notFoundErr, ErrObjectNotFound)
+}
+
+func TestInspector_ExplainTarget(t *testing.T) {
+	inspector := NewInspector(inspectedObjects(), &common.Config{})
+	ctx := context.Background()
+
+	report, err := inspector.ExplainTarget(ctx, "Pod", types.NamespacedName{Namespace: "default", Name: "app-2"})
+
+	assert.Nil(t, err)
+	assert.Len(t, report.Keys, 1)
+	assert.Equal(t, "team", report.Keys[0].Key)
+	assert.Equal(t, &ObjectReference{Kind: reflector.SourceKindDeployment, Namespace: "default", Name: "app"},
+		report.Keys[0].OwnerSource)
+	assert.Empty(t, report.Pending)
+
+	pendingReport, pendingErr := inspector.ExplainTarget(ctx, "Pod",
+		types.NamespacedName{Namespace: "default", Name: "app-1"})
+
+	assert.Nil(t, pendingErr)
+	assert.Empty(t, pendingReport.Keys)
+	assert.Len(t, pendingReport.Pending, 1)
+	assert.Equal(t, "app", pendingReport.Pending[0].Source.Name)
+
+	orphanReport, orphanErr := inspector.ExplainTarget(ctx, "Pod",
+		types.NamespacedName{Namespace: "default", Name: "orphan"})
+
+	assert.Nil(t, orphanErr)
+	assert.Len(t, orphanReport.Keys, 2)
+	assert.Nil(t, orphanReport.Keys[1].OwnerSource)
+	assert.Equal(t, []*ObjectReference{nil}, orphanReport.Keys[1].ClaimSources)
+}
+
+func TestInspector_Audit(t *testing.T) {
+	inspector := NewInspector(inspectedObjects(), &common.Config{})
+
+	findings := inspector.Audit(context.Background())
+
+	var found []string
+	for _, finding := range findings {
+		found = append(found, fmt.Sprintf("%s %s %v", finding.Type, finding.Object.Name, finding.Keys))
+	}
+
+	assert.Equal(t, []string{
+		fmt.Sprintf("%s app-1 [labels/team annotations/%s]", FindingOutOfSync,
+			reflector.ReflectorLabelsOwnershipAnnotation),
+		FindingOrphanedReflectedList + " orphan [labels/tier]",
+		FindingOrphanedClaim + " orphan [annotations/owner]",
+	}, found)
+}
+
+func TestNewInspector_duplicateObjects(t *testing.T) {
+	objects := append(inspectedObjects(), inspectedObjects()...)
+
+	inspector := NewInspector(objects, &common.Config{})
+
+	assert.Len(t, inspector.objects, len(inspectedObjects()))
+}
@@ -0,0 +1,195 @@
+package inspect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+ReadFiles read objects relevant to reflection from YAML or JSON files, e.g. the output of `kubectl get -o yaml`.
+the path - reads the standard input. lists are flattened, other kinds are skipped
+and data of config maps and secrets is dropped.
+*/
+func ReadFiles(paths []string) ([]client.Object, error) {
+	var objects []client.Object
+
+	for _, path := range paths {
+		content, readErr := readFile(path)
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		fileObjects, decodeErr := decodeObjects(content)
+		if decodeErr != nil {
+			return nil, fmt.Errorf("%s: %w", path, decodeErr)
+		}
+
+		objects = append(objects, fileObjects...)
+	}
+
+	return objects, nil
+}
+
+func readFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(path)
+}
+
+// decode documents of a YAML stream or a JSON object.
+func decodeObjects(content []byte) ([]client.Object, error) {
+	var objects []client.Object
+
+	reader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+
+	for {
+		document, readErr := reader.Read()
+		if errors.Is(readErr, io.EOF) {
+			return objects, nil
+		}
+
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+
+		documentObjects, decodeErr := decodeObject(document)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+
+		objects = append(objects, documentObjects...)
+	}
+}
+
+func decodeObject(document []byte) ([]client.Object, error) {
+	decoded, _, decodeErr := clientgoscheme.Codecs.UniversalDeserializer().Decode(document, nil, nil)
+
+	switch {
+	case runtime.IsNotRegisteredError(decodeErr):
+		return nil, nil
+	case decodeErr != nil:
+		return nil, decodeErr
+	}
+
+	list, isList := decoded.(*v1.List)
+	if !isList {
+		if object := relevantObject(decoded); object != nil {
+			return []client.Object{object}, nil
+		}
+
+		return nil, nil
+	}
+
+	var objects []client.Object
+
+	for _, item := range list.Items {
+		itemObjects, itemErr := decodeObject(item.Raw)
+		if itemErr != nil {
+			return nil, itemErr
+		}
+
+		objects = append(objects, itemObjects...)
+	}
+
+	return objects, nil
+}
+
+// get the object when it's a source, a target or a controller of pods, data of config maps and secrets is dropped.
+func relevantObject(object runtime.Object) client.Object {
+	switch typedObject := object.(type) {
+	case *appsv1.Deployment, *appsv1.ReplicaSet, *appsv1.StatefulSet, *appsv1.DaemonSet,
+		*batchv1.CronJob, *batchv1.Job, *v1.Pod, *v1.Service, *discoveryv1.EndpointSlice:
+		clientObject, _ := typedObject.(client.Object)
+
+		return clientObject
+	case *v1.ConfigMap:
+		return &v1.ConfigMap{ObjectMeta: typedObject.ObjectMeta}
+	case *v1.Secret:
+		return &v1.Secret{ObjectMeta: typedObject.ObjectMeta}
+	default:
+		return nil
+	}
+}
+
+/*
+FetchObjects fetch objects relevant to reflection from the cluster, an empty namespace means all namespaces.
+only metadata of config maps and secrets is fetched, their data never leaves the cluster.
+*/
+func FetchObjects(ctx context.Context, reader client.Reader, namespace string) ([]client.Object, error) {
+	var objects []client.Object
+
+	typedLists := []client.ObjectList{
+		&appsv1.DeploymentList{}, &appsv1.ReplicaSetList{}, &appsv1.StatefulSetList{}, &appsv1.DaemonSetList{},
+		&batchv1.CronJobList{}, &batchv1.JobList{}, &v1.PodList{}, &v1.ServiceList{},
+		&discoveryv1.EndpointSliceList{},
+	}
+
+	for _, list := range typedLists {
+		if listErr := reader.List(ctx, list, client.InNamespace(namespace)); listErr != nil {
+			return nil, listErr
+		}
+
+		items, itemsErr := metaItems(list)
+		if itemsErr != nil {
+			return nil, itemsErr
+		}
+
+		objects = append(objects, items...)
+	}
+
+	for _, kind := range []string{"ConfigMap", "Secret"} {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind(kind + "List"))
+
+		if listErr := reader.List(ctx, list, client.InNamespace(namespace)); listErr != nil {
+			return nil, listErr
+		}
+
+		for _, item := range list.Items {
+			if kind == "ConfigMap" {
+				objects = append(objects, &v1.ConfigMap{ObjectMeta: item.ObjectMeta})
+			} else {
+				objects = append(objects, &v1.Secret{ObjectMeta: item.ObjectMeta})
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// get items of a typed list as objects.
+func metaItems(list client.ObjectList) ([]client.Object, error) {
+	var objects []client.Object
+
+	extractErr := meta.EachListItem(list, func(object runtime.Object) error {
+		if clientObject, ok := object.(client.Object); ok {
+			objects = append(objects, clientObject)
+		}
+
+		return nil
+	})
+
+	return objects, extractErr
+}
@@ -0,0 +1,147 @@
+package inspect
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReadFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name: "YAML documents and lists",
+			content: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: app-1
+    namespace: default
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: app-config
+    namespace: default
+  data:
+    password: secret
+`,
+			wantNames: []string{"app", "app-1", "app-config"},
+			wantErr:   false,
+		},
+		{
+			name: "Irrelevant and unknown kinds are skipped",
+			content: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: default
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+`,
+			wantNames: nil,
+			wantErr:   false,
+		},
+		{
+			name:      "JSON object",
+			content:   `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"app-1","namespace":"default"}}`,
+			wantNames: []string{"app-1"},
+			wantErr:   false,
+		},
+		{
+			name:      "Invalid document",
+			content:   "apiVersion: v1\nkind: Pod\nmetadata: [",
+			wantNames: nil,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "objects.yaml")
+			assert.Nil(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			objects, err := ReadFiles([]string{path})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+
+			var names []string
+
+			for _, object := range objects {
+				names = append(names, object.GetName())
+
+				if configMap, ok := object.(*v1.ConfigMap); ok {
+					assert.Empty(t, configMap.Data)
+				}
+			}
+
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+func TestReadFiles_missingFile(t *testing.T) {
+	_, err := ReadFiles([]string{filepath.Join(t.TempDir(), "missing.yaml")})
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFetchObjects(t *testing.T) {
+	reader := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"}},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-secret", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		},
+	).Build()
+
+	objects, err := FetchObjects(context.Background(), reader, "default")
+
+	assert.Nil(t, err)
+	assert.Len(t, objects, 2)
+
+	var secret *v1.Secret
+
+	for _, object := range objects {
+		assert.Equal(t, "default", object.GetNamespace())
+
+		if fetchedSecret, ok := object.(*v1.Secret); ok {
+			secret = fetchedSecret
+		}
+	}
+
+	if assert.NotNil(t, secret) {
+		assert.Equal(t, "app-secret", secret.Name)
+		assert.Empty(t, secret.Data)
+	}
+
+	allObjects, allErr := FetchObjects(context.Background(), reader, "")
+
+	assert.Nil(t, allErr)
+	assert.Len(t, allObjects, 3)
+}
@@ -0,0 +1,171 @@
+package inspect
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/NCCloud/metadata-reflector/internal/controllers/reflector"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SourceReport reflector annotations of a source, the metadata its targets end up with and why it's invalid.
+type SourceReport struct {
+	ObjectReference
+
+	Operations []reflector.OperationExplanation `json:"operations"`
+	Targets    []RenderedTarget                 `json:"targets"`
+	// errors of reflector annotations and errors the reconciliation of the source would fail with
+	Errors []string `json:"errors,omitempty"`
+}
+
+// RenderedTarget metadata of a target after its source is reconciled.
+type RenderedTarget struct {
+	ObjectReference
+
+	// the target selector of the source excludes the target, reflected metadata is only removed from it
+	Excluded    bool              `json:"excluded,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// Valid check that the source has valid reflector annotations and that its reconciliation wouldn't fail.
+func (s SourceReport) Valid() bool {
+	return len(s.Errors) == 0
+}
+
+/*
+Render reconcile sources with reflector annotations in memory and get the metadata of their targets.
+invalid reflector annotations and errors the reconciliation would fail with are reported for each source.
+*/
+func (i *Inspector) Render(ctx context.Context) []SourceReport {
+	reports := []SourceReport{}
+
+	for _, source := range i.reflectingSources() {
+		report := SourceReport{
+			ObjectReference: referenceOf(source),
+			Operations:      reflector.MatchOperations(source),
+			Targets:         []RenderedTarget{},
+		}
+
+		var operationErrors []string
+
+		for _, operation := range report.Operations {
+			if operation.Error != "" {
+				operationErrors = append(operationErrors, operation.Error)
+				report.Errors = append(report.Errors, operation.Annotation+": "+operation.Error)
+			}
+		}
+
+		explanation, explainErr := i.controller.Explain(ctx, source)
+		if explainErr != nil {
+			report.Errors = append(report.Errors, explainErr.Error())
+			reports = append(reports, report)
+
+			continue
+		}
+
+		// errors of reflector annotations fail the reconciliation too, they are reported once
+		for _, explainedErr := range explanation.Errors {
+			if !slices.Contains(operationErrors, explainedErr) {
+				report.Errors = append(report.Errors, explainedErr)
+			}
+		}
+
+		for _, targetExplanation := range explanation.Targets {
+			report.Targets = append(report.Targets, i.renderTarget(targetExplanation))
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+func (i *Inspector) renderTarget(targetExplanation reflector.TargetExplanation) RenderedTarget {
+	reference := referenceOfExplanation(targetExplanation)
+	rendered := RenderedTarget{
+		ObjectReference: reference,
+		Excluded:        targetExplanation.Excluded,
+		Labels:          map[string]string{},
+		Annotations:     map[string]string{},
+	}
+
+	if target, findErr := i.find(reference.Kind,
+		types.NamespacedName{Namespace: reference.Namespace, Name: reference.Name}); findErr == nil {
+		maps.Copy(rendered.Labels, target.GetLabels())
+		maps.Copy(rendered.Annotations, target.GetAnnotations())
+	}
+
+	applyChanges(rendered.Labels, targetExplanation.Labels)
+	applyChanges(rendered.Annotations, targetExplanation.Annotations)
+
+	return rendered
+}
+
+func applyChanges(metadata map[string]string, changes []reflector.KeyChange) {
+	for _, change := range changes {
+		if change.To == nil {
+			delete(metadata, change.Key)
+		} else {
+			metadata[change.Key] = *change.To
+		}
+	}
+}
+
+/*
+WithTemplatePods add a pod created from the template of every deployment without pods among the objects,
+so the metadata of pods can be rendered from deployment manifests alone.
+*/
+func WithTemplatePods(objects []client.Object) []client.Object {
+	var pods []*v1.Pod
+
+	for _, object := range objects {
+		if pod, ok := object.(*v1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	}
+
+	templatePods := []client.Object{}
+
+	for _, object := range objects {
+		deployment, ok := object.(*appsv1.Deployment)
+		if !ok || hasManagedPods(deployment, pods) {
+			continue
+		}
+
+		template := deployment.Spec.Template
+		templatePods = append(templatePods, &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        deployment.Name + "-template",
+				Namespace:   deployment.Namespace,
+				Labels:      maps.Clone(template.Labels),
+				Annotations: maps.Clone(template.Annotations),
+			},
+			Spec: template.Spec,
+		})
+	}
+
+	return slices.Concat(objects, templatePods)
+}
+
+func hasManagedPods(deployment *appsv1.Deployment, pods []*v1.Pod) bool {
+	selector, selectorErr := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if selectorErr != nil {
+		return false
+	}
+
+	for _, pod := range pods {
+		if pod.Namespace == deployment.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+			return true
+		}
+	}
+
+	return false
+}
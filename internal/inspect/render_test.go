@@ -0,0 +1,102 @@
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/NCCloud/metadata-reflector/internal/common"
+	"github.com/NCCloud/metadata-reflector/internal/controllers/reflector"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func templateDeployment(name string, annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Labels:      map[string]string{"team": "payments"},
+			Annotations: annotations,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+			},
+		},
+	}
+}
+
+func TestInspector_Render(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantLabels  map[string]string
+		wantErrors  int
+	}{
+		{
+			name: "Valid annotations",
+			annotations: map[string]string{
+				fmt.Sprintf("%s/list", reflector.ReflectorLabelsAnnotationDomain): "team",
+			},
+			wantLabels: map[string]string{"app": "app", "team": "payments"},
+			wantErrors: 0,
+		},
+		{
+			name: "Invalid regex",
+			annotations: map[string]string{
+				fmt.Sprintf("%s/regex", reflector.ReflectorLabelsAnnotationDomain): "team[",
+			},
+			wantLabels: map[string]string{"app": "app"},
+			wantErrors: 1,
+		},
+		{
+			name: "Unsupported operation",
+			annotations: map[string]string{
+				fmt.Sprintf("%s/list", reflector.ReflectorLabelsAnnotationDomain):    "team",
+				fmt.Sprintf("%s/exclude", reflector.ReflectorLabelsAnnotationDomain): "team",
+			},
+			wantLabels: map[string]string{"app": "app"},
+			wantErrors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := WithTemplatePods([]client.Object{templateDeployment("app", tt.annotations)})
+
+			reports := NewInspector(objects, &common.Config{}).Render(context.Background())
+
+			assert.Len(t, reports, 1)
+			assert.Len(t, reports[0].Errors, tt.wantErrors)
+			assert.Equal(t, tt.wantErrors == 0, reports[0].Valid())
+			assert.Len(t, reports[0].Targets, 1)
+
+			target := reports[0].Targets[0]
+			assert.Equal(t, "app-template", target.Name)
+			assert.Equal(t, tt.wantLabels, target.Labels)
+		})
+	}
+}
+
+func TestWithTemplatePods(t *testing.T) {
+	withPods := templateDeployment("with-pods", nil)
+	withoutPods := templateDeployment("without-pods", nil)
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "with-pods-1", Namespace: "default", Labels: map[string]string{"app": "with-pods"},
+	}}
+
+	objects := WithTemplatePods([]client.Object{withPods, withoutPods, pod})
+
+	assert.Len(t, objects, 4)
+
+	templatePod, ok := objects[3].(*v1.Pod)
+	if assert.True(t, ok) {
+		assert.Equal(t, "without-pods-template", templatePod.Name)
+		assert.Equal(t, "default", templatePod.Namespace)
+		assert.Equal(t, map[string]string{"app": "without-pods"}, templatePod.Labels)
+	}
+}
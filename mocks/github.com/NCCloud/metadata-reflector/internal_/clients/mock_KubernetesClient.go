@@ -9,8 +9,12 @@ import (
 
 	mock "github.com/stretchr/testify/mock"
 	"k8s.io/api/apps/v1"
+	v13 "k8s.io/api/batch/v1"
 	v10 "k8s.io/api/core/v1"
+	v11 "k8s.io/api/discovery/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -41,6 +45,148 @@ func (_m *MockKubernetesClient) EXPECT() *MockKubernetesClient_Expecter {
 	return &MockKubernetesClient_Expecter{mock: &_m.Mock}
 }
 
+// GetControllerSelector provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) GetControllerSelector(ctx context.Context, namespace string, controller v12.OwnerReference) (*v12.LabelSelector, error) {
+	ret := _mock.Called(ctx, namespace, controller)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetControllerSelector")
+	}
+
+	var r0 *v12.LabelSelector
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, v12.OwnerReference) (*v12.LabelSelector, error)); ok {
+		return returnFunc(ctx, namespace, controller)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, v12.OwnerReference) *v12.LabelSelector); ok {
+		r0 = returnFunc(ctx, namespace, controller)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v12.LabelSelector)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, v12.OwnerReference) error); ok {
+		r1 = returnFunc(ctx, namespace, controller)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockKubernetesClient_GetControllerSelector_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetControllerSelector'
+type MockKubernetesClient_GetControllerSelector_Call struct {
+	*mock.Call
+}
+
+// GetControllerSelector is a helper method to define mock.On call
+//   - ctx context.Context
+//   - namespace string
+//   - controller v12.OwnerReference
+func (_e *MockKubernetesClient_Expecter) GetControllerSelector(ctx interface{}, namespace interface{}, controller interface{}) *MockKubernetesClient_GetControllerSelector_Call {
+	return &MockKubernetesClient_GetControllerSelector_Call{Call: _e.mock.On("GetControllerSelector", ctx, namespace, controller)}
+}
+
+func (_c *MockKubernetesClient_GetControllerSelector_Call) Run(run func(ctx context.Context, namespace string, controller v12.OwnerReference)) *MockKubernetesClient_GetControllerSelector_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 v12.OwnerReference
+		if args[2] != nil {
+			arg2 = args[2].(v12.OwnerReference)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_GetControllerSelector_Call) Return(labelSelector *v12.LabelSelector, err error) *MockKubernetesClient_GetControllerSelector_Call {
+	_c.Call.Return(labelSelector, err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_GetControllerSelector_Call) RunAndReturn(run func(ctx context.Context, namespace string, controller v12.OwnerReference) (*v12.LabelSelector, error)) *MockKubernetesClient_GetControllerSelector_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetCronJob provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) GetCronJob(ctx context.Context, namespacedName types.NamespacedName) (*v13.CronJob, error) {
+	ret := _mock.Called(ctx, namespacedName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCronJob")
+	}
+
+	var r0 *v13.CronJob
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, types.NamespacedName) (*v13.CronJob, error)); ok {
+		return returnFunc(ctx, namespacedName)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, types.NamespacedName) *v13.CronJob); ok {
+		r0 = returnFunc(ctx, namespacedName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v13.CronJob)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, types.NamespacedName) error); ok {
+		r1 = returnFunc(ctx, namespacedName)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockKubernetesClient_GetCronJob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetCronJob'
+type MockKubernetesClient_GetCronJob_Call struct {
+	*mock.Call
+}
+
+// GetCronJob is a helper method to define mock.On call
+//   - ctx context.Context
+//   - namespacedName types.NamespacedName
+func (_e *MockKubernetesClient_Expecter) GetCronJob(ctx interface{}, namespacedName interface{}) *MockKubernetesClient_GetCronJob_Call {
+	return &MockKubernetesClient_GetCronJob_Call{Call: _e.mock.On("GetCronJob", ctx, namespacedName)}
+}
+
+func (_c *MockKubernetesClient_GetCronJob_Call) Run(run func(ctx context.Context, namespacedName types.NamespacedName)) *MockKubernetesClient_GetCronJob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 types.NamespacedName
+		if args[1] != nil {
+			arg1 = args[1].(types.NamespacedName)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_GetCronJob_Call) Return(cronJob *v13.CronJob, err error) *MockKubernetesClient_GetCronJob_Call {
+	_c.Call.Return(cronJob, err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_GetCronJob_Call) RunAndReturn(run func(ctx context.Context, namespacedName types.NamespacedName) (*v13.CronJob, error)) *MockKubernetesClient_GetCronJob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetDeployment provides a mock function for the type MockKubernetesClient
 func (_mock *MockKubernetesClient) GetDeployment(ctx context.Context, namespacedName types.NamespacedName) (*v1.Deployment, error) {
 	ret := _mock.Called(ctx, namespacedName)
@@ -109,6 +255,148 @@ func (_c *MockKubernetesClient_GetDeployment_Call) RunAndReturn(run func(ctx con
 	return _c
 }
 
+// GetJob provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) GetJob(ctx context.Context, namespacedName types.NamespacedName) (*v13.Job, error) {
+	ret := _mock.Called(ctx, namespacedName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetJob")
+	}
+
+	var r0 *v13.Job
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, types.NamespacedName) (*v13.Job, error)); ok {
+		return returnFunc(ctx, namespacedName)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, types.NamespacedName) *v13.Job); ok {
+		r0 = returnFunc(ctx, namespacedName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v13.Job)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, types.NamespacedName) error); ok {
+		r1 = returnFunc(ctx, namespacedName)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockKubernetesClient_GetJob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetJob'
+type MockKubernetesClient_GetJob_Call struct {
+	*mock.Call
+}
+
+// GetJob is a helper method to define mock.On call
+//   - ctx context.Context
+//   - namespacedName types.NamespacedName
+func (_e *MockKubernetesClient_Expecter) GetJob(ctx interface{}, namespacedName interface{}) *MockKubernetesClient_GetJob_Call {
+	return &MockKubernetesClient_GetJob_Call{Call: _e.mock.On("GetJob", ctx, namespacedName)}
+}
+
+func (_c *MockKubernetesClient_GetJob_Call) Run(run func(ctx context.Context, namespacedName types.NamespacedName)) *MockKubernetesClient_GetJob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 types.NamespacedName
+		if args[1] != nil {
+			arg1 = args[1].(types.NamespacedName)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_GetJob_Call) Return(job *v13.Job, err error) *MockKubernetesClient_GetJob_Call {
+	_c.Call.Return(job, err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_GetJob_Call) RunAndReturn(run func(ctx context.Context, namespacedName types.NamespacedName) (*v13.Job, error)) *MockKubernetesClient_GetJob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetMetadata provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) GetMetadata(ctx context.Context, gvk schema.GroupVersionKind, namespacedName types.NamespacedName) (*v12.PartialObjectMetadata, error) {
+	ret := _mock.Called(ctx, gvk, namespacedName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMetadata")
+	}
+
+	var r0 *v12.PartialObjectMetadata
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, schema.GroupVersionKind, types.NamespacedName) (*v12.PartialObjectMetadata, error)); ok {
+		return returnFunc(ctx, gvk, namespacedName)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, schema.GroupVersionKind, types.NamespacedName) *v12.PartialObjectMetadata); ok {
+		r0 = returnFunc(ctx, gvk, namespacedName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v12.PartialObjectMetadata)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, schema.GroupVersionKind, types.NamespacedName) error); ok {
+		r1 = returnFunc(ctx, gvk, namespacedName)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockKubernetesClient_GetMetadata_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetMetadata'
+type MockKubernetesClient_GetMetadata_Call struct {
+	*mock.Call
+}
+
+// GetMetadata is a helper method to define mock.On call
+//   - ctx context.Context
+//   - gvk schema.GroupVersionKind
+//   - namespacedName types.NamespacedName
+func (_e *MockKubernetesClient_Expecter) GetMetadata(ctx interface{}, gvk interface{}, namespacedName interface{}) *MockKubernetesClient_GetMetadata_Call {
+	return &MockKubernetesClient_GetMetadata_Call{Call: _e.mock.On("GetMetadata", ctx, gvk, namespacedName)}
+}
+
+func (_c *MockKubernetesClient_GetMetadata_Call) Run(run func(ctx context.Context, gvk schema.GroupVersionKind, namespacedName types.NamespacedName)) *MockKubernetesClient_GetMetadata_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 schema.GroupVersionKind
+		if args[1] != nil {
+			arg1 = args[1].(schema.GroupVersionKind)
+		}
+		var arg2 types.NamespacedName
+		if args[2] != nil {
+			arg2 = args[2].(types.NamespacedName)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_GetMetadata_Call) Return(partialObjectMetadata *v12.PartialObjectMetadata, err error) *MockKubernetesClient_GetMetadata_Call {
+	_c.Call.Return(partialObjectMetadata, err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_GetMetadata_Call) RunAndReturn(run func(ctx context.Context, gvk schema.GroupVersionKind, namespacedName types.NamespacedName) (*v12.PartialObjectMetadata, error)) *MockKubernetesClient_GetMetadata_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ListDeployments provides a mock function for the type MockKubernetesClient
 func (_mock *MockKubernetesClient) ListDeployments(ctx context.Context, labelSelector labels.Selector) (*v1.DeploymentList, error) {
 	ret := _mock.Called(ctx, labelSelector)
@@ -177,9 +465,151 @@ func (_c *MockKubernetesClient_ListDeployments_Call) RunAndReturn(run func(ctx c
 	return _c
 }
 
+// ListEndpointSlices provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) ListEndpointSlices(ctx context.Context, namespace string, labelSelector labels.Selector) (*v11.EndpointSliceList, error) {
+	ret := _mock.Called(ctx, namespace, labelSelector)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListEndpointSlices")
+	}
+
+	var r0 *v11.EndpointSliceList
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, labels.Selector) (*v11.EndpointSliceList, error)); ok {
+		return returnFunc(ctx, namespace, labelSelector)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, labels.Selector) *v11.EndpointSliceList); ok {
+		r0 = returnFunc(ctx, namespace, labelSelector)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v11.EndpointSliceList)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, labels.Selector) error); ok {
+		r1 = returnFunc(ctx, namespace, labelSelector)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockKubernetesClient_ListEndpointSlices_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListEndpointSlices'
+type MockKubernetesClient_ListEndpointSlices_Call struct {
+	*mock.Call
+}
+
+// ListEndpointSlices is a helper method to define mock.On call
+//   - ctx context.Context
+//   - namespace string
+//   - labelSelector labels.Selector
+func (_e *MockKubernetesClient_Expecter) ListEndpointSlices(ctx interface{}, namespace interface{}, labelSelector interface{}) *MockKubernetesClient_ListEndpointSlices_Call {
+	return &MockKubernetesClient_ListEndpointSlices_Call{Call: _e.mock.On("ListEndpointSlices", ctx, namespace, labelSelector)}
+}
+
+func (_c *MockKubernetesClient_ListEndpointSlices_Call) Run(run func(ctx context.Context, namespace string, labelSelector labels.Selector)) *MockKubernetesClient_ListEndpointSlices_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 labels.Selector
+		if args[2] != nil {
+			arg2 = args[2].(labels.Selector)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_ListEndpointSlices_Call) Return(endpointSliceList *v11.EndpointSliceList, err error) *MockKubernetesClient_ListEndpointSlices_Call {
+	_c.Call.Return(endpointSliceList, err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_ListEndpointSlices_Call) RunAndReturn(run func(ctx context.Context, namespace string, labelSelector labels.Selector) (*v11.EndpointSliceList, error)) *MockKubernetesClient_ListEndpointSlices_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListJobs provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) ListJobs(ctx context.Context, namespace string) (*v13.JobList, error) {
+	ret := _mock.Called(ctx, namespace)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListJobs")
+	}
+
+	var r0 *v13.JobList
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*v13.JobList, error)); ok {
+		return returnFunc(ctx, namespace)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *v13.JobList); ok {
+		r0 = returnFunc(ctx, namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v13.JobList)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockKubernetesClient_ListJobs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListJobs'
+type MockKubernetesClient_ListJobs_Call struct {
+	*mock.Call
+}
+
+// ListJobs is a helper method to define mock.On call
+//   - ctx context.Context
+//   - namespace string
+func (_e *MockKubernetesClient_Expecter) ListJobs(ctx interface{}, namespace interface{}) *MockKubernetesClient_ListJobs_Call {
+	return &MockKubernetesClient_ListJobs_Call{Call: _e.mock.On("ListJobs", ctx, namespace)}
+}
+
+func (_c *MockKubernetesClient_ListJobs_Call) Run(run func(ctx context.Context, namespace string)) *MockKubernetesClient_ListJobs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_ListJobs_Call) Return(jobList *v13.JobList, err error) *MockKubernetesClient_ListJobs_Call {
+	_c.Call.Return(jobList, err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_ListJobs_Call) RunAndReturn(run func(ctx context.Context, namespace string) (*v13.JobList, error)) *MockKubernetesClient_ListJobs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ListPods provides a mock function for the type MockKubernetesClient
-func (_mock *MockKubernetesClient) ListPods(ctx context.Context, labelSelector labels.Selector) (*v10.PodList, error) {
-	ret := _mock.Called(ctx, labelSelector)
+func (_mock *MockKubernetesClient) ListPods(ctx context.Context, namespace string, labelSelector labels.Selector) (*v10.PodList, error) {
+	ret := _mock.Called(ctx, namespace, labelSelector)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListPods")
@@ -187,18 +617,18 @@ func (_mock *MockKubernetesClient) ListPods(ctx context.Context, labelSelector l
 
 	var r0 *v10.PodList
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, labels.Selector) (*v10.PodList, error)); ok {
-		return returnFunc(ctx, labelSelector)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, labels.Selector) (*v10.PodList, error)); ok {
+		return returnFunc(ctx, namespace, labelSelector)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, labels.Selector) *v10.PodList); ok {
-		r0 = returnFunc(ctx, labelSelector)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, labels.Selector) *v10.PodList); ok {
+		r0 = returnFunc(ctx, namespace, labelSelector)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*v10.PodList)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, labels.Selector) error); ok {
-		r1 = returnFunc(ctx, labelSelector)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, labels.Selector) error); ok {
+		r1 = returnFunc(ctx, namespace, labelSelector)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -212,24 +642,30 @@ type MockKubernetesClient_ListPods_Call struct {
 
 // ListPods is a helper method to define mock.On call
 //   - ctx context.Context
+//   - namespace string
 //   - labelSelector labels.Selector
-func (_e *MockKubernetesClient_Expecter) ListPods(ctx interface{}, labelSelector interface{}) *MockKubernetesClient_ListPods_Call {
-	return &MockKubernetesClient_ListPods_Call{Call: _e.mock.On("ListPods", ctx, labelSelector)}
+func (_e *MockKubernetesClient_Expecter) ListPods(ctx interface{}, namespace interface{}, labelSelector interface{}) *MockKubernetesClient_ListPods_Call {
+	return &MockKubernetesClient_ListPods_Call{Call: _e.mock.On("ListPods", ctx, namespace, labelSelector)}
 }
 
-func (_c *MockKubernetesClient_ListPods_Call) Run(run func(ctx context.Context, labelSelector labels.Selector)) *MockKubernetesClient_ListPods_Call {
+func (_c *MockKubernetesClient_ListPods_Call) Run(run func(ctx context.Context, namespace string, labelSelector labels.Selector)) *MockKubernetesClient_ListPods_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 labels.Selector
+		var arg1 string
 		if args[1] != nil {
-			arg1 = args[1].(labels.Selector)
+			arg1 = args[1].(string)
+		}
+		var arg2 labels.Selector
+		if args[2] != nil {
+			arg2 = args[2].(labels.Selector)
 		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
@@ -240,41 +676,247 @@ func (_c *MockKubernetesClient_ListPods_Call) Return(podList *v10.PodList, err e
 	return _c
 }
 
-func (_c *MockKubernetesClient_ListPods_Call) RunAndReturn(run func(ctx context.Context, labelSelector labels.Selector) (*v10.PodList, error)) *MockKubernetesClient_ListPods_Call {
+func (_c *MockKubernetesClient_ListPods_Call) RunAndReturn(run func(ctx context.Context, namespace string, labelSelector labels.Selector) (*v10.PodList, error)) *MockKubernetesClient_ListPods_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// UpdatePod provides a mock function for the type MockKubernetesClient
-func (_mock *MockKubernetesClient) UpdatePod(ctx context.Context, pod v10.Pod) error {
-	ret := _mock.Called(ctx, pod)
+// ListPodsByIndex provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) ListPodsByIndex(ctx context.Context, namespace string, index string, value string) (*v10.PodList, error) {
+	ret := _mock.Called(ctx, namespace, index, value)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdatePod")
+		panic("no return value specified for ListPodsByIndex")
+	}
+
+	var r0 *v10.PodList
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, string) (*v10.PodList, error)); ok {
+		return returnFunc(ctx, namespace, index, value)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, string) *v10.PodList); ok {
+		r0 = returnFunc(ctx, namespace, index, value)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v10.PodList)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = returnFunc(ctx, namespace, index, value)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockKubernetesClient_ListPodsByIndex_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListPodsByIndex'
+type MockKubernetesClient_ListPodsByIndex_Call struct {
+	*mock.Call
+}
+
+// ListPodsByIndex is a helper method to define mock.On call
+//   - ctx context.Context
+//   - namespace string
+//   - index string
+//   - value string
+func (_e *MockKubernetesClient_Expecter) ListPodsByIndex(ctx interface{}, namespace interface{}, index interface{}, value interface{}) *MockKubernetesClient_ListPodsByIndex_Call {
+	return &MockKubernetesClient_ListPodsByIndex_Call{Call: _e.mock.On("ListPodsByIndex", ctx, namespace, index, value)}
+}
+
+func (_c *MockKubernetesClient_ListPodsByIndex_Call) Run(run func(ctx context.Context, namespace string, index string, value string)) *MockKubernetesClient_ListPodsByIndex_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 string
+		if args[3] != nil {
+			arg3 = args[3].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_ListPodsByIndex_Call) Return(podList *v10.PodList, err error) *MockKubernetesClient_ListPodsByIndex_Call {
+	_c.Call.Return(podList, err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_ListPodsByIndex_Call) RunAndReturn(run func(ctx context.Context, namespace string, index string, value string) (*v10.PodList, error)) *MockKubernetesClient_ListPodsByIndex_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListServices provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) ListServices(ctx context.Context, namespace string) (*v10.ServiceList, error) {
+	ret := _mock.Called(ctx, namespace)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListServices")
+	}
+
+	var r0 *v10.ServiceList
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*v10.ServiceList, error)); ok {
+		return returnFunc(ctx, namespace)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *v10.ServiceList); ok {
+		r0 = returnFunc(ctx, namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v10.ServiceList)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockKubernetesClient_ListServices_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListServices'
+type MockKubernetesClient_ListServices_Call struct {
+	*mock.Call
+}
+
+// ListServices is a helper method to define mock.On call
+//   - ctx context.Context
+//   - namespace string
+func (_e *MockKubernetesClient_Expecter) ListServices(ctx interface{}, namespace interface{}) *MockKubernetesClient_ListServices_Call {
+	return &MockKubernetesClient_ListServices_Call{Call: _e.mock.On("ListServices", ctx, namespace)}
+}
+
+func (_c *MockKubernetesClient_ListServices_Call) Run(run func(ctx context.Context, namespace string)) *MockKubernetesClient_ListServices_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_ListServices_Call) Return(serviceList *v10.ServiceList, err error) *MockKubernetesClient_ListServices_Call {
+	_c.Call.Return(serviceList, err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_ListServices_Call) RunAndReturn(run func(ctx context.Context, namespace string) (*v10.ServiceList, error)) *MockKubernetesClient_ListServices_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PatchMetadata provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) PatchMetadata(ctx context.Context, object *v12.PartialObjectMetadata) error {
+	ret := _mock.Called(ctx, object)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PatchMetadata")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, v10.Pod) error); ok {
-		r0 = returnFunc(ctx, pod)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *v12.PartialObjectMetadata) error); ok {
+		r0 = returnFunc(ctx, object)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// MockKubernetesClient_UpdatePod_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdatePod'
-type MockKubernetesClient_UpdatePod_Call struct {
+// MockKubernetesClient_PatchMetadata_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PatchMetadata'
+type MockKubernetesClient_PatchMetadata_Call struct {
 	*mock.Call
 }
 
-// UpdatePod is a helper method to define mock.On call
+// PatchMetadata is a helper method to define mock.On call
+//   - ctx context.Context
+//   - object *v12.PartialObjectMetadata
+func (_e *MockKubernetesClient_Expecter) PatchMetadata(ctx interface{}, object interface{}) *MockKubernetesClient_PatchMetadata_Call {
+	return &MockKubernetesClient_PatchMetadata_Call{Call: _e.mock.On("PatchMetadata", ctx, object)}
+}
+
+func (_c *MockKubernetesClient_PatchMetadata_Call) Run(run func(ctx context.Context, object *v12.PartialObjectMetadata)) *MockKubernetesClient_PatchMetadata_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *v12.PartialObjectMetadata
+		if args[1] != nil {
+			arg1 = args[1].(*v12.PartialObjectMetadata)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockKubernetesClient_PatchMetadata_Call) Return(err error) *MockKubernetesClient_PatchMetadata_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockKubernetesClient_PatchMetadata_Call) RunAndReturn(run func(ctx context.Context, object *v12.PartialObjectMetadata) error) *MockKubernetesClient_PatchMetadata_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetPodCondition provides a mock function for the type MockKubernetesClient
+func (_mock *MockKubernetesClient) SetPodCondition(ctx context.Context, pod v10.Pod, condition v10.PodCondition) error {
+	ret := _mock.Called(ctx, pod, condition)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetPodCondition")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, v10.Pod, v10.PodCondition) error); ok {
+		r0 = returnFunc(ctx, pod, condition)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockKubernetesClient_SetPodCondition_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetPodCondition'
+type MockKubernetesClient_SetPodCondition_Call struct {
+	*mock.Call
+}
+
+// SetPodCondition is a helper method to define mock.On call
 //   - ctx context.Context
 //   - pod v10.Pod
-func (_e *MockKubernetesClient_Expecter) UpdatePod(ctx interface{}, pod interface{}) *MockKubernetesClient_UpdatePod_Call {
-	return &MockKubernetesClient_UpdatePod_Call{Call: _e.mock.On("UpdatePod", ctx, pod)}
+//   - condition v10.PodCondition
+func (_e *MockKubernetesClient_Expecter) SetPodCondition(ctx interface{}, pod interface{}, condition interface{}) *MockKubernetesClient_SetPodCondition_Call {
+	return &MockKubernetesClient_SetPodCondition_Call{Call: _e.mock.On("SetPodCondition", ctx, pod, condition)}
 }
 
-func (_c *MockKubernetesClient_UpdatePod_Call) Run(run func(ctx context.Context, pod v10.Pod)) *MockKubernetesClient_UpdatePod_Call {
+func (_c *MockKubernetesClient_SetPodCondition_Call) Run(run func(ctx context.Context, pod v10.Pod, condition v10.PodCondition)) *MockKubernetesClient_SetPodCondition_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -284,20 +926,25 @@ func (_c *MockKubernetesClient_UpdatePod_Call) Run(run func(ctx context.Context,
 		if args[1] != nil {
 			arg1 = args[1].(v10.Pod)
 		}
+		var arg2 v10.PodCondition
+		if args[2] != nil {
+			arg2 = args[2].(v10.PodCondition)
+		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *MockKubernetesClient_UpdatePod_Call) Return(err error) *MockKubernetesClient_UpdatePod_Call {
+func (_c *MockKubernetesClient_SetPodCondition_Call) Return(err error) *MockKubernetesClient_SetPodCondition_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *MockKubernetesClient_UpdatePod_Call) RunAndReturn(run func(ctx context.Context, pod v10.Pod) error) *MockKubernetesClient_UpdatePod_Call {
+func (_c *MockKubernetesClient_SetPodCondition_Call) RunAndReturn(run func(ctx context.Context, pod v10.Pod, condition v10.PodCondition) error) *MockKubernetesClient_SetPodCondition_Call {
 	_c.Call.Return(run)
 	return _c
 }